@@ -1,6 +1,13 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
@@ -10,6 +17,48 @@ import (
 )
 
 func main() {
+	daemon := flag.Bool("daemon", false, "run scheduled jobs headless, without opening a window")
+	passphraseFile := flag.String("vault-passphrase-file", "", "with -daemon, unlock the credential vault with the passphrase in `file`")
+	verifyAudit := flag.Bool("verify-audit", false, "verify the audit log hash chain and exit")
+	exportAudit := flag.String("export-audit", "", "write a verifiable audit log report to `file` and exit")
+	auditAnchor := flag.String("audit-anchor", "", "with -verify-audit, also check the `entries:hash` anchor from an earlier export")
+	flag.Parse()
+
+	if *verifyAudit {
+		count, err := appmodules.VerifyAuditLog(*auditAnchor)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "audit log verification failed after %d entries: %v\n", count, err)
+			os.Exit(1)
+		}
+		fmt.Printf("audit log verified: %d entries, hash chain intact\n", count)
+		return
+	}
+
+	if *exportAudit != "" {
+		f, err := os.Create(*exportAudit)
+		if err == nil {
+			err = appmodules.ExportAuditLog(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "audit export failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *daemon {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := appmodules.RunDaemon(ctx, *passphraseFile); err != nil {
+			fmt.Fprintf(os.Stderr, "daemon: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	application := app.New()
 	window := application.NewWindow("Rodent")
 
@@ -27,7 +76,7 @@ func main() {
 
 	contentContainer := container.NewMax()
 	rightColumn := container.NewBorder(
-		titleLabel, nil, nil, nil, contentContainer,
+		titleLabel, nil, nil, nil, container.NewVScroll(contentContainer),
 	)
 
 	var buttons []*widget.Button
@@ -56,24 +105,35 @@ func main() {
 		leftColumn.Add(btn)
 	}
 
+	appmodules.SetNavigator(func(name string) {
+		for index, module := range registered {
+			if module.Name() == name {
+				setActive(index)
+				return
+			}
+		}
+	})
+
 	setActive(0)
+	appmodules.StartServices()
 
 	topBar := container.NewHBox(
 		widget.NewButton("File", func() {}),
-		widget.NewButton("Settings", func() {}),
+		widget.NewButton("Settings", func() { appmodules.ShowSettings(window) }),
 	)
 
-	split := container.NewHSplit(leftColumn, rightColumn)
+	split := container.NewHSplit(container.NewVScroll(leftColumn), rightColumn)
 
 	content := container.NewBorder(
 		topBar,
-		nil,
+		appmodules.AlertBar(),
 		nil,
 		nil,
 		split,
 	)
 
 	window.SetContent(content)
+	appmodules.HandleClose(window)
 
 	const (
 		windowWidth   = 1000.0
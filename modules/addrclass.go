@@ -0,0 +1,282 @@
+package modules
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	addressPrivate   = "private"
+	addressLoopback  = "loopback"
+	addressLinkLocal = "link-local"
+	addressPublic    = "public"
+	addressReserved  = "reserved"
+	addressMulticast = "multicast"
+	addressBogon     = "bogon"
+)
+
+const maxListedAddresses = 5
+
+type specialRange struct {
+	network *net.IPNet
+	kind    string
+	label   string
+}
+
+var specialRanges = func() []specialRange {
+	defs := []struct{ cidr, kind, label string }{
+		{"10.0.0.0/8", addressPrivate, "Private (10.x)"},
+		{"172.16.0.0/12", addressPrivate, "Private (172.16/12)"},
+		{"192.168.0.0/16", addressPrivate, "Private (192.168.x.x)"},
+		{"127.0.0.0/8", addressLoopback, "Loopback"},
+		{"169.254.0.0/16", addressLinkLocal, "Link-local"},
+		{"0.0.0.0/8", addressBogon, "\"This network\" (0.0.0.0/8)"},
+		{"100.64.0.0/10", addressReserved, "Shared address space (100.64.0.0/10)"},
+		{"192.0.0.0/24", addressReserved, "IETF protocol assignments (192.0.0.0/24)"},
+		{"192.0.2.0/24", addressBogon, "Documentation (192.0.2.0/24)"},
+		{"198.18.0.0/15", addressReserved, "Benchmarking (198.18.0.0/15)"},
+		{"198.51.100.0/24", addressBogon, "Documentation (198.51.100.0/24)"},
+		{"203.0.113.0/24", addressBogon, "Documentation (203.0.113.0/24)"},
+		{"224.0.0.0/4", addressMulticast, "Multicast (224.0.0.0/4)"},
+		{"255.255.255.255/32", addressBogon, "Limited broadcast"},
+		{"240.0.0.0/4", addressReserved, "Reserved for future use (240.0.0.0/4)"},
+		{"::1/128", addressLoopback, "Loopback"},
+		{"fe80::/10", addressLinkLocal, "Link-local"},
+		{"fc00::/7", addressPrivate, "Unique local (fc00::/7)"},
+		{"ff00::/8", addressMulticast, "Multicast (ff00::/8)"},
+		{"2001:db8::/32", addressBogon, "Documentation (2001:db8::/32)"},
+		{"::/128", addressBogon, "Unspecified (::)"},
+	}
+	ranges := make([]specialRange, 0, len(defs))
+	for _, def := range defs {
+		_, network, err := net.ParseCIDR(def.cidr)
+		if err != nil {
+			panic(err)
+		}
+		ranges = append(ranges, specialRange{network: network, kind: def.kind, label: def.label})
+	}
+	return ranges
+}()
+
+func classifyIP(ip net.IP) (string, string) {
+	for _, r := range specialRanges {
+		if r.network.Contains(ip) {
+			return r.kind, r.label
+		}
+	}
+	return addressPublic, "Public"
+}
+
+func sensitiveKind(kind string) bool {
+	switch kind {
+	case addressPublic, addressReserved, addressMulticast, addressBogon:
+		return true
+	default:
+		return false
+	}
+}
+
+// sensitiveRange groups the addresses of a run that fall into one public,
+// reserved, multicast or bogon range.
+type sensitiveRange struct {
+	Kind      string
+	Label     string
+	Addresses []string
+}
+
+func (r sensitiveRange) String() string {
+	if len(r.Addresses) == 0 {
+		return r.Label
+	}
+	listed := r.Addresses
+	more := ""
+	if len(listed) > maxListedAddresses {
+		more = fmt.Sprintf(" (+%d more)", len(listed)-maxListedAddresses)
+		listed = listed[:maxListedAddresses]
+	}
+	return fmt.Sprintf("%s: %s%s", r.Label, strings.Join(listed, ", "), more)
+}
+
+func describeRanges(ranges []sensitiveRange) string {
+	parts := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+func rangeKinds(ranges []sensitiveRange) []string {
+	seen := map[string]bool{}
+	var kinds []string
+	for _, r := range ranges {
+		if !seen[r.Kind] {
+			seen[r.Kind] = true
+			kinds = append(kinds, r.Kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func unacknowledged(ranges []sensitiveRange, acknowledged []string) []sensitiveRange {
+	acked := map[string]bool{}
+	for _, kind := range acknowledged {
+		acked[kind] = true
+	}
+	var missing []sensitiveRange
+	for _, r := range ranges {
+		if !acked[r.Kind] {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+// sensitiveAddresses resolves hostnames so that a name pointing at public
+// address space is caught as well.
+func sensitiveAddresses(hosts []string) []sensitiveRange {
+	var ranges []sensitiveRange
+	index := map[string]int{}
+	add := func(ip net.IP, display string) {
+		kind, label := classifyIP(ip)
+		if !sensitiveKind(kind) {
+			return
+		}
+		i, ok := index[label]
+		if !ok {
+			i = len(ranges)
+			index[label] = i
+			ranges = append(ranges, sensitiveRange{Kind: kind, Label: label})
+		}
+		ranges[i].Addresses = append(ranges[i].Addresses, display)
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			add(ip, ip.String())
+			continue
+		}
+		ips, _ := net.LookupIP(host)
+		for _, ip := range ips {
+			add(ip, fmt.Sprintf("%s (%s)", host, ip))
+		}
+	}
+	return ranges
+}
+
+func sensitiveNetwork(network *net.IPNet) []sensitiveRange {
+	var ranges []sensitiveRange
+	covered := false
+	for _, r := range specialRanges {
+		rOnes, _ := r.network.Mask.Size()
+		ones, _ := network.Mask.Size()
+		inside := r.network.Contains(network.IP) && rOnes <= ones
+		if !inside && !network.Contains(r.network.IP) {
+			continue
+		}
+		if inside {
+			covered = true
+		}
+		if sensitiveKind(r.kind) {
+			ranges = append(ranges, sensitiveRange{Kind: r.kind, Label: r.label})
+		}
+	}
+	if !covered {
+		ranges = append(ranges, sensitiveRange{Kind: addressPublic, Label: "Public", Addresses: []string{network.String()}})
+	}
+	return ranges
+}
+
+// targetSensitivity lists the sensitive ranges a job would probe.
+func targetSensitivity(module, target string) ([]sensitiveRange, error) {
+	switch module {
+	case moduleMapper:
+		normalized, err := normalizeSubnet(target)
+		if err != nil {
+			return nil, err
+		}
+		_, network, err := net.ParseCIDR(normalized)
+		if err != nil {
+			return nil, err
+		}
+		return sensitiveNetwork(network), nil
+	case moduleScanner, moduleTraceroute:
+		hosts, err := expandTargets(target)
+		if err != nil {
+			return nil, err
+		}
+		return sensitiveAddresses(hosts), nil
+	default:
+		return sensitiveAddresses([]string{target}), nil
+	}
+}
+
+// checkAcknowledged refuses a run whose targets include sensitive ranges the
+// job was not acknowledged for, and records the ranges in the run settings.
+func checkAcknowledged(spec jobSpec, ranges []sensitiveRange, record *scanRecord) error {
+	if len(ranges) == 0 {
+		return nil
+	}
+	record.Settings["sensitive addresses"] = describeRanges(ranges)
+	missing := unacknowledged(ranges, spec.Acknowledged)
+	if len(missing) == 0 {
+		return nil
+	}
+	reason := fmt.Sprintf("%s not acknowledged", describeRanges(missing))
+	defaultAudit.recordRefusal(spec.Module, spec.Target, reason)
+	return fmt.Errorf("%s", reason)
+}
+
+// confirmSensitive asks the operator to acknowledge probing public, reserved,
+// multicast or bogon address space. proceed receives the acknowledged kinds;
+// it is not called when the operator declines.
+func confirmSensitive(ranges []sensitiveRange, proceed func(acknowledged []string)) {
+	if len(ranges) == 0 {
+		proceed(nil)
+		return
+	}
+	win := activeWindow()
+	if win == nil {
+		return
+	}
+
+	lines := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		lines = append(lines, "• "+r.String())
+	}
+	details := widget.NewLabel(strings.Join(lines, "\n"))
+	details.Wrapping = fyne.TextWrapWord
+
+	var d *dialog.CustomDialog
+	proceedButton := widget.NewButton("Proceed", func() {
+		d.Hide()
+		proceed(rangeKinds(ranges))
+	})
+	proceedButton.Importance = widget.DangerImportance
+	proceedButton.Disable()
+	ack := widget.NewCheck("I am authorized to probe these addresses", func(checked bool) {
+		if checked {
+			proceedButton.Enable()
+		} else {
+			proceedButton.Disable()
+		}
+	})
+	cancelButton := widget.NewButton("Cancel", func() { d.Hide() })
+
+	content := container.NewVBox(
+		widget.NewLabel("The targets include address space outside private networks:"),
+		details,
+		ack,
+	)
+	d = dialog.NewCustomWithoutButtons("Confirm Scan of Non-Private Addresses", content, win)
+	d.SetButtons([]fyne.CanvasObject{cancelButton, proceedButton})
+	d.Resize(fyne.NewSize(520, 0))
+	d.Show()
+}
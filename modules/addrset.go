@@ -0,0 +1,172 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+type ipRange struct {
+	start net.IP
+	end   net.IP
+}
+
+func (r ipRange) contains(ip net.IP) bool {
+	ip16 := ip.To16()
+	return bytes.Compare(ip16, r.start.To16()) >= 0 && bytes.Compare(ip16, r.end.To16()) <= 0
+}
+
+func (r ipRange) String() string {
+	return fmt.Sprintf("%s-%s", r.start, r.end)
+}
+
+// addressSet matches addresses against a list of IPs, CIDRs, dash ranges and
+// hostname patterns such as *.example.com.
+type addressSet struct {
+	networks []*net.IPNet
+	ranges   []ipRange
+	hosts    []string
+	named    map[string]string
+}
+
+func parseAddressSet(entries []string) (addressSet, error) {
+	var set addressSet
+	for _, raw := range entries {
+		entry := strings.TrimSpace(raw)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		switch {
+		case strings.Contains(entry, "/"):
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return addressSet{}, fmt.Errorf("invalid CIDR %q", entry)
+			}
+			set.networks = append(set.networks, network)
+		case net.ParseIP(entry) != nil:
+			ip := net.ParseIP(entry)
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			set.networks = append(set.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		case strings.Contains(entry, "-") && net.ParseIP(strings.TrimSpace(strings.SplitN(entry, "-", 2)[0])) != nil:
+			parts := strings.SplitN(entry, "-", 2)
+			start := net.ParseIP(strings.TrimSpace(parts[0]))
+			end := net.ParseIP(strings.TrimSpace(parts[1]))
+			if end == nil {
+				end = expandShortRangeEnd(start, strings.TrimSpace(parts[1]))
+			}
+			if end == nil || bytes.Compare(start.To16(), end.To16()) > 0 {
+				return addressSet{}, fmt.Errorf("invalid address range %q", entry)
+			}
+			set.ranges = append(set.ranges, ipRange{start: start, end: end})
+		default:
+			set.hosts = append(set.hosts, strings.ToLower(strings.TrimSuffix(entry, ".")))
+		}
+	}
+	return set, nil
+}
+
+// expandShortRangeEnd turns the "20" in 192.168.1.10-20 into 192.168.1.20.
+func expandShortRangeEnd(start net.IP, last string) net.IP {
+	v4 := start.To4()
+	if v4 == nil {
+		return nil
+	}
+	var octet int
+	if _, err := fmt.Sscanf(last, "%d", &octet); err != nil || octet < 0 || octet > 255 || fmt.Sprint(octet) != last {
+		return nil
+	}
+	end := append(net.IP(nil), v4...)
+	end[3] = byte(octet)
+	return end
+}
+
+func (s addressSet) empty() bool {
+	return len(s.networks) == 0 && len(s.ranges) == 0 && len(s.hosts) == 0
+}
+
+func (s addressSet) hasAddresses() bool {
+	return len(s.networks) > 0 || len(s.ranges) > 0
+}
+
+func (s addressSet) containsIP(ip net.IP) bool {
+	for _, network := range s.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	for _, r := range s.ranges {
+		if r.contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s addressSet) matchingEntry(ip net.IP) string {
+	for _, network := range s.networks {
+		if network.Contains(ip) {
+			return network.String()
+		}
+	}
+	for _, r := range s.ranges {
+		if r.contains(ip) {
+			return r.String()
+		}
+	}
+	return ""
+}
+
+func (s addressSet) matchesHost(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if name == "" {
+		return false
+	}
+	for _, pattern := range s.hosts {
+		if pattern == name {
+			return true
+		}
+		if strings.HasPrefix(pattern, "*.") && strings.HasSuffix(name, pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversNetwork reports whether every address of n lies in a single entry.
+func (s addressSet) coversNetwork(n *net.IPNet) bool {
+	first := n.IP.Mask(n.Mask)
+	last := broadcastIP(&net.IPNet{IP: first, Mask: n.Mask})
+	for _, network := range s.networks {
+		if network.Contains(first) && network.Contains(last) {
+			return true
+		}
+	}
+	for _, r := range s.ranges {
+		if r.contains(first) && r.contains(last) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlapsNetwork reports whether any entry shares at least one address with n.
+func (s addressSet) overlapsNetwork(n *net.IPNet) bool {
+	first := n.IP.Mask(n.Mask)
+	last := broadcastIP(&net.IPNet{IP: first, Mask: n.Mask})
+	for _, network := range s.networks {
+		if network.Contains(first) || n.Contains(network.IP) {
+			return true
+		}
+	}
+	for _, r := range s.ranges {
+		if n.Contains(r.start) || n.Contains(r.end) || (r.contains(first) && r.contains(last)) {
+			return true
+		}
+	}
+	return false
+}
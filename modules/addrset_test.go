@@ -0,0 +1,60 @@
+package modules
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseAddressSet(t *testing.T) {
+	set, err := parseAddressSet([]string{
+		"# comment",
+		"10.0.0.0/24",
+		"192.168.1.5",
+		"192.168.2.10-20",
+		"172.16.0.1 - 172.16.0.3",
+		"2001:db8::/64",
+		"*.Example.com.",
+		"printer.local",
+	})
+	if err != nil {
+		t.Fatalf("parseAddressSet: %v", err)
+	}
+	for ip, want := range map[string]bool{
+		"10.0.0.255":    true,
+		"10.0.1.0":      false,
+		"192.168.1.5":   true,
+		"192.168.1.6":   false,
+		"192.168.2.10":  true,
+		"192.168.2.20":  true,
+		"192.168.2.21":  false,
+		"172.16.0.2":    true,
+		"2001:db8::1":   true,
+		"2001:db8:1::1": false,
+	} {
+		if got := set.containsIP(net.ParseIP(ip)); got != want {
+			t.Errorf("containsIP(%s) = %v, want %v", ip, got, want)
+		}
+	}
+	for name, want := range map[string]bool{
+		"www.example.com":  true,
+		"WWW.EXAMPLE.COM.": true,
+		"example.com":      false,
+		"badexample.com":   false,
+		"printer.local":    true,
+		"scanner.local":    false,
+		"":                 false,
+	} {
+		if got := set.matchesHost(name); got != want {
+			t.Errorf("matchesHost(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if got := set.matchingEntry(net.ParseIP("192.168.2.15")); got != "192.168.2.10-192.168.2.20" {
+		t.Errorf("matchingEntry = %q", got)
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "10.0.0.20-10", "10.0.0.1-300", "10.0.0.1-x"} {
+		if _, err := parseAddressSet([]string{bad}); err == nil {
+			t.Errorf("parseAddressSet(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package modules
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	announceWindow = 3 * time.Second
+	dnssdServices  = "_services._dns-sd._udp.local."
+	maxBrowseTypes = 32
+)
+
+var (
+	errMulticastProxied = errors.New("multicast discovery bypasses the proxy chain")
+	errNotOnLink        = errors.New("the subnet is not on a local interface")
+	mdnsGroup           = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+	ssdpGroup           = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+)
+
+// announcement is what a device advertised about itself over mDNS/DNS-SD or
+// SSDP.
+type announcement struct {
+	Hostname string
+	Name     string
+	Model    string
+	Services []string
+	location string
+}
+
+func (a *announcement) addService(service string) {
+	if service != "" && !containsString(a.Services, service) {
+		a.Services = append(a.Services, service)
+	}
+}
+
+type announceCollector struct {
+	mu      sync.Mutex
+	allowed func(net.IP) bool
+	hosts   map[string]*announcement
+}
+
+// update records what ip announced. Devices the run may not probe are
+// ignored.
+func (c *announceCollector) update(ip net.IP, fn func(a *announcement)) {
+	if !c.allowed(ip) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.hosts[ip.String()]
+	if !ok {
+		a = &announcement{}
+		c.hosts[ip.String()] = a
+	}
+	fn(a)
+}
+
+// listenAnnouncements browses DNS-SD and sends an SSDP search on the interface
+// the subnet is on, then fetches the UPnP descriptions of the devices that
+// answered. allowed filters the devices the run may probe.
+func listenAnnouncements(ctx context.Context, src *sourceBinding, ipnet *net.IPNet, allowed func(net.IP) bool) (map[string]*announcement, error) {
+	if defaultProxy.current().active() {
+		return nil, errMulticastProxied
+	}
+	iface, local := multicastInterface(src, ipnet)
+	if iface == nil {
+		return nil, errNotOnLink
+	}
+	c := &announceCollector{
+		allowed: func(ip net.IP) bool { return ipnet.Contains(ip) && allowed(ip) },
+		hosts:   map[string]*announcement{},
+	}
+	var mdnsErr, ssdpErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		mdnsErr = browseMDNS(ctx, iface, local, c)
+	}()
+	go func() {
+		defer wg.Done()
+		ssdpErr = searchSSDP(ctx, iface, local, c)
+	}()
+	wg.Wait()
+	if mdnsErr != nil && ssdpErr != nil {
+		return nil, errors.Join(mdnsErr, ssdpErr)
+	}
+
+	for ip, a := range c.hosts {
+		if a.location == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(ip string, a *announcement) {
+			defer wg.Done()
+			describeUPnP(ctx, src, a)
+		}(ip, a)
+	}
+	wg.Wait()
+	return c.hosts, nil
+}
+
+func multicastInterface(src *sourceBinding, ipnet *net.IPNet) (*net.Interface, net.IP) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			local, ok := addr.(*net.IPNet)
+			if !ok || local.IP.To4() == nil || (src != nil && !local.IP.Equal(src.ip)) {
+				continue
+			}
+			if local.Contains(ipnet.IP) || ipnet.Contains(local.IP) {
+				return &iface, local.IP.To4()
+			}
+		}
+	}
+	return nil, nil
+}
+
+func multicastConn(iface *net.Interface, local net.IP, ttl int) (*net.UDPConn, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: local})
+	if err != nil {
+		return nil, err
+	}
+	p := ipv4.NewPacketConn(conn)
+	if err := p.SetMulticastInterface(iface); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	p.SetMulticastTTL(ttl)
+	return conn, nil
+}
+
+// browseMDNS asks for the service types on the segment, then for the
+// instances of each. Queries come from an ephemeral port, so responders reply
+// by unicast (RFC 6762 section 6.7).
+func browseMDNS(ctx context.Context, iface *net.Interface, local net.IP, c *announceCollector) error {
+	conn, err := multicastConn(iface, local, 255)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(announceWindow)
+	types := map[string]bool{}
+	if err := sendMDNS(conn, []string{dnssdServices}); err != nil {
+		return err
+	}
+	readMDNS(ctx, conn, time.Now().Add(announceWindow/3), c, types)
+	if len(types) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		if len(names) < maxBrowseTypes {
+			names = append(names, name)
+		}
+	}
+	if err := sendMDNS(conn, names); err != nil {
+		return err
+	}
+	readMDNS(ctx, conn, deadline, c, types)
+	return nil
+}
+
+func sendMDNS(conn *net.UDPConn, names []string) error {
+	msg := dnsmessage.Message{Header: dnsmessage.Header{ID: 1}}
+	for _, name := range names {
+		n, err := dnsmessage.NewName(name)
+		if err != nil {
+			continue
+		}
+		msg.Questions = append(msg.Questions, dnsmessage.Question{Name: n, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET})
+	}
+	packet, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+	_, err = conn.WriteToUDP(packet, mdnsGroup)
+	return err
+}
+
+func readMDNS(ctx context.Context, conn *net.UDPConn, deadline time.Time, c *announceCollector, types map[string]bool) {
+	conn.SetReadDeadline(deadline)
+	buf := make([]byte, 9000)
+	for ctx.Err() == nil {
+		n, peer, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		var msg dnsmessage.Message
+		if msg.Unpack(buf[:n]) != nil || !msg.Response {
+			continue
+		}
+		c.update(peer.IP, func(a *announcement) {
+			for _, rr := range append(msg.Answers, msg.Additionals...) {
+				name := rr.Header.Name.String()
+				switch body := rr.Body.(type) {
+				case *dnsmessage.PTRResource:
+					target := body.PTR.String()
+					if name == dnssdServices {
+						types[target] = true
+						a.addService(strings.TrimSuffix(target, ".local."))
+					} else if strings.HasSuffix(name, "._tcp.local.") || strings.HasSuffix(name, "._udp.local.") {
+						a.addService(strings.TrimSuffix(name, ".local."))
+						if a.Name == "" {
+							a.Name = strings.TrimSuffix(target, "."+name)
+						}
+					}
+				case *dnsmessage.AResource:
+					if a.Hostname == "" && net.IP(body.A[:]).Equal(peer.IP) {
+						a.Hostname = strings.TrimSuffix(name, ".")
+					}
+				case *dnsmessage.TXTResource:
+					for _, txt := range body.TXT {
+						key, value, _ := strings.Cut(txt, "=")
+						switch strings.ToLower(key) {
+						case "fn":
+							a.Name = value
+						case "ty", "md", "model", "usb_mdl":
+							if a.Model == "" {
+								a.Model = value
+							}
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// searchSSDP sends M-SEARCH for every device and service type and keeps the
+// description URL of devices that serve it themselves.
+func searchSSDP(ctx context.Context, iface *net.Interface, local net.IP, c *announceCollector) error {
+	conn, err := multicastConn(iface, local, 2)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	search := []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nMX: 2\r\nST: ssdp:all\r\n\r\n")
+	for i := 0; i < 2; i++ {
+		if _, err := conn.WriteToUDP(search, ssdpGroup); err != nil {
+			return err
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(announceWindow))
+	buf := make([]byte, 4096)
+	for ctx.Err() == nil {
+		n, peer, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return nil
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		st, location := resp.Header.Get("ST"), resp.Header.Get("LOCATION")
+		c.update(peer.IP, func(a *announcement) {
+			a.addService(upnpType(st))
+			if u, err := url.Parse(location); err == nil && a.location == "" && net.ParseIP(u.Hostname()).Equal(peer.IP) {
+				a.location = location
+			}
+		})
+	}
+	return nil
+}
+
+// upnpType shortens urn:schemas-upnp-org:device:MediaRenderer:1 to
+// upnp:MediaRenderer. Search targets that are not types are dropped.
+func upnpType(st string) string {
+	parts := strings.Split(st, ":")
+	if len(parts) != 5 || parts[0] != "urn" || (parts[2] != "device" && parts[2] != "service") {
+		return ""
+	}
+	return "upnp:" + parts[3]
+}
+
+type upnpDescription struct {
+	Device struct {
+		DeviceType   string `xml:"deviceType"`
+		FriendlyName string `xml:"friendlyName"`
+		Manufacturer string `xml:"manufacturer"`
+		ModelName    string `xml:"modelName"`
+		ModelNumber  string `xml:"modelNumber"`
+	} `xml:"device"`
+}
+
+func describeUPnP(ctx context.Context, src *sourceBinding, a *announcement) {
+	client := &http.Client{
+		Timeout: 3 * time.Second,
+		Transport: &http.Transport{
+			// Each description is fetched once, so idle connections would
+			// only hold socket slots until the transport is collected.
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return dialTCPContext(ctx, src, address, 2*time.Second)
+			},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.location, nil)
+	if err != nil {
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	var desc upnpDescription
+	if xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&desc) != nil {
+		return
+	}
+	d := desc.Device
+	a.addService(upnpType(d.DeviceType))
+	if a.Name == "" {
+		a.Name = strings.TrimSpace(d.FriendlyName)
+	}
+	if a.Model == "" {
+		a.Model = strings.Join(strings.Fields(d.Manufacturer+" "+d.ModelName+" "+d.ModelNumber), " ")
+	}
+}
@@ -0,0 +1,156 @@
+//go:build linux
+
+package modules
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	arpRounds = 2
+	arpPace   = time.Millisecond
+	arpWait   = 800 * time.Millisecond
+)
+
+// arpSegment is the part of a sweep that goes out of one interface.
+type arpSegment struct {
+	iface   net.Interface
+	ip      net.IP
+	targets []net.IP
+}
+
+// arpSweep asks every on-link target for its MAC address over an AF_PACKET
+// socket, which needs CAP_NET_RAW. The result covers the on-link targets: the
+// MAC address of those that answered, "" for those that did not.
+func arpSweep(ctx context.Context, src *sourceBinding, targets []net.IP) (map[string]string, error) {
+	if defaultProxy.current().active() {
+		return nil, errARPProxied
+	}
+	results := map[string]string{}
+	for _, seg := range arpSegments(src, targets) {
+		if err := seg.sweep(ctx, results); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func arpSegments(src *sourceBinding, targets []net.IP) []*arpSegment {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var segments []*arpSegment
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) != 6 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil || (src != nil && !ipnet.IP.Equal(src.ip)) {
+				continue
+			}
+			seg := &arpSegment{iface: iface, ip: ipnet.IP.To4()}
+			for _, target := range targets {
+				if v4 := target.To4(); v4 != nil && ipnet.Contains(v4) && !v4.Equal(seg.ip) {
+					seg.targets = append(seg.targets, v4)
+				}
+			}
+			if len(seg.targets) > 0 {
+				segments = append(segments, seg)
+			}
+		}
+	}
+	return segments
+}
+
+func (seg *arpSegment) sweep(ctx context.Context, results map[string]string) error {
+	release := defaultGuard.acquireSocket()
+	defer release()
+
+	proto := htons(syscall.ETH_P_ARP)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_DGRAM, int(proto))
+	if err != nil {
+		return fmt.Errorf("no packet socket: %w", err)
+	}
+	defer syscall.Close(fd)
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: proto, Ifindex: seg.iface.Index}); err != nil {
+		return fmt.Errorf("bind to %s: %w", seg.iface.Name, err)
+	}
+	tv := syscall.NsecToTimeval(int64(50 * time.Millisecond))
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return err
+	}
+	broadcast := &syscall.SockaddrLinklayer{Protocol: proto, Ifindex: seg.iface.Index, Halen: 6}
+	copy(broadcast.Addr[:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	pending := map[string]bool{}
+	for _, target := range seg.targets {
+		pending[target.String()] = true
+		results[target.String()] = ""
+	}
+	buf := make([]byte, 1500)
+	for round := 0; round < arpRounds && len(pending) > 0; round++ {
+		for _, target := range seg.targets {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !pending[target.String()] {
+				continue
+			}
+			if err := syscall.Sendto(fd, seg.request(target), 0, broadcast); err != nil {
+				return fmt.Errorf("send on %s: %w", seg.iface.Name, err)
+			}
+			time.Sleep(arpPace)
+		}
+		deadline := time.Now().Add(arpWait)
+		for len(pending) > 0 && time.Now().Before(deadline) && ctx.Err() == nil {
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err != nil {
+				continue
+			}
+			if ip, mac, ok := parseARPReply(buf[:n]); ok && pending[ip] {
+				delete(pending, ip)
+				results[ip] = mac
+			}
+		}
+	}
+	return nil
+}
+
+func (seg *arpSegment) request(target net.IP) []byte {
+	pkt := make([]byte, 28)
+	binary.BigEndian.PutUint16(pkt[0:], 1)
+	binary.BigEndian.PutUint16(pkt[2:], 0x0800)
+	pkt[4], pkt[5] = 6, 4
+	binary.BigEndian.PutUint16(pkt[6:], 1)
+	copy(pkt[8:], seg.iface.HardwareAddr)
+	copy(pkt[14:], seg.ip)
+	copy(pkt[24:], target.To4())
+	return pkt
+}
+
+func parseARPReply(pkt []byte) (string, string, bool) {
+	if len(pkt) < 28 || binary.BigEndian.Uint16(pkt[2:]) != 0x0800 || pkt[4] != 6 || pkt[5] != 4 {
+		return "", "", false
+	}
+	if binary.BigEndian.Uint16(pkt[6:]) != 2 {
+		return "", "", false
+	}
+	mac := net.HardwareAddr(pkt[8:14])
+	return net.IP(pkt[14:18]).String(), mac.String(), true
+}
+
+// htons converts v to network byte order, as the socket calls expect it in
+// a native integer.
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return binary.NativeEndian.Uint16(b[:])
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package modules
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+func arpSweep(ctx context.Context, src *sourceBinding, targets []net.IP) (map[string]string, error) {
+	return nil, errors.New("ARP sweeps need AF_PACKET sockets, which only Linux has")
+}
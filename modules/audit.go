@@ -0,0 +1,588 @@
+package modules
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	auditFile        = "audit.log"
+	auditHeadFile    = "audit_head.json"
+	auditLockFile    = "audit.lock"
+	auditOperatorKey = "operator.json"
+	auditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+	auditListLimit   = 500
+)
+
+const (
+	auditScanStarted  = "scan started"
+	auditScanFinished = "scan finished"
+	auditScanStopped  = "scan stopped"
+	auditScanFailed   = "scan failed"
+	auditScanRefused  = "scan refused"
+)
+
+type auditEntry struct {
+	Seq      int               `json:"seq"`
+	Time     time.Time         `json:"time"`
+	Event    string            `json:"event"`
+	Operator string            `json:"operator"`
+	Module   string            `json:"module"`
+	RunID    string            `json:"run_id"`
+	JobID    string            `json:"job_id,omitempty"`
+	Targets  []string          `json:"targets"`
+	Settings map[string]string `json:"settings,omitempty"`
+	Result   string            `json:"result,omitempty"`
+	PrevHash string            `json:"prev_hash"`
+	Hash     string            `json:"hash"`
+}
+
+func (e auditEntry) String() string {
+	line := fmt.Sprintf("#%d %s %-14s %-21s %s by %s",
+		e.Seq, e.Time.Format("2006-01-02 15:04:05"), e.Event, e.Module, strings.Join(e.Targets, ","), e.Operator)
+	if e.Result != "" {
+		line += " - " + e.Result
+	}
+	return line
+}
+
+func (e auditEntry) computeHash() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(append([]byte(e.PrevHash), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// auditHead is the newest entry's sequence number and hash. It is kept
+// outside the log so that entries cut from the end are noticed.
+type auditHead struct {
+	Seq  int    `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+func (h auditHead) String() string {
+	return fmt.Sprintf("%d:%s", h.Seq, h.Hash)
+}
+
+func parseAuditHead(text string) (auditHead, error) {
+	seqText, hash, ok := strings.Cut(strings.TrimSpace(text), ":")
+	seq, err := strconv.Atoi(seqText)
+	if !ok || err != nil || seq < 1 || len(hash) != len(auditGenesisHash) {
+		return auditHead{}, fmt.Errorf("invalid anchor %q, want <entries>:<final hash>", text)
+	}
+	return auditHead{Seq: seq, Hash: strings.ToLower(hash)}, nil
+}
+
+type auditLog struct {
+	mu        sync.Mutex
+	loadOnce  sync.Once
+	operator  string
+	listeners []func()
+}
+
+var defaultAudit = &auditLog{}
+
+type auditOperator struct {
+	Name string `json:"name"`
+}
+
+func (a *auditLog) load() {
+	a.loadOnce.Do(func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		var op auditOperator
+		if err := loadJSON(auditOperatorKey, &op); err != nil {
+			log.Printf("audit: %v", err)
+		}
+		a.operator = op.Name
+	})
+}
+
+func (a *auditLog) currentOperator() string {
+	a.load()
+	a.mu.Lock()
+	name := a.operator
+	a.mu.Unlock()
+	if name != "" {
+		return name
+	}
+	if env := os.Getenv("RODENT_OPERATOR"); env != "" {
+		return env
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+func (a *auditLog) setOperator(name string) error {
+	a.load()
+	a.mu.Lock()
+	a.operator = strings.TrimSpace(name)
+	err := saveJSON(auditOperatorKey, auditOperator{Name: a.operator})
+	a.mu.Unlock()
+	return err
+}
+
+func (a *auditLog) recordRun(event string, rec scanRecord) {
+	operator := a.currentOperator()
+	if rec.JobID != "" {
+		operator = fmt.Sprintf("scheduler (%s)", operator)
+	}
+	entry := auditEntry{
+		Time:     time.Now(),
+		Event:    event,
+		Operator: operator,
+		Module:   rec.Module,
+		RunID:    rec.ID,
+		JobID:    rec.JobID,
+		Targets:  []string{rec.Target},
+		Settings: rec.Settings,
+	}
+	if event != auditScanStarted {
+		entry.Result = rec.Summary
+	}
+	if err := a.append(entry); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+func (a *auditLog) recordRefusal(module, target, reason string) {
+	entry := auditEntry{
+		Time:     time.Now(),
+		Event:    auditScanRefused,
+		Operator: a.currentOperator(),
+		Module:   module,
+		Targets:  []string{target},
+		Result:   reason,
+	}
+	if err := a.append(entry); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+// append chains entry to the last one in the file. The GUI and the daemon
+// may both be writing, so the tail is read again under a file lock rather
+// than remembered. The head only moves while the log still ends where it
+// said; after a truncation it is left for verification to report.
+func (a *auditLog) append(entry auditEntry) error {
+	a.mu.Lock()
+	err := withFileLock(auditLockFile, func() error {
+		last, err := lastAuditEntry()
+		if err != nil {
+			return err
+		}
+		var head auditHead
+		if err := loadJSON(auditHeadFile, &head); err != nil {
+			return err
+		}
+		entry.Seq = 1
+		entry.PrevHash = auditGenesisHash
+		if last != nil {
+			entry.Seq = last.Seq + 1
+			entry.PrevHash = last.Hash
+		}
+		entry.Hash = entry.computeHash()
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := appendAuditLine(data); err != nil {
+			return err
+		}
+		switch {
+		case head.Seq == 0,
+			last != nil && last.Seq == head.Seq && last.Hash == head.Hash,
+			last != nil && last.Seq == head.Seq+1 && last.PrevHash == head.Hash:
+			return saveJSON(auditHeadFile, auditHead{Seq: entry.Seq, Hash: entry.Hash})
+		}
+		log.Printf("audit: the log no longer ends at entry %d recorded in %s", head.Seq, auditHeadFile)
+		return nil
+	})
+	listeners := append([]func(){}, a.listeners...)
+	a.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn()
+	}
+	return err
+}
+
+// withFileLock runs fn while holding the lock file name in the data
+// directory.
+func withFileLock(name string, fn func() error) error {
+	path, err := dataPath(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+	return fn()
+}
+
+// lastAuditEntry reads the final entry from the end of the log without
+// reading the rest of it.
+func lastAuditEntry() (*auditEntry, error) {
+	path, err := dataPath(auditFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	for chunk := int64(4096); ; chunk *= 2 {
+		start := max(size-chunk, 0)
+		buf := make([]byte, size-start)
+		if _, err := f.ReadAt(buf, start); err != nil {
+			return nil, err
+		}
+		buf = bytes.TrimRight(buf, " \t\r\n")
+		cut := bytes.LastIndexByte(buf, '\n')
+		if cut < 0 && start > 0 {
+			continue
+		}
+		if len(buf) == 0 {
+			return nil, nil
+		}
+		var entry auditEntry
+		if err := json.Unmarshal(buf[cut+1:], &entry); err != nil {
+			return nil, fmt.Errorf("the last line is not a valid audit entry: %w", err)
+		}
+		return &entry, nil
+	}
+}
+
+func appendAuditLine(data []byte) error {
+	path, err := dataPath(auditFile)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readAuditEntries() ([]auditEntry, error) {
+	path, err := dataPath(auditFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("line %d is not a valid audit entry: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// verifyAuditEntries checks the hash chain and that it still holds each
+// anchor, a head recorded when the log was longer or exported.
+func verifyAuditEntries(entries []auditEntry, anchors ...auditHead) error {
+	prev := auditGenesisHash
+	for i, entry := range entries {
+		if entry.Seq != i+1 {
+			return fmt.Errorf("entry %d has sequence number %d (entries removed or reordered)", i+1, entry.Seq)
+		}
+		if entry.PrevHash != prev {
+			return fmt.Errorf("entry %d does not chain to the previous entry", entry.Seq)
+		}
+		if entry.computeHash() != entry.Hash {
+			return fmt.Errorf("entry %d was modified after it was written", entry.Seq)
+		}
+		prev = entry.Hash
+	}
+	for _, anchor := range anchors {
+		if anchor.Seq == 0 {
+			continue
+		}
+		if anchor.Seq > len(entries) {
+			return fmt.Errorf("the log ends at entry %d but entry %d was recorded (entries removed from the end)", len(entries), anchor.Seq)
+		}
+		if entries[anchor.Seq-1].Hash != anchor.Hash {
+			return fmt.Errorf("entry %d does not match the recorded hash", anchor.Seq)
+		}
+	}
+	return nil
+}
+
+// VerifyAuditLog checks the log against its chain and the head kept beside
+// it. anchor, when given, is an "<entries>:<final hash>" pair taken from an
+// earlier export, which also catches a log and head that were rolled back
+// together.
+func VerifyAuditLog(anchor string) (int, error) {
+	anchors := make([]auditHead, 2)
+	if err := loadJSON(auditHeadFile, &anchors[0]); err != nil {
+		return 0, err
+	}
+	if anchor != "" {
+		head, err := parseAuditHead(anchor)
+		if err != nil {
+			return 0, err
+		}
+		anchors[1] = head
+	}
+	entries, err := readAuditEntries()
+	if err != nil {
+		return len(entries), err
+	}
+	return len(entries), verifyAuditEntries(entries, anchors...)
+}
+
+// ExportAuditLog writes the log for engagement paperwork. Its anchor line is
+// what makes the export evidence: filed with the paperwork, it is outside
+// the reach of anyone who can edit the data directory, and passing it to
+// rodent -verify-audit -audit-anchor later proves that no entry up to it was
+// removed or changed, even if the log and its head were both rewritten.
+func ExportAuditLog(w io.Writer) error {
+	entries, readErr := readAuditEntries()
+	verifyErr := readErr
+	if verifyErr == nil {
+		var head auditHead
+		verifyErr = loadJSON(auditHeadFile, &head)
+		if verifyErr == nil {
+			verifyErr = verifyAuditEntries(entries, head)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "Rodent scan activity audit log\n")
+	fmt.Fprintf(bw, "Exported: %s\n", time.Now().Format(time.RFC1123))
+	fmt.Fprintf(bw, "Exported by: %s\n", defaultAudit.currentOperator())
+	fmt.Fprintf(bw, "Entries: %d\n", len(entries))
+	if verifyErr != nil {
+		fmt.Fprintf(bw, "Hash chain: FAILED (%v)\n", verifyErr)
+	} else {
+		fmt.Fprintf(bw, "Hash chain: verified\n")
+	}
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		fmt.Fprintf(bw, "Final hash: %s\n", last.Hash)
+		fmt.Fprintf(bw, "Anchor: %s\n", auditHead{Seq: last.Seq, Hash: last.Hash})
+		fmt.Fprintf(bw, "File the anchor with the engagement records; rodent -verify-audit -audit-anchor <anchor>\n")
+		fmt.Fprintf(bw, "confirms later that none of these entries was removed or changed.\n")
+	}
+
+	fmt.Fprintf(bw, "\nActivity\n--------\n")
+	for _, entry := range entries {
+		fmt.Fprintln(bw, entry.String())
+		if len(entry.Settings) > 0 {
+			keys := make([]string, 0, len(entry.Settings))
+			for k := range entry.Settings {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(bw, "    %s: %s\n", k, entry.Settings[k])
+			}
+		}
+	}
+
+	fmt.Fprintf(bw, "\nRaw entries (JSON lines, verifiable)\n------------------------------------\n")
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(bw, string(data))
+	}
+	return bw.Flush()
+}
+
+func (a *auditLog) subscribe(fn func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.listeners = append(a.listeners, fn)
+}
+
+type auditModule struct {
+	content       fyne.CanvasObject
+	operatorEntry *widget.Entry
+	statusLabel   *widget.Label
+	entryList     *widget.List
+	entries       []auditEntry
+}
+
+func (m *auditModule) Name() string {
+	return "Audit Log"
+}
+
+func (m *auditModule) Content() fyne.CanvasObject {
+	if m.content != nil {
+		return m.content
+	}
+
+	m.operatorEntry = widget.NewEntry()
+	m.operatorEntry.SetPlaceHolder("Operator name")
+	m.operatorEntry.SetText(defaultAudit.currentOperator())
+	saveOperator := widget.NewButton("Set Operator", func() {
+		if err := defaultAudit.setOperator(m.operatorEntry.Text); err != nil {
+			m.setStatus(fmt.Sprintf("Unable to save operator: %v", err))
+			return
+		}
+		m.setStatus(fmt.Sprintf("Scans will be recorded as run by %s.", defaultAudit.currentOperator()))
+	})
+
+	verifyButton := widget.NewButton("Verify Chain", m.verify)
+	exportButton := widget.NewButton("Export...", m.export)
+
+	rowHeight := m.operatorEntry.MinSize().Height
+	controls := container.NewHBox(
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(200, rowHeight)), m.operatorEntry),
+		saveOperator,
+		verifyButton,
+		exportButton,
+		layout.NewSpacer(),
+	)
+
+	m.statusLabel = widget.NewLabel("Every scan start and stop is appended to a hash-chained log.")
+
+	m.entryList = widget.NewList(
+		func() int { return len(m.entries) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i int, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(m.entries[i].String())
+		},
+	)
+	scroll := container.NewVScroll(m.entryList)
+	scroll.SetMinSize(fyne.NewSize(0, 360))
+
+	m.content = container.NewVBox(
+		widget.NewLabelWithStyle("Audit Log", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Tamper-evident record of what was scanned, when, by whom and with which settings."),
+		controls,
+		m.statusLabel,
+		widget.NewCard("Entries", "Newest first.", container.NewMax(scroll)),
+	)
+
+	defaultAudit.subscribe(func() { m.queueOnMain(m.reload) })
+	m.reload()
+
+	return m.content
+}
+
+func (m *auditModule) reload() {
+	entries, err := readAuditEntries()
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Unable to read audit log: %v", err))
+	}
+	m.entries = m.entries[:0]
+	for i := len(entries) - 1; i >= 0 && len(m.entries) < auditListLimit; i-- {
+		m.entries = append(m.entries, entries[i])
+	}
+	m.entryList.Refresh()
+}
+
+func (m *auditModule) verify() {
+	count, err := VerifyAuditLog("")
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Audit log verification FAILED: %v", err))
+		return
+	}
+	m.setStatus(fmt.Sprintf("Audit log verified: %d entries, hash chain intact.", count))
+}
+
+func (m *auditModule) export() {
+	win := activeWindow()
+	if win == nil {
+		return
+	}
+	save := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+		if err != nil || w == nil {
+			return
+		}
+		defer w.Close()
+		if err := ExportAuditLog(w); err != nil {
+			m.setStatus(fmt.Sprintf("Export failed: %v", err))
+			return
+		}
+		m.setStatus(fmt.Sprintf("Audit log exported to %s.", w.URI().Path()))
+	}, win)
+	save.SetFileName(fmt.Sprintf("rodent-audit-%s.txt", time.Now().Format("20060102-150405")))
+	save.Show()
+}
+
+func (m *auditModule) setStatus(text string) {
+	if m.statusLabel != nil {
+		m.statusLabel.SetText(text)
+	}
+}
+
+func (m *auditModule) queueOnMain(fn func()) {
+	if app := fyne.CurrentApp(); app != nil {
+		if drv := app.Driver(); drv != nil {
+			if runner, ok := drv.(interface{ RunOnMain(func()) }); ok {
+				runner.RunOnMain(fn)
+				return
+			}
+		}
+	}
+	fn()
+}
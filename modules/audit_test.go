@@ -0,0 +1,137 @@
+package modules
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func auditChain(n int) []auditEntry {
+	entries := make([]auditEntry, n)
+	prev := auditGenesisHash
+	for i := range entries {
+		e := auditEntry{
+			Seq:      i + 1,
+			Time:     time.Date(2026, 1, 1, 0, i, 0, 0, time.UTC),
+			Event:    auditScanFinished,
+			Operator: "tester",
+			Module:   moduleScanner,
+			RunID:    "run",
+			Targets:  []string{"10.0.0.1"},
+			PrevHash: prev,
+		}
+		e.Hash = e.computeHash()
+		prev = e.Hash
+		entries[i] = e
+	}
+	return entries
+}
+
+func TestVerifyAuditEntries(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]auditEntry) []auditEntry
+		want   string
+	}{
+		{"intact", func(e []auditEntry) []auditEntry { return e }, ""},
+		{"empty", func(e []auditEntry) []auditEntry { return nil }, "entries removed from the end"},
+		{"modified", func(e []auditEntry) []auditEntry {
+			e[1].Targets = []string{"10.0.0.2"}
+			return e
+		}, "entry 2 was modified"},
+		{"rehashed", func(e []auditEntry) []auditEntry {
+			e[1].Operator = "someone else"
+			e[1].Hash = e[1].computeHash()
+			return e
+		}, "entry 3 does not chain"},
+		{"removed", func(e []auditEntry) []auditEntry {
+			return append(e[:1], e[2:]...)
+		}, "sequence number 3"},
+		{"truncated start", func(e []auditEntry) []auditEntry { return e[1:] }, "sequence number 2"},
+		{"reordered", func(e []auditEntry) []auditEntry {
+			e[1], e[2] = e[2], e[1]
+			return e
+		}, "sequence number 3"},
+		{"truncated end", func(e []auditEntry) []auditEntry { return e[:3] }, "entries removed from the end"},
+		{"rewritten end", func(e []auditEntry) []auditEntry {
+			e[3].Result = "nothing found"
+			e[3].Hash = e[3].computeHash()
+			return e
+		}, "entry 4 does not match the recorded hash"},
+	}
+	head := auditChain(4)[3]
+	for _, tt := range tests {
+		err := verifyAuditEntries(tt.tamper(auditChain(4)), auditHead{Seq: head.Seq, Hash: head.Hash})
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// Two auditLogs stand in for the GUI and the daemon writing at once.
+func TestAuditAppendConcurrent(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	gui, daemon := &auditLog{}, &auditLog{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, a := range []*auditLog{gui, daemon} {
+			wg.Add(1)
+			go func(a *auditLog) {
+				defer wg.Done()
+				if err := a.append(auditEntry{Event: auditScanStarted, Module: moduleScanner}); err != nil {
+					t.Error(err)
+				}
+			}(a)
+		}
+	}
+	wg.Wait()
+	if count, err := VerifyAuditLog(""); err != nil || count != 20 {
+		t.Fatalf("VerifyAuditLog = %d, %v; want 20 entries", count, err)
+	}
+}
+
+func TestAuditTruncatedEnd(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	a := &auditLog{}
+	for i := 0; i < 3; i++ {
+		if err := a.append(auditEntry{Time: time.Now(), Event: auditScanFinished, Module: moduleScanner}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := readAuditEntries()
+	anchor := auditHead{Seq: 3, Hash: entries[2].Hash}.String()
+	if _, err := VerifyAuditLog(anchor); err != nil {
+		t.Fatalf("intact log: %v", err)
+	}
+
+	path, _ := dataPath(auditFile)
+	data, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(strings.TrimSpace(string(data)), "\n")
+	if err := os.WriteFile(path, []byte(strings.Join(lines[:2], "")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyAuditLog(""); err == nil {
+		t.Fatal("a log cut after entry 2 verified")
+	}
+	if err := a.append(auditEntry{Time: time.Now(), Event: auditScanStarted, Module: moduleScanner}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyAuditLog(""); err == nil {
+		t.Fatal("appending after the cut hid it")
+	}
+
+	os.Remove(path)
+	headPath, _ := dataPath(auditHeadFile)
+	os.Remove(headPath)
+	if _, err := VerifyAuditLog(anchor); err == nil {
+		t.Fatal("the exported anchor did not catch a log and head removed together")
+	}
+	if _, err := VerifyAuditLog("3"); err == nil {
+		t.Error("accepted an anchor without a hash")
+	}
+}
@@ -0,0 +1,549 @@
+package modules
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+const baselinesFile = "baselines.json"
+
+const (
+	scopeHost   = "Host"
+	scopeSubnet = "Subnet"
+	scopeTag    = "Tag"
+)
+
+const findingSourcePolicy = "policy"
+
+type baselinePolicy struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Scope           string   `json:"scope"`
+	Match           string   `json:"match"`
+	AllowedPorts    []int    `json:"allowed_ports,omitempty"`
+	AllowedServices []string `json:"allowed_services,omitempty"`
+	ForbiddenPorts  []int    `json:"forbidden_ports,omitempty"`
+	Severity        string   `json:"severity"`
+}
+
+type baselineState struct {
+	Policies []baselinePolicy    `json:"policies"`
+	Tags     map[string][]string `json:"tags"`
+}
+
+type baselineStore struct {
+	mu        sync.Mutex
+	loadOnce  sync.Once
+	state     baselineState
+	listeners []func()
+}
+
+var defaultBaselines = &baselineStore{}
+
+func (b *baselineStore) load() {
+	b.loadOnce.Do(func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if err := loadJSON(baselinesFile, &b.state); err != nil {
+			log.Printf("baselines: %v", err)
+		}
+		if b.state.Tags == nil {
+			b.state.Tags = map[string][]string{}
+		}
+	})
+}
+
+func (b *baselineStore) snapshot() baselineState {
+	b.load()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tags := make(map[string][]string, len(b.state.Tags))
+	for host, list := range b.state.Tags {
+		tags[host] = append([]string(nil), list...)
+	}
+	return baselineState{
+		Policies: append([]baselinePolicy(nil), b.state.Policies...),
+		Tags:     tags,
+	}
+}
+
+func (b *baselineStore) addPolicy(policy baselinePolicy) error {
+	policy.Match = strings.TrimSpace(policy.Match)
+	if policy.Match == "" {
+		return fmt.Errorf("a host, subnet or tag is required")
+	}
+	if policy.Scope == scopeSubnet {
+		normalized, err := normalizeSubnet(policy.Match)
+		if err != nil {
+			return fmt.Errorf("invalid subnet: %w", err)
+		}
+		policy.Match = normalized
+	}
+	if len(policy.AllowedPorts) == 0 && len(policy.AllowedServices) == 0 && len(policy.ForbiddenPorts) == 0 {
+		return fmt.Errorf("list at least one allowed or forbidden port")
+	}
+	if policy.Name == "" {
+		policy.Name = fmt.Sprintf("%s %s", policy.Scope, policy.Match)
+	}
+	if policy.Severity == "" {
+		policy.Severity = "High"
+	}
+
+	b.load()
+	b.mu.Lock()
+	policy.ID = newID()
+	b.state.Policies = append(b.state.Policies, policy)
+	b.saveLocked()
+	b.mu.Unlock()
+
+	b.changed()
+	return nil
+}
+
+func (b *baselineStore) removePolicy(id string) {
+	b.mu.Lock()
+	for i := range b.state.Policies {
+		if b.state.Policies[i].ID == id {
+			b.state.Policies = append(b.state.Policies[:i], b.state.Policies[i+1:]...)
+			break
+		}
+	}
+	b.saveLocked()
+	b.mu.Unlock()
+	b.changed()
+}
+
+func (b *baselineStore) setTags(host string, tags []string) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" {
+		return
+	}
+	b.load()
+	b.mu.Lock()
+	if len(tags) == 0 {
+		delete(b.state.Tags, host)
+	} else {
+		b.state.Tags[host] = tags
+	}
+	b.saveLocked()
+	b.mu.Unlock()
+	b.changed()
+}
+
+func (b *baselineStore) saveLocked() {
+	if err := saveJSON(baselinesFile, b.state); err != nil {
+		log.Printf("baselines: %v", err)
+	}
+}
+
+func (b *baselineStore) subscribe(fn func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, fn)
+}
+
+func (b *baselineStore) changed() {
+	b.mu.Lock()
+	listeners := append([]func(){}, b.listeners...)
+	b.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+func (b *baselineStore) evaluate(target string, ports []portStatus) []vulnerabilityFinding {
+	state := b.snapshot()
+	if len(state.Policies) == 0 {
+		return nil
+	}
+
+	identities := []string{strings.ToLower(target)}
+	var addrs []net.IP
+	if ip := net.ParseIP(target); ip != nil {
+		addrs = append(addrs, ip)
+	} else if ips, err := net.LookupIP(target); err == nil {
+		for _, ip := range ips {
+			addrs = append(addrs, ip)
+			identities = append(identities, ip.String())
+		}
+	}
+
+	var applicable []baselinePolicy
+	for _, policy := range state.Policies {
+		if policy.appliesTo(identities, addrs, state.Tags) {
+			applicable = append(applicable, policy)
+		}
+	}
+	if len(applicable) == 0 {
+		return nil
+	}
+
+	var findings []vulnerabilityFinding
+	for _, ps := range ports {
+		if ps.Status != "open" {
+			continue
+		}
+		service := fmt.Sprintf("%d/tcp (%s)", ps.Port, ps.Service)
+
+		if policy, ok := forbiddingPolicy(applicable, ps.Port); ok {
+			findings = append(findings, vulnerabilityFinding{
+				Service:     service,
+				Severity:    policy.Severity,
+				Description: fmt.Sprintf("Policy violation: port is forbidden by baseline %q.", policy.Name),
+				Remediation: "Close the port or stop the service, or update the baseline if the exposure is intended.",
+				Source:      findingSourcePolicy,
+				Category:    checkPassive,
+			})
+			continue
+		}
+
+		if policy, ok := unexpectedExposure(applicable, ps); ok {
+			findings = append(findings, vulnerabilityFinding{
+				Service:     service,
+				Severity:    policy.Severity,
+				Description: fmt.Sprintf("Policy violation: port is not in the allowed list of baseline %q.", policy.Name),
+				Remediation: "Restrict the service to the expected exposure, or add it to the baseline if intended.",
+				Source:      findingSourcePolicy,
+				Category:    checkPassive,
+			})
+		}
+	}
+
+	return findings
+}
+
+func (p baselinePolicy) appliesTo(identities []string, addrs []net.IP, tags map[string][]string) bool {
+	switch p.Scope {
+	case scopeHost:
+		for _, id := range identities {
+			if strings.EqualFold(id, p.Match) {
+				return true
+			}
+		}
+	case scopeSubnet:
+		_, network, err := net.ParseCIDR(p.Match)
+		if err != nil {
+			return false
+		}
+		for _, ip := range addrs {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	case scopeTag:
+		for _, id := range identities {
+			for _, tag := range tags[strings.ToLower(id)] {
+				if strings.EqualFold(tag, p.Match) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func forbiddingPolicy(policies []baselinePolicy, port int) (baselinePolicy, bool) {
+	for _, policy := range policies {
+		for _, forbidden := range policy.ForbiddenPorts {
+			if forbidden == port {
+				return policy, true
+			}
+		}
+	}
+	return baselinePolicy{}, false
+}
+
+// unexpectedExposure reports the first policy with an allow-list when no
+// applicable policy allows the port.
+func unexpectedExposure(policies []baselinePolicy, ps portStatus) (baselinePolicy, bool) {
+	var restricting *baselinePolicy
+	for i, policy := range policies {
+		if len(policy.AllowedPorts) == 0 && len(policy.AllowedServices) == 0 {
+			continue
+		}
+		for _, allowed := range policy.AllowedPorts {
+			if allowed == ps.Port {
+				return baselinePolicy{}, false
+			}
+		}
+		for _, allowed := range policy.AllowedServices {
+			if strings.EqualFold(allowed, ps.Service) {
+				return baselinePolicy{}, false
+			}
+		}
+		if restricting == nil {
+			restricting = &policies[i]
+		}
+	}
+	if restricting == nil {
+		return baselinePolicy{}, false
+	}
+	return *restricting, true
+}
+
+func parsePortList(input string) ([]int, []string, error) {
+	var (
+		ports    []int
+		services []string
+	)
+	for _, token := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if lo, hi, ok := strings.Cut(token, "-"); ok {
+			start, err1 := strconv.Atoi(lo)
+			end, err2 := strconv.Atoi(hi)
+			if err1 != nil || err2 != nil || start < 1 || end > 65535 || start > end {
+				return nil, nil, fmt.Errorf("invalid port range %q", token)
+			}
+			for p := start; p <= end; p++ {
+				ports = append(ports, p)
+			}
+			continue
+		}
+		if p, err := strconv.Atoi(token); err == nil {
+			if p < 1 || p > 65535 {
+				return nil, nil, fmt.Errorf("invalid port %q", token)
+			}
+			ports = append(ports, p)
+			continue
+		}
+		services = append(services, token)
+	}
+	sort.Ints(ports)
+	return ports, services, nil
+}
+
+func formatPortList(ports []int, services []string) string {
+	parts := make([]string, 0, len(ports)+len(services))
+	for _, p := range ports {
+		parts = append(parts, strconv.Itoa(p))
+	}
+	parts = append(parts, services...)
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ",")
+}
+
+type baselineModule struct {
+	content        fyne.CanvasObject
+	nameEntry      *widget.Entry
+	scopeSelect    *widget.Select
+	matchEntry     *widget.Entry
+	allowedEntry   *widget.Entry
+	forbiddenEntry *widget.Entry
+	severitySelect *widget.Select
+	tagHostEntry   *widget.Entry
+	tagsEntry      *widget.Entry
+	statusLabel    *widget.Label
+	policyList     *widget.List
+	tagList        *widget.List
+	deleteButton   *widget.Button
+	policies       []baselinePolicy
+	tagLines       []string
+	selected       int
+}
+
+func (m *baselineModule) Name() string {
+	return "Baselines"
+}
+
+func (m *baselineModule) Content() fyne.CanvasObject {
+	if m.content != nil {
+		return m.content
+	}
+
+	m.selected = -1
+
+	m.nameEntry = widget.NewEntry()
+	m.nameEntry.SetPlaceHolder("Baseline name (optional)")
+
+	m.scopeSelect = widget.NewSelect([]string{scopeHost, scopeSubnet, scopeTag}, nil)
+	m.scopeSelect.SetSelected(scopeHost)
+
+	m.matchEntry = widget.NewEntry()
+	m.matchEntry.SetPlaceHolder("Host, subnet or tag")
+
+	m.allowedEntry = widget.NewEntry()
+	m.allowedEntry.SetPlaceHolder("Allowed ports/services (22,443,HTTPS)")
+
+	m.forbiddenEntry = widget.NewEntry()
+	m.forbiddenEntry.SetPlaceHolder("Forbidden ports (23,6379)")
+
+	m.severitySelect = widget.NewSelect(severityLevels(), nil)
+	m.severitySelect.SetSelected("High")
+
+	addButton := widget.NewButton("Add Baseline", m.addPolicy)
+
+	rowHeight := m.matchEntry.MinSize().Height
+	policyRow := container.NewHBox(
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(170, rowHeight)), m.nameEntry),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(100, rowHeight)), m.scopeSelect),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(180, rowHeight)), m.matchEntry),
+		layout.NewSpacer(),
+	)
+	portsRow := container.NewHBox(
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(260, rowHeight)), m.allowedEntry),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(190, rowHeight)), m.forbiddenEntry),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(110, rowHeight)), m.severitySelect),
+		addButton,
+		layout.NewSpacer(),
+	)
+
+	m.tagHostEntry = widget.NewEntry()
+	m.tagHostEntry.SetPlaceHolder("Host or IP")
+	m.tagsEntry = widget.NewEntry()
+	m.tagsEntry.SetPlaceHolder("Tags (web, dmz)")
+	tagButton := widget.NewButton("Set Tags", m.setTags)
+	tagRow := container.NewHBox(
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(180, rowHeight)), m.tagHostEntry),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(220, rowHeight)), m.tagsEntry),
+		tagButton,
+		layout.NewSpacer(),
+	)
+
+	m.statusLabel = widget.NewLabel("Scanner results are checked against every matching baseline.")
+
+	m.policyList = widget.NewList(
+		func() int { return len(m.policies) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i int, obj fyne.CanvasObject) {
+			p := m.policies[i]
+			obj.(*widget.Label).SetText(fmt.Sprintf("%-20s %-6s %-18s allowed: %-20s forbidden: %-14s [%s]",
+				p.Name, p.Scope, p.Match, formatPortList(p.AllowedPorts, p.AllowedServices), formatPortList(p.ForbiddenPorts, nil), p.Severity))
+		},
+	)
+	m.policyList.OnSelected = func(id widget.ListItemID) {
+		m.selected = id
+		m.deleteButton.Enable()
+	}
+	m.policyList.OnUnselected = func(widget.ListItemID) {
+		m.selected = -1
+		m.deleteButton.Disable()
+	}
+	policyScroll := container.NewVScroll(m.policyList)
+	policyScroll.SetMinSize(fyne.NewSize(0, 150))
+
+	m.deleteButton = widget.NewButton("Delete", m.deleteSelected)
+	m.deleteButton.Disable()
+
+	m.tagList = widget.NewList(
+		func() int { return len(m.tagLines) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i int, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(m.tagLines[i])
+		},
+	)
+	tagScroll := container.NewVScroll(m.tagList)
+	tagScroll.SetMinSize(fyne.NewSize(0, 90))
+
+	m.content = container.NewVBox(
+		widget.NewLabelWithStyle("Baselines", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Define the ports each host, subnet or tag is expected to expose."),
+		policyRow,
+		portsRow,
+		m.statusLabel,
+		widget.NewCard("Baseline Policies", "", container.NewVBox(policyScroll, container.NewHBox(m.deleteButton, layout.NewSpacer()))),
+		widget.NewCard("Host Tags", "", container.NewVBox(tagRow, tagScroll)),
+	)
+
+	defaultBaselines.subscribe(func() { m.queueOnMain(m.reload) })
+	m.reload()
+
+	return m.content
+}
+
+func (m *baselineModule) addPolicy() {
+	allowedPorts, allowedServices, err := parsePortList(m.allowedEntry.Text)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Allowed ports: %v", err))
+		return
+	}
+	forbiddenPorts, forbiddenServices, err := parsePortList(m.forbiddenEntry.Text)
+	if err != nil || len(forbiddenServices) > 0 {
+		m.setStatus("Forbidden ports must be port numbers or ranges.")
+		return
+	}
+
+	policy := baselinePolicy{
+		Name:            strings.TrimSpace(m.nameEntry.Text),
+		Scope:           m.scopeSelect.Selected,
+		Match:           m.matchEntry.Text,
+		AllowedPorts:    allowedPorts,
+		AllowedServices: allowedServices,
+		ForbiddenPorts:  forbiddenPorts,
+		Severity:        m.severitySelect.Selected,
+	}
+	if err := defaultBaselines.addPolicy(policy); err != nil {
+		m.setStatus(fmt.Sprintf("Unable to add baseline: %v", err))
+		return
+	}
+	m.nameEntry.SetText("")
+	m.matchEntry.SetText("")
+	m.allowedEntry.SetText("")
+	m.forbiddenEntry.SetText("")
+	m.setStatus("Baseline added.")
+}
+
+func (m *baselineModule) setTags() {
+	host := strings.TrimSpace(m.tagHostEntry.Text)
+	if host == "" {
+		m.setStatus("Enter a host to tag.")
+		return
+	}
+	var tags []string
+	for _, tag := range strings.Split(m.tagsEntry.Text, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	defaultBaselines.setTags(host, tags)
+	m.tagHostEntry.SetText("")
+	m.tagsEntry.SetText("")
+}
+
+func (m *baselineModule) deleteSelected() {
+	if m.selected < 0 || m.selected >= len(m.policies) {
+		return
+	}
+	defaultBaselines.removePolicy(m.policies[m.selected].ID)
+	m.policyList.UnselectAll()
+}
+
+func (m *baselineModule) reload() {
+	state := defaultBaselines.snapshot()
+	m.policies = state.Policies
+	m.tagLines = m.tagLines[:0]
+	for host, tags := range state.Tags {
+		m.tagLines = append(m.tagLines, fmt.Sprintf("%-24s %s", host, strings.Join(tags, ", ")))
+	}
+	sort.Strings(m.tagLines)
+	m.policyList.Refresh()
+	m.tagList.Refresh()
+}
+
+func (m *baselineModule) setStatus(text string) {
+	if m.statusLabel != nil {
+		m.statusLabel.SetText(text)
+	}
+}
+
+func (m *baselineModule) queueOnMain(fn func()) {
+	if app := fyne.CurrentApp(); app != nil {
+		if drv := app.Driver(); drv != nil {
+			if runner, ok := drv.(interface{ RunOnMain(func()) }); ok {
+				runner.RunOnMain(fn)
+				return
+			}
+		}
+	}
+	fn()
+}
@@ -0,0 +1,299 @@
+package modules
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+const checkpointFile = "checkpoints.json"
+
+const checkpointInterval = 5 * time.Second
+
+// runCheckpoint is the saved progress of an unfinished run. Record holds the
+// results gathered so far. The mapper continues after Cursor, the last address
+// it handled, with Done hosts already probed; the vulnerability scanner skips
+// its first Done rules.
+type runCheckpoint struct {
+	jobSpec
+	Record scanRecord `json:"record"`
+	Cursor string     `json:"cursor,omitempty"`
+	Done   int        `json:"done,omitempty"`
+	Saved  time.Time  `json:"saved"`
+}
+
+func (cp runCheckpoint) String() string {
+	return cp.Saved.Format("2006-01-02 15:04") + "  " + cp.Module + "  " + cp.Record.Target + "  " + cp.Record.describe()
+}
+
+// priorPort returns the status of a port the run already checked.
+func (cp runCheckpoint) priorPort(host string, port int) (portStatus, bool) {
+	for _, ps := range cp.Record.Ports {
+		if ps.Host == host && ps.Port == port {
+			ps.Host = ""
+			return ps, true
+		}
+	}
+	return portStatus{}, false
+}
+
+type runControlKey struct{}
+
+// runControl pauses a run and checkpoints its progress. The scan loops find
+// it in their context; a nil control never pauses and saves nothing.
+type runControl struct {
+	mu       sync.Mutex
+	tracking bool
+	cp       runCheckpoint
+	prior    runCheckpoint
+	saved    time.Time
+	resume   chan struct{}
+	cancel   context.CancelCauseFunc
+}
+
+func newRunControl() *runControl {
+	return &runControl{}
+}
+
+// withRunControl also lets the control cancel the run, which is how shutdown
+// stops runs with errShutdown.
+func withRunControl(ctx context.Context, rc *runControl) context.Context {
+	ctx, cancel := context.WithCancelCause(ctx)
+	rc.mu.Lock()
+	rc.cancel = cancel
+	rc.mu.Unlock()
+	return context.WithValue(ctx, runControlKey{}, rc)
+}
+
+func runControlFrom(ctx context.Context) *runControl {
+	rc, _ := ctx.Value(runControlKey{}).(*runControl)
+	return rc
+}
+
+// track starts checkpointing a run. When prior is set the run continues from
+// it, and the scan loops skip what it already covers.
+func (rc *runControl) track(spec jobSpec, record scanRecord, prior *runCheckpoint) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.tracking = true
+	defaultCheckpoints.setActive(record.ID, true)
+	activeRuns.add(rc)
+	rc.cp = runCheckpoint{jobSpec: spec, Record: record}
+	if prior != nil {
+		rc.prior = *prior
+		rc.cp.Record.Ports = prior.Record.Ports
+		rc.cp.Record.Devices = prior.Record.Devices
+		rc.cp.Record.Findings = prior.Record.Findings
+		rc.cp.Record.Skipped = prior.Record.Skipped
+		rc.cp.Cursor = prior.Cursor
+		rc.cp.Done = prior.Done
+	}
+	rc.saveLocked()
+}
+
+func (rc *runControl) resumed() runCheckpoint {
+	if rc == nil {
+		return runCheckpoint{}
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.prior
+}
+
+// progress records a step of the run and saves a checkpoint when the last
+// one is older than checkpointInterval.
+func (rc *runControl) progress(fn func(cp *runCheckpoint)) {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.tracking {
+		return
+	}
+	fn(&rc.cp)
+	if time.Since(rc.saved) >= checkpointInterval {
+		rc.saveLocked()
+	}
+}
+
+func (rc *runControl) saveLocked() {
+	rc.saved = time.Now()
+	rc.cp.Saved = rc.saved
+	defaultCheckpoints.put(rc.cp)
+}
+
+// finish drops the checkpoint of a completed or failed run. Stopped and
+// incomplete runs keep theirs so they can be resumed later.
+func (rc *runControl) finish(record scanRecord) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if !rc.tracking {
+		return
+	}
+	rc.tracking = false
+	defaultCheckpoints.setActive(rc.cp.Record.ID, false)
+	activeRuns.remove(rc)
+	if record.Status == runStopped || record.Status == runIncomplete {
+		rc.saveLocked()
+		return
+	}
+	defaultCheckpoints.remove(rc.cp.Record.ID)
+}
+
+func (rc *runControl) stop(cause error) {
+	rc.mu.Lock()
+	cancel := rc.cancel
+	rc.mu.Unlock()
+	if cancel != nil {
+		cancel(cause)
+	}
+}
+
+func (rc *runControl) pause() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.resume != nil {
+		return
+	}
+	rc.resume = make(chan struct{})
+	if rc.tracking {
+		rc.saveLocked()
+	}
+}
+
+func (rc *runControl) unpause() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.resume != nil {
+		close(rc.resume)
+		rc.resume = nil
+	}
+}
+
+func (rc *runControl) paused() bool {
+	if rc == nil {
+		return false
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.resume != nil
+}
+
+// wait blocks while the run is paused. It reports false once ctx is done.
+func (rc *runControl) wait(ctx context.Context) bool {
+	var resume chan struct{}
+	if rc != nil {
+		rc.mu.Lock()
+		resume = rc.resume
+		rc.mu.Unlock()
+	}
+	if resume != nil {
+		select {
+		case <-ctx.Done():
+		case <-resume:
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+type checkpointStore struct {
+	mu        sync.Mutex
+	loadOnce  sync.Once
+	items     []runCheckpoint
+	active    map[string]bool
+	listeners []func()
+}
+
+var defaultCheckpoints = &checkpointStore{active: map[string]bool{}}
+
+func (s *checkpointStore) load() {
+	s.loadOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := loadJSON(checkpointFile, &s.items); err != nil {
+			log.Printf("checkpoints: %v", err)
+		}
+	})
+}
+
+// interrupted lists the checkpoints of runs that are not in progress.
+func (s *checkpointStore) interrupted() []runCheckpoint {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []runCheckpoint
+	for _, cp := range s.items {
+		if !s.active[cp.Record.ID] {
+			items = append(items, cp)
+		}
+	}
+	return items
+}
+
+func (s *checkpointStore) setActive(id string, active bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if active {
+		s.active[id] = true
+	} else {
+		delete(s.active, id)
+	}
+}
+
+func (s *checkpointStore) put(cp runCheckpoint) {
+	s.load()
+	s.mu.Lock()
+	replaced := false
+	for i := range s.items {
+		if s.items[i].Record.ID == cp.Record.ID {
+			s.items[i] = cp
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		s.items = append(s.items, cp)
+	}
+	s.saveLocked()
+	listeners := append([]func(){}, s.listeners...)
+	s.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+func (s *checkpointStore) remove(id string) {
+	s.load()
+	s.mu.Lock()
+	for i := range s.items {
+		if s.items[i].Record.ID == id {
+			s.items = append(s.items[:i], s.items[i+1:]...)
+			break
+		}
+	}
+	s.saveLocked()
+	listeners := append([]func(){}, s.listeners...)
+	s.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+func (s *checkpointStore) saveLocked() {
+	if err := saveJSON(checkpointFile, s.items); err != nil {
+		log.Printf("checkpoints: %v", err)
+	}
+}
+
+func (s *checkpointStore) subscribe(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
@@ -0,0 +1,67 @@
+package modules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * foo *",
+		"* * * * 8",
+		"*/0 * * * *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"* * * * *", "2026-03-04 10:15", "2026-03-04 10:16"},
+		{"@hourly", "2026-03-04 10:15", "2026-03-04 11:00"},
+		{"@daily", "2026-12-31 23:59", "2027-01-01 00:00"},
+		{"*/15 9-17 * * mon-fri", "2026-10-16 17:50", "2026-10-19 09:00"},
+		{"30 2 * * 7", "2026-10-18 03:00", "2026-10-25 02:30"},
+		{"0 0 29 feb *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		{"0 12 13 * fri", "2026-11-01 00:00", "2026-11-06 12:00"},
+		{"0 12 1,15 * *", "2026-11-01 12:00", "2026-11-15 12:00"},
+		{"0 0 31 apr *", "2026-01-01 00:00", ""},
+	}
+	for _, tt := range tests {
+		sched, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		got := sched.next(at(tt.from))
+		if tt.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%q after %s = %s, want never", tt.expr, tt.from, got)
+			}
+			continue
+		}
+		if want := at(tt.want); !got.Equal(want) {
+			t.Errorf("%q after %s = %s, want %s", tt.expr, tt.from, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package modules
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	discoveryBoth = "icmp+tcp"
+	discoveryARP  = "arp"
+	discoveryICMP = "icmp"
+	discoveryTCP  = "tcp"
+)
+
+const icmpTimeout = 300 * time.Millisecond
+
+var (
+	errICMPProxied = errors.New("ICMP cannot be sent through the proxy chain")
+	errARPProxied  = errors.New("ARP sweeps bypass the proxy chain")
+)
+
+var discoveryLabels = map[string]string{
+	discoveryBoth: "ICMP + TCP",
+	discoveryARP:  "ARP sweep",
+	discoveryICMP: "ICMP only",
+	discoveryTCP:  "TCP only",
+}
+
+func discoveryOptions() []string {
+	return []string{discoveryLabels[discoveryBoth], discoveryLabels[discoveryARP], discoveryLabels[discoveryICMP], discoveryLabels[discoveryTCP]}
+}
+
+// parseDiscovery accepts a strategy or its label. The empty strategy is
+// ICMP + TCP.
+func parseDiscovery(input string) (string, error) {
+	if input == "" {
+		return discoveryBoth, nil
+	}
+	for strategy, label := range discoveryLabels {
+		if input == strategy || input == label {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown discovery strategy %q", input)
+}
+
+// discoveryProbes is the worst case number of probes per address.
+func discoveryProbes(strategy string) int {
+	switch strategy {
+	case discoveryICMP:
+		return 2
+	case discoveryTCP:
+		return len(discoveryPorts())
+	case discoveryARP:
+		return 3 + len(discoveryPorts())
+	default:
+		return 2 + len(discoveryPorts())
+	}
+}
+
+// hostDiscovery decides whether an address is alive. The ARP sweep settles
+// on-link addresses, and the ICMP and TCP checks the rest. What the checks
+// observe is kept in a hostProbe for fingerprinting. When ARP or ICMP
+// cannot be used, because sockets are not permitted or a proxy is active, the
+// run continues without them. Addresses are probed concurrently; the ARP
+// results and announcements only change between batches.
+type hostDiscovery struct {
+	nw          probeNetwork
+	strategy    string
+	arp         map[string]string
+	arpErr      error
+	mu          sync.Mutex
+	icmpErr     error
+	announced   map[string]*announcement
+	announceErr error
+}
+
+func newHostDiscovery(nw probeNetwork, strategy string) *hostDiscovery {
+	if strategy == "" {
+		strategy = discoveryBoth
+	}
+	return &hostDiscovery{nw: nw, strategy: strategy}
+}
+
+// listen collects the devices on the subnet that announce themselves. TCP
+// only discovery sends no multicast.
+func (d *hostDiscovery) listen(ctx context.Context, ipnet *net.IPNet, allowed func(net.IP) bool) {
+	if d.strategy == discoveryTCP {
+		return
+	}
+	found, err := d.nw.announcements(ctx, ipnet, allowed)
+	if err != nil {
+		d.announceErr = err
+		log.Printf("discovery: no announcements: %v", err)
+		return
+	}
+	d.announced = found
+}
+
+// sweep runs the ARP sweep over the next addresses the mapper probes.
+func (d *hostDiscovery) sweep(ctx context.Context, targets []net.IP) {
+	d.arp = nil
+	if d.strategy != discoveryARP || d.arpErr != nil || len(targets) == 0 {
+		return
+	}
+	results, err := d.nw.arpSweep(ctx, targets)
+	if err != nil {
+		d.arpErr = err
+		log.Printf("discovery: ARP unavailable, using ICMP and TCP: %v", err)
+		return
+	}
+	d.arp = results
+}
+
+// probe reports whether ip is alive, along with what was learned about it.
+func (d *hostDiscovery) probe(ip net.IP) (*hostProbe, bool) {
+	p := newHostProbe(d.nw, ip)
+	if d.announced[ip.String()] != nil {
+		return p, true
+	}
+	if mac, ok := d.arp[ip.String()]; ok {
+		return p, mac != ""
+	}
+	if d.strategy != discoveryTCP && d.icmpAvailable() {
+		ttl, err := d.nw.ping(ip, icmpTimeout)
+		switch {
+		case err != nil:
+			d.icmpFailed(err)
+		case ttl != 0:
+			p.ttl = ttl
+			return p, true
+		case d.strategy == discoveryICMP:
+			return p, false
+		}
+	}
+	for _, port := range discoveryPorts() {
+		if p.tryPort(port) {
+			return p, true
+		}
+	}
+	return p, false
+}
+
+func (d *hostDiscovery) icmpAvailable() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.icmpErr == nil
+}
+
+func (d *hostDiscovery) icmpFailed(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.icmpErr == nil {
+		d.icmpErr = err
+		log.Printf("discovery: ICMP unavailable, using TCP: %v", err)
+	}
+}
+
+// mac prefers the address from the ARP reply over the neighbor table.
+func (d *hostDiscovery) mac(ip net.IP) string {
+	if mac := d.arp[ip.String()]; mac != "" {
+		return mac
+	}
+	return d.nw.hardwareAddr(ip)
+}
+
+// note records the strategy of the run, and the fallbacks if there were any.
+func (d *hostDiscovery) note(record *scanRecord) {
+	text := discoveryLabels[d.strategy]
+	if d.arpErr != nil {
+		text += fmt.Sprintf(", ARP unavailable (%v)", d.arpErr)
+	}
+	if d.icmpErr != nil {
+		text += fmt.Sprintf(", fell back to TCP (%v)", d.icmpErr)
+	}
+	record.Settings["discovery"] = text
+	switch {
+	case d.announceErr != nil:
+		record.Settings["announcements"] = fmt.Sprintf("unavailable (%v)", d.announceErr)
+	case d.announced != nil:
+		record.Settings["announcements"] = fmt.Sprintf("%d device(s)", len(d.announced))
+	}
+}
+
+// pingHost sends an ICMP echo request, then a timestamp request, which some
+// hosts that drop echo still answer. Unprivileged ICMP sockets are tried
+// first; the kernel only lets them send echo requests, so timestamp requests
+// need a raw socket. It returns the TTL of the reply, -1 when the socket does
+// not report it, or 0 when there was no reply.
+func pingHost(src *sourceBinding, ip net.IP, timeout time.Duration) (int, error) {
+	if defaultProxy.current().active() {
+		return 0, errICMPProxied
+	}
+	release := defaultGuard.acquireSocket()
+	defer release()
+
+	conn, raw, err := listenICMP(src, ip)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if p := conn.IPv4PacketConn(); p != nil {
+		p.SetControlMessage(ipv4.FlagTTL, true)
+	} else if p := conn.IPv6PacketConn(); p != nil {
+		p.SetControlMessage(ipv6.FlagHopLimit, true)
+	}
+
+	echoType := icmp.Type(ipv4.ICMPTypeEcho)
+	if ip.To4() == nil {
+		echoType = ipv6.ICMPTypeEchoRequest
+	}
+	if ttl, err := icmpExchange(conn, raw, ip, echoType, timeout); ttl != 0 || err != nil {
+		return ttl, err
+	}
+	if raw && ip.To4() != nil {
+		return icmpExchange(conn, raw, ip, ipv4.ICMPTypeTimestamp, timeout)
+	}
+	return 0, nil
+}
+
+func listenICMP(src *sourceBinding, ip net.IP) (*icmp.PacketConn, bool, error) {
+	network, rawNetwork, address := "udp4", "ip4:icmp", "0.0.0.0"
+	if ip.To4() == nil {
+		network, rawNetwork, address = "udp6", "ip6:ipv6-icmp", "::"
+	}
+	if src != nil {
+		address = src.ip.String()
+	}
+	conn, err := icmp.ListenPacket(network, address)
+	if err == nil {
+		return conn, false, nil
+	}
+	conn, rawErr := icmp.ListenPacket(rawNetwork, address)
+	if rawErr != nil {
+		return nil, false, fmt.Errorf("no ICMP socket: %v; raw socket: %v", err, rawErr)
+	}
+	return conn, true, nil
+}
+
+func icmpExchange(conn *icmp.PacketConn, raw bool, ip net.IP, request icmp.Type, timeout time.Duration) (int, error) {
+	id, seq := rand.Intn(1<<16), rand.Intn(1<<16)
+	proto, reply := 1, icmp.Type(ipv4.ICMPTypeEchoReply)
+	var body icmp.MessageBody = &icmp.Echo{ID: id, Seq: seq, Data: []byte("rodent")}
+	switch request {
+	case ipv6.ICMPTypeEchoRequest:
+		proto, reply = 58, ipv6.ICMPTypeEchoReply
+	case ipv4.ICMPTypeTimestamp:
+		reply = ipv4.ICMPTypeTimestampReply
+		data := make([]byte, 16)
+		binary.BigEndian.PutUint16(data[0:], uint16(id))
+		binary.BigEndian.PutUint16(data[2:], uint16(seq))
+		body = &icmp.RawBody{Data: data}
+	}
+	msg, err := (&icmp.Message{Type: request, Body: body}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var dst net.Addr = &net.UDPAddr{IP: ip}
+	if raw {
+		dst = &net.IPAddr{IP: ip}
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.WriteTo(msg, dst); err != nil {
+		return 0, fmt.Errorf("send ICMP: %w", err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, ttl, err := readICMP(conn, buf)
+		if err != nil {
+			return 0, nil
+		}
+		if !peerIP(peer).Equal(ip) {
+			continue
+		}
+		m, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || m.Type != reply {
+			continue
+		}
+		gotID, gotSeq := -1, -1
+		switch b := m.Body.(type) {
+		case *icmp.Echo:
+			gotID, gotSeq = b.ID, b.Seq
+		case *icmp.RawBody:
+			if len(b.Data) >= 4 {
+				gotID, gotSeq = int(binary.BigEndian.Uint16(b.Data)), int(binary.BigEndian.Uint16(b.Data[2:]))
+			}
+		}
+		// Unprivileged sockets rewrite the identifier; the kernel already
+		// delivers only replies to this socket.
+		if gotSeq == seq && (!raw || gotID == id) {
+			if ttl == 0 {
+				return -1, nil
+			}
+			return ttl, nil
+		}
+	}
+}
+
+// readICMP reads a message along with the TTL or hop limit it arrived with,
+// when the socket reports it.
+func readICMP(conn *icmp.PacketConn, buf []byte) (int, net.Addr, int, error) {
+	if p := conn.IPv4PacketConn(); p != nil {
+		n, cm, peer, err := p.ReadFrom(buf)
+		if cm != nil {
+			return n, peer, cm.TTL, err
+		}
+		return n, peer, 0, err
+	}
+	if p := conn.IPv6PacketConn(); p != nil {
+		n, cm, peer, err := p.ReadFrom(buf)
+		if cm != nil {
+			return n, peer, cm.HopLimit, err
+		}
+		return n, peer, 0, err
+	}
+	n, peer, err := conn.ReadFrom(buf)
+	return n, peer, 0, err
+}
+
+func peerIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package modules
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const exclusionsFile = "exclusions.json"
+
+const (
+	statusExcluded   = "skipped (excluded)"
+	statusOutOfScope = "skipped (out of scope)"
+)
+
+type skippedHost struct {
+	Host   string `json:"host"`
+	Status string `json:"status"`
+	Entry  string `json:"entry,omitempty"`
+}
+
+func (s skippedHost) reason() string {
+	if s.Entry != "" {
+		return fmt.Sprintf("%s by %s", s.Status, s.Entry)
+	}
+	return s.Status
+}
+
+func (s skippedHost) String() string {
+	return fmt.Sprintf("%s %s", s.Host, s.reason())
+}
+
+// exclusionList names hosts that must never be probed, even inside an
+// in-scope subnet. Files are re-read on every run so a shared list stays
+// authoritative.
+type exclusionList struct {
+	Entries []string `json:"entries"`
+	Files   []string `json:"files,omitempty"`
+}
+
+func (l exclusionList) empty() bool {
+	return len(l.Entries) == 0 && len(l.Files) == 0
+}
+
+func (l exclusionList) parse() (addressSet, error) {
+	entries := append([]string(nil), l.Entries...)
+	for _, path := range l.Files {
+		lines, err := readExclusionFile(path)
+		if err != nil {
+			return addressSet{}, err
+		}
+		entries = append(entries, lines...)
+	}
+	return parseAddressSet(entries)
+}
+
+// compile parses the list and resolves its hostnames once, so that checking
+// the addresses of a run is a set lookup rather than a reverse lookup per
+// address. Wildcard patterns cannot be resolved and only match targets given
+// by name.
+func (l exclusionList) compile() (addressSet, error) {
+	set, err := l.parse()
+	if err != nil || len(set.hosts) == 0 {
+		return set, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	set.named = map[string]string{}
+	for _, name := range set.hosts {
+		if strings.HasPrefix(name, "*.") {
+			continue
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			addrs, _ := net.DefaultResolver.LookupIPAddr(ctx, name)
+			mu.Lock()
+			for _, addr := range addrs {
+				set.named[addr.IP.String()] = name
+			}
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return set, nil
+}
+
+func readExclusionFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("exclusion file: %w", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		lines = append(lines, splitLines(line)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("exclusion file %s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// excludedBy returns the exclusion entry matching host, or "" when the host
+// may be probed. Addresses are matched against the entries and the addresses
+// compile resolved their hostnames to, so an entry such as
+// ceo-laptop.corp.example also protects its address.
+func (s addressSet) excludedBy(host string) string {
+	if s.empty() {
+		return ""
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return s.excludedIP(ip)
+	}
+
+	if s.matchesHost(host) {
+		return host
+	}
+	ips, _ := net.LookupIP(host)
+	for _, ip := range ips {
+		if entry := s.excludedIP(ip); entry != "" {
+			return entry
+		}
+	}
+	return ""
+}
+
+func (s addressSet) excludedIP(ip net.IP) string {
+	if entry := s.matchingEntry(ip); entry != "" {
+		return entry
+	}
+	return s.named[ip.String()]
+}
+
+// partitionExcluded splits hosts into the ones to probe and the ones the
+// exclusion list skips.
+func partitionExcluded(hosts []string) ([]string, []skippedHost, error) {
+	excluded, err := defaultExclusions.current().compile()
+	if err != nil {
+		return nil, nil, err
+	}
+	var probe []string
+	var skipped []skippedHost
+	for _, host := range hosts {
+		if entry := excluded.excludedBy(host); entry != "" {
+			skipped = append(skipped, skippedHost{Host: host, Status: statusExcluded, Entry: entry})
+			continue
+		}
+		probe = append(probe, host)
+	}
+	return probe, skipped, nil
+}
+
+func withoutSkipped(hosts []string, skipped []skippedHost) []string {
+	if len(skipped) == 0 {
+		return hosts
+	}
+	drop := map[string]bool{}
+	for _, skip := range skipped {
+		drop[skip.Host] = true
+	}
+	var out []string
+	for _, host := range hosts {
+		if !drop[host] {
+			out = append(out, host)
+		}
+	}
+	return out
+}
+
+type exclusionStore struct {
+	mu        sync.Mutex
+	loadOnce  sync.Once
+	list      exclusionList
+	listeners []func()
+}
+
+var defaultExclusions = &exclusionStore{}
+
+func (s *exclusionStore) current() exclusionList {
+	s.loadOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := loadJSON(exclusionsFile, &s.list); err != nil {
+			log.Printf("exclusions: %v", err)
+		}
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list
+}
+
+func (s *exclusionStore) set(list exclusionList) error {
+	if _, err := list.parse(); err != nil {
+		return err
+	}
+	s.current()
+	s.mu.Lock()
+	s.list = list
+	err := saveJSON(exclusionsFile, list)
+	listeners := append([]func(){}, s.listeners...)
+	s.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+	return err
+}
+
+func (s *exclusionStore) subscribe(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
@@ -0,0 +1,32 @@
+package modules
+
+import (
+	"net"
+	"testing"
+)
+
+// localhost resolves from the hosts file, so compile needs no DNS server.
+func TestExclusionCompileResolvesNames(t *testing.T) {
+	set, err := exclusionList{Entries: []string{"10.0.0.5", "localhost", "*.example.com"}}.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"10.0.0.5", "10.0.0.5/32"},
+		{"10.0.0.6", ""},
+		{"127.0.0.1", "localhost"},
+		{"localhost", "localhost"},
+		{"www.example.com", "www.example.com"},
+	}
+	for _, tt := range tests {
+		if got := set.excludedBy(tt.host); got != tt.want {
+			t.Errorf("excludedBy(%s) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+	if got := set.excludedIP(net.ParseIP("127.0.0.1")); got != "localhost" {
+		t.Errorf("excludedIP(127.0.0.1) = %q, want localhost", got)
+	}
+}
@@ -0,0 +1,362 @@
+package modules
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const osSignatureFile = "os_signatures.json"
+
+const (
+	portTimeout   = 150 * time.Millisecond
+	bannerTimeout = 300 * time.Millisecond
+	minConfidence = 10
+)
+
+// Evidence weights. A service banner naming the system is the strongest
+// sign, open ports the weakest.
+const (
+	weightTTL     = 20
+	weightWindow  = 25
+	weightOptions = 30
+	weightBanner  = 40
+	weightPorts   = 10
+	weightTotal   = weightTTL + weightWindow + weightOptions + weightBanner + weightPorts
+)
+
+//go:embed os_signatures.json
+var bundledSignatures []byte
+
+// bannerPorts are the services that identify themselves when asked.
+var bannerPorts = map[int]bool{21: true, 22: true, 23: true, 25: true, 80: true, 110: true, 143: true, 8080: true}
+
+// tcpStack is what a SYN-ACK reveals about the stack that sent it. Options
+// are one letter per option in the order they were sent: M (MSS), N (NOP),
+// W (window scale), S (SACK permitted), T (timestamps) and E (end of list).
+type tcpStack struct {
+	TTL     int
+	Window  int
+	Options string
+}
+
+// parseSYNACK reads the SYN-ACK from srcPort to dstPort out of a TCP segment.
+func parseSYNACK(segment []byte, srcPort, dstPort int) *tcpStack {
+	if len(segment) < 20 {
+		return nil
+	}
+	if int(binary.BigEndian.Uint16(segment)) != srcPort || int(binary.BigEndian.Uint16(segment[2:])) != dstPort {
+		return nil
+	}
+	if segment[13]&0x12 != 0x12 {
+		return nil
+	}
+	end := int(segment[12]>>4) * 4
+	if end < 20 || end > len(segment) {
+		return nil
+	}
+	stack := &tcpStack{Window: int(binary.BigEndian.Uint16(segment[14:]))}
+	var options strings.Builder
+	for i := 20; i < end; {
+		kind := segment[i]
+		switch kind {
+		case 0:
+			options.WriteByte('E')
+			i = end
+			continue
+		case 1:
+			options.WriteByte('N')
+			i++
+			continue
+		case 2:
+			options.WriteByte('M')
+		case 3:
+			options.WriteByte('W')
+		case 4:
+			options.WriteByte('S')
+		case 8:
+			options.WriteByte('T')
+		}
+		if i+1 >= end || segment[i+1] < 2 {
+			break
+		}
+		i += int(segment[i+1])
+	}
+	stack.Options = options.String()
+	return stack
+}
+
+// hostProbe collects what the mapper learns about a host, so no port is
+// dialed twice between discovery and fingerprinting.
+type hostProbe struct {
+	nw      probeNetwork
+	ip      net.IP
+	ttl     int
+	ports   map[int]bool
+	banners map[int]string
+	stack   *tcpStack
+}
+
+func newHostProbe(nw probeNetwork, ip net.IP) *hostProbe {
+	return &hostProbe{nw: nw, ip: ip, ports: map[int]bool{}, banners: map[int]string{}}
+}
+
+// tryPort reports whether port is open, dialing it the first time only.
+func (p *hostProbe) tryPort(port int) bool {
+	if open, ok := p.ports[port]; ok {
+		return open
+	}
+	conn, stack, err := p.nw.dialStack(net.JoinHostPort(p.ip.String(), strconv.Itoa(port)), portTimeout)
+	p.ports[port] = err == nil
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	if p.stack == nil && stack != nil {
+		p.stack = stack
+	}
+	if banner := readBanner(conn, port); banner != "" {
+		p.banners[port] = banner
+	}
+	return true
+}
+
+// readBanner returns the greeting of a service, or the Server header of a
+// web server.
+func readBanner(conn net.Conn, port int) string {
+	if !bannerPorts[port] {
+		return ""
+	}
+	conn.SetDeadline(time.Now().Add(bannerTimeout))
+	web := port == 80 || port == 8080
+	if web {
+		if _, err := conn.Write([]byte("HEAD / HTTP/1.0\r\n\r\n")); err != nil {
+			return ""
+		}
+	}
+	buf := make([]byte, 1024)
+	n, _ := conn.Read(buf)
+	text := string(buf[:n])
+	if !web {
+		line, _, _ := strings.Cut(text, "\n")
+		return cleanBanner(line)
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(key), "Server") {
+			return cleanBanner(value)
+		}
+	}
+	return ""
+}
+
+func cleanBanner(s string) string {
+	s = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, s))
+	if len(s) > 80 {
+		s = s[:80]
+	}
+	return s
+}
+
+// osSignature describes a system. Any field may be left out; a signature
+// only scores on what it declares. TTL is the initial TTL (64, 128 or 255),
+// Options the SYN-ACK option order as in tcpStack, Banners regular
+// expressions matched against service banners, and Ports services that
+// suggest the system when open. A closed port is no evidence against it.
+type osSignature struct {
+	Name    string   `json:"name"`
+	TTL     int      `json:"ttl,omitempty"`
+	Windows []int    `json:"windows,omitempty"`
+	Options string   `json:"options,omitempty"`
+	Banners []string `json:"banners,omitempty"`
+	Ports   []int    `json:"ports,omitempty"`
+	banners []*regexp.Regexp
+}
+
+// signatureDB holds the bundled signatures and those in os_signatures.json
+// in the data directory, which come first and replace bundled ones of the
+// same name.
+type signatureDB struct {
+	loadOnce   sync.Once
+	signatures []osSignature
+	ports      []int
+}
+
+var defaultSignatures = &signatureDB{}
+
+func (db *signatureDB) load() {
+	db.loadOnce.Do(func() {
+		var bundled, custom []osSignature
+		if err := json.Unmarshal(bundledSignatures, &bundled); err != nil {
+			log.Printf("os signatures: bundled: %v", err)
+		}
+		if err := loadJSON(osSignatureFile, &custom); err != nil {
+			log.Printf("os signatures: %v", err)
+		}
+		seen := map[string]bool{}
+		ports := map[int]bool{}
+		for _, port := range discoveryPorts() {
+			ports[port] = true
+		}
+		for _, sig := range append(custom, bundled...) {
+			key := strings.ToLower(sig.Name)
+			if key == "" || seen[key] {
+				continue
+			}
+			if err := sig.compile(); err != nil {
+				log.Printf("os signatures: %s: %v", sig.Name, err)
+				continue
+			}
+			seen[key] = true
+			db.signatures = append(db.signatures, sig)
+			for _, port := range sig.Ports {
+				ports[port] = true
+			}
+		}
+		for port := range ports {
+			db.ports = append(db.ports, port)
+		}
+		sort.Ints(db.ports)
+	})
+}
+
+func (sig *osSignature) compile() error {
+	for _, pattern := range sig.Banners {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		sig.banners = append(sig.banners, re)
+	}
+	return nil
+}
+
+func (sig osSignature) matchesBanner(banners map[int]string) bool {
+	for _, banner := range banners {
+		for _, re := range sig.banners {
+			if re.MatchString(banner) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// initialTTL rounds a received TTL up to the value the sender started from.
+func initialTTL(ttl int) int {
+	for _, initial := range []int{32, 64, 128} {
+		if ttl <= initial {
+			return initial
+		}
+	}
+	return 255
+}
+
+// osMatch is the best signature for a host, or the signatures tied for it.
+// Confidence is the share of the full fingerprint that matched, scaled down
+// by the evidence that contradicted it.
+type osMatch struct {
+	Name       string
+	Confidence int
+	Evidence   string
+}
+
+// fingerprint probes the ports the signatures care about and scores every
+// signature against what the host showed.
+func (p *hostProbe) fingerprint(db *signatureDB) osMatch {
+	db.load()
+	for _, port := range db.ports {
+		p.tryPort(port)
+	}
+	ttl := p.ttl
+	if p.stack != nil && p.stack.TTL > 0 {
+		ttl = p.stack.TTL
+	}
+	bannerKnown := false
+	for _, sig := range db.signatures {
+		bannerKnown = bannerKnown || sig.matchesBanner(p.banners)
+	}
+
+	best := osMatch{Name: "Unknown", Evidence: p.evidence(ttl)}
+	for _, sig := range db.signatures {
+		matched, possible := 0, 0
+		score := func(weight int, known, ok bool) {
+			if known {
+				possible += weight
+				if ok {
+					matched += weight
+				}
+			}
+		}
+		score(weightTTL, sig.TTL != 0 && ttl > 0, initialTTL(ttl) == sig.TTL)
+		if p.stack != nil {
+			score(weightWindow, len(sig.Windows) > 0 && p.stack.Window > 0, containsInt(sig.Windows, p.stack.Window))
+			score(weightOptions, sig.Options != "" && p.stack.Options != "", sig.Options == p.stack.Options)
+		}
+		score(weightBanner, len(sig.banners) > 0 && bannerKnown, sig.matchesBanner(p.banners))
+		open := false
+		for _, port := range sig.Ports {
+			open = open || p.ports[port]
+		}
+		score(weightPorts, open, true)
+		if possible == 0 {
+			continue
+		}
+		confidence := (100*matched*matched + weightTotal*possible/2) / (weightTotal * possible)
+		switch {
+		case confidence < minConfidence || confidence < best.Confidence:
+		case confidence == best.Confidence:
+			best.Name += " / " + sig.Name
+		default:
+			best.Name, best.Confidence = sig.Name, confidence
+		}
+	}
+	return best
+}
+
+func (p *hostProbe) evidence(ttl int) string {
+	var parts []string
+	if ttl > 0 {
+		parts = append(parts, fmt.Sprintf("TTL %d (%d)", ttl, initialTTL(ttl)))
+	}
+	if p.stack != nil {
+		if p.stack.Window > 0 {
+			parts = append(parts, fmt.Sprintf("window %d", p.stack.Window))
+		}
+		if p.stack.Options != "" {
+			parts = append(parts, "options "+p.stack.Options)
+		}
+	}
+	var ports []int
+	for port := range p.banners {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	for _, port := range ports {
+		parts = append(parts, fmt.Sprintf("%d/tcp %s", port, p.banners[port]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package modules
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func synack(srcPort, dstPort int, flags byte, window int, options []byte) []byte {
+	segment := make([]byte, 20, 20+len(options))
+	binary.BigEndian.PutUint16(segment, uint16(srcPort))
+	binary.BigEndian.PutUint16(segment[2:], uint16(dstPort))
+	segment[12] = byte((20+len(options))/4) << 4
+	segment[13] = flags
+	binary.BigEndian.PutUint16(segment[14:], uint16(window))
+	return append(segment, options...)
+}
+
+func TestParseSYNACK(t *testing.T) {
+	linux := []byte{2, 4, 5, 180, 4, 2, 8, 10, 0, 0, 0, 1, 0, 0, 0, 0, 1, 3, 3, 7}
+	windows := []byte{2, 4, 5, 180, 1, 3, 3, 8, 1, 1, 4, 2}
+	tests := []struct {
+		name    string
+		segment []byte
+		port    int
+		want    *tcpStack
+	}{
+		{"linux", synack(22, 40000, 0x12, 65160, linux), 22, &tcpStack{Window: 65160, Options: "MSTNW"}},
+		{"windows", synack(3389, 40000, 0x12, 64240, windows), 3389, &tcpStack{Window: 64240, Options: "MNWNNS"}},
+		{"no options", synack(80, 40000, 0x12, 8192, nil), 80, &tcpStack{Window: 8192}},
+		{"end of list", synack(80, 40000, 0x12, 8192, []byte{2, 4, 5, 180, 0, 0, 0, 0}), 80, &tcpStack{Window: 8192, Options: "ME"}},
+		{"wrong source port", synack(23, 40000, 0x12, 65160, linux), 22, nil},
+		{"wrong destination port", synack(22, 40001, 0x12, 65160, linux), 22, nil},
+		{"reset", synack(22, 40000, 0x14, 0, nil), 22, nil},
+		{"truncated", synack(22, 40000, 0x12, 65160, linux)[:30], 22, nil},
+		{"short", []byte{0, 22, 0x9c, 0x40}, 22, nil},
+	}
+	for _, tt := range tests {
+		got := parseSYNACK(tt.segment, tt.port, 40000)
+		switch {
+		case tt.want == nil && got != nil:
+			t.Errorf("%s: got %+v, want nil", tt.name, *got)
+		case tt.want != nil && (got == nil || *got != *tt.want):
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, *tt.want)
+		}
+	}
+}
+
+func TestInitialTTL(t *testing.T) {
+	for ttl, want := range map[int]int{1: 32, 32: 32, 57: 64, 64: 64, 113: 128, 128: 128, 200: 255, 255: 255} {
+		if got := initialTTL(ttl); got != want {
+			t.Errorf("initialTTL(%d) = %d, want %d", ttl, got, want)
+		}
+	}
+}
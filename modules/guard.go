@@ -0,0 +1,431 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const guardFile = "guard.json"
+
+// ackLargeRun is stored with the acknowledged address kinds when the
+// operator confirmed a run above the size thresholds.
+const ackLargeRun = "large run"
+
+var (
+	errMemoryLimit    = errors.New("memory limit reached")
+	errNoConfirmation = errors.New("no window to confirm the run in")
+)
+
+type guardSettings struct {
+	ConfirmProbes  int `json:"confirm_probes"`
+	ConfirmMinutes int `json:"confirm_minutes"`
+	MaxHosts       int `json:"max_hosts"`
+	MaxOpenSockets int `json:"max_open_sockets"`
+	MemoryLimitMB  int `json:"memory_limit_mb"`
+}
+
+func defaultGuardSettings() guardSettings {
+	return guardSettings{
+		ConfirmProbes:  10000,
+		ConfirmMinutes: 10,
+		MaxHosts:       65536,
+		MaxOpenSockets: 256,
+		MemoryLimitMB:  512,
+	}
+}
+
+func (g guardSettings) validate() error {
+	switch {
+	case g.ConfirmProbes <= 0:
+		return fmt.Errorf("the probe threshold must be positive")
+	case g.ConfirmMinutes <= 0:
+		return fmt.Errorf("the duration threshold must be positive")
+	case g.MaxHosts <= 0:
+		return fmt.Errorf("the host limit must be positive")
+	case g.MaxOpenSockets <= 0:
+		return fmt.Errorf("the socket limit must be positive")
+	case g.MemoryLimitMB < 64:
+		return fmt.Errorf("the memory limit must be at least 64 MB")
+	}
+	return nil
+}
+
+type runEstimate struct {
+	Hosts    uint64
+	PerHost  int
+	Probes   uint64
+	Duration time.Duration
+}
+
+func (e runEstimate) String() string {
+	return fmt.Sprintf("%d host(s) x %d probe(s) = %d probes, up to %s",
+		e.Hosts, e.PerHost, e.Probes, e.Duration.Round(time.Second))
+}
+
+func (g guardSettings) needsConfirmation(e runEstimate) bool {
+	return e.Probes > uint64(g.ConfirmProbes) || e.Duration > time.Duration(g.ConfirmMinutes)*time.Minute
+}
+
+func (g guardSettings) checkLimit(e runEstimate) error {
+	if e.Hosts > uint64(g.MaxHosts) {
+		return fmt.Errorf("%d hosts exceed the limit of %d hosts per run", e.Hosts, g.MaxHosts)
+	}
+	return nil
+}
+
+// estimateRun uses worst-case timeouts: every probe of an unresponsive
+// address waits for the full dial timeout.
+func estimateRun(spec jobSpec) (runEstimate, error) {
+	var e runEstimate
+	var timeout time.Duration
+	workers := 1
+	switch spec.Module {
+	case moduleScanner:
+		hosts, err := expandTargets(spec.Target)
+		if err != nil {
+			return e, err
+		}
+		e.Hosts = uint64(len(hosts))
+		e.PerHost = len(portCatalog())
+		timeout = 500 * time.Millisecond
+	case moduleMapper:
+		normalized, err := normalizeSubnet(spec.Target)
+		if err != nil {
+			return e, err
+		}
+		_, network, err := net.ParseCIDR(normalized)
+		if err != nil {
+			return e, err
+		}
+		ones, bits := network.Mask.Size()
+		e.Hosts = uint64(1) << uint(min(bits-ones, 48))
+		if bits == 32 && bits-ones > 1 {
+			e.Hosts -= 2
+		}
+		e.PerHost = discoveryProbes(spec.Discovery)
+		timeout = 150 * time.Millisecond
+		workers = mapConcurrency()
+	case moduleVulnerability:
+		allowed := allowedCategories(spec.Categories)
+		for _, rule := range vulnerabilityRules() {
+			if allowed[rule.Category] {
+				e.PerHost++
+			}
+		}
+		e.Hosts = 1
+		timeout = 500 * time.Millisecond
+	case moduleTraceroute:
+		hosts, err := expandTargets(spec.Target)
+		if err != nil {
+			return e, err
+		}
+		e.Hosts = uint64(len(hosts))
+		e.PerHost = traceMaxHops
+		timeout = traceTimeout
+		workers = traceWorkers
+	default:
+		return e, fmt.Errorf("unknown module %q", spec.Module)
+	}
+	e.Probes = e.Hosts * uint64(e.PerHost)
+	e.Duration = math.MaxInt64
+	if e.Probes < uint64(math.MaxInt64/int64(timeout)) {
+		e.Duration = time.Duration(e.Probes) * timeout / time.Duration(workers)
+	}
+	return e, nil
+}
+
+// checkEstimate refuses runs over the host limit, and runs over the
+// confirmation thresholds that were not acknowledged.
+func checkEstimate(spec jobSpec, record *scanRecord) error {
+	est, err := estimateRun(spec)
+	if err != nil {
+		return err
+	}
+	record.Settings["estimate"] = est.String()
+	guard := defaultGuard.current()
+	reason := ""
+	if err := guard.checkLimit(est); err != nil {
+		reason = err.Error()
+	} else if guard.needsConfirmation(est) && !containsString(spec.Acknowledged, ackLargeRun) {
+		reason = fmt.Sprintf("%s is above the confirmation thresholds and was not acknowledged", est)
+	}
+	if reason == "" {
+		return nil
+	}
+	defaultAudit.recordRefusal(spec.Module, spec.Target, reason)
+	return fmt.Errorf("%s", reason)
+}
+
+func preflight(spec jobSpec, ranges []sensitiveRange, record *scanRecord) error {
+	if err := checkEstimate(spec, record); err != nil {
+		return err
+	}
+	return checkAcknowledged(spec, ranges, record)
+}
+
+// confirmRun asks for confirmation of large runs and of sensitive address
+// space before calling proceed with everything the operator acknowledged.
+func confirmRun(spec jobSpec, ranges []sensitiveRange, proceed func(acknowledged []string)) error {
+	est, err := estimateRun(spec)
+	if err != nil {
+		return err
+	}
+	guard := defaultGuard.current()
+	if err := guard.checkLimit(est); err != nil {
+		return err
+	}
+	confirm := guard.needsConfirmation(est)
+	win := activeWindow()
+	if win == nil && (confirm || len(ranges) > 0) {
+		return errNoConfirmation
+	}
+	if !confirm {
+		confirmSensitive(ranges, proceed)
+		return nil
+	}
+	message := fmt.Sprintf("This run is estimated at %s,\nabove the confirmation thresholds (%d probes or %d minutes).\nContinue?",
+		est, guard.ConfirmProbes, guard.ConfirmMinutes)
+	dialog.ShowConfirm("Large Scan", message, func(ok bool) {
+		if !ok {
+			return
+		}
+		confirmSensitive(ranges, func(acknowledged []string) {
+			proceed(append(acknowledged, ackLargeRun))
+		})
+	}, win)
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+type guardStore struct {
+	mu       sync.Mutex
+	loadOnce sync.Once
+	settings guardSettings
+	sockets  chan struct{}
+}
+
+var defaultGuard = &guardStore{}
+
+func (g *guardStore) current() guardSettings {
+	g.loadOnce.Do(func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.settings = defaultGuardSettings()
+		if err := loadJSON(guardFile, &g.settings); err != nil {
+			log.Printf("guard: %v", err)
+		}
+		if err := g.settings.validate(); err != nil {
+			log.Printf("guard: %v, using defaults", err)
+			g.settings = defaultGuardSettings()
+		}
+		g.applyLocked()
+	})
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.settings
+}
+
+func (g *guardStore) set(settings guardSettings) error {
+	if err := settings.validate(); err != nil {
+		return err
+	}
+	g.current()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.settings = settings
+	g.applyLocked()
+	return saveJSON(guardFile, settings)
+}
+
+func (g *guardStore) applyLocked() {
+	debug.SetMemoryLimit(int64(g.settings.MemoryLimitMB) << 20)
+	g.sockets = make(chan struct{}, g.settings.MaxOpenSockets)
+}
+
+// acquireSocket blocks until a socket slot is free. Slots are returned to
+// the pool they came from, so resizing the pool never strands a caller.
+func (g *guardStore) acquireSocket() func() {
+	g.current()
+	g.mu.Lock()
+	sockets := g.sockets
+	g.mu.Unlock()
+	sockets <- struct{}{}
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-sockets })
+	}
+}
+
+// tryAcquireSocket takes a socket slot only when one is free, for sockets a
+// probe can do without.
+func (g *guardStore) tryAcquireSocket() (func(), bool) {
+	g.current()
+	g.mu.Lock()
+	sockets := g.sockets
+	g.mu.Unlock()
+	select {
+	case sockets <- struct{}{}:
+	default:
+		return nil, false
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-sockets })
+	}, true
+}
+
+type guardedConn struct {
+	net.Conn
+	release func()
+}
+
+func (c *guardedConn) Close() error {
+	err := c.Conn.Close()
+	c.release()
+	return err
+}
+
+// dialTCP is the single place scans open TCP connections, so every probe
+// counts against the open socket limit, leaves from the job's source binding
+// and goes through the proxy chain.
+func dialTCP(src *sourceBinding, address string, timeout time.Duration) (net.Conn, error) {
+	release := defaultGuard.acquireSocket()
+	conn, err := defaultProxy.current().dial(src, address, timeout)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &guardedConn{Conn: conn, release: release}, nil
+}
+
+// dialTCPContext is dialTCP for callers that hold a context, such as HTTP
+// transports. The dial gives up when ctx is done; a connection that still
+// completes afterwards is closed so it does not keep its socket slot.
+func dialTCPContext(ctx context.Context, src *sourceBinding, address string, timeout time.Duration) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan dialResult, 1)
+	go func() {
+		conn, err := dialTCP(src, address, timeout)
+		done <- dialResult{conn, err}
+	}()
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// guardContext cancels a run when the heap grows past the memory limit.
+func guardContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	limit := uint64(defaultGuard.current().MemoryLimitMB) << 20
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runtime.ReadMemStats(&stats)
+				if stats.HeapAlloc > limit {
+					cancel(errMemoryLimit)
+					return
+				}
+			}
+		}
+	}()
+	return ctx, func() { cancel(nil) }
+}
+
+// guardCause reports why a guarded run was stopped: the memory limit, or
+// shutdown. It is nil when the run was stopped by the operator or not at all.
+func guardCause(ctx context.Context) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errMemoryLimit) || errors.Is(cause, errShutdown) {
+		return cause
+	}
+	return nil
+}
+
+// ShowSettings opens the run guard settings.
+func ShowSettings(win fyne.Window) {
+	current := defaultGuard.current()
+	entries := []*widget.Entry{}
+	newEntry := func(value int) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetText(strconv.Itoa(value))
+		entry.Validator = func(text string) error {
+			if _, err := strconv.Atoi(strings.TrimSpace(text)); err != nil {
+				return fmt.Errorf("enter a whole number")
+			}
+			return nil
+		}
+		entries = append(entries, entry)
+		return entry
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Confirm above probes", newEntry(current.ConfirmProbes)),
+		widget.NewFormItem("Confirm above minutes", newEntry(current.ConfirmMinutes)),
+		widget.NewFormItem("Max hosts per run", newEntry(current.MaxHosts)),
+		widget.NewFormItem("Max open sockets", newEntry(current.MaxOpenSockets)),
+		widget.NewFormItem("Memory limit (MB)", newEntry(current.MemoryLimitMB)),
+	}
+	dialog.ShowForm("Settings", "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		values := make([]int, len(entries))
+		for i, entry := range entries {
+			values[i], _ = strconv.Atoi(strings.TrimSpace(entry.Text))
+		}
+		settings := guardSettings{
+			ConfirmProbes:  values[0],
+			ConfirmMinutes: values[1],
+			MaxHosts:       values[2],
+			MaxOpenSockets: values[3],
+			MemoryLimitMB:  values[4],
+		}
+		if err := defaultGuard.set(settings); err != nil {
+			dialog.ShowError(err, win)
+		}
+	}, win)
+}
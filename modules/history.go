@@ -0,0 +1,198 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const historyFile = "history.json"
+
+const maxHistoryRecords = 500
+
+const (
+	runCompleted  = "completed"
+	runStopped    = "stopped"
+	runFailed     = "failed"
+	runMissed     = "missed"
+	runIncomplete = "incomplete"
+)
+
+type scanRecord struct {
+	ID           string                 `json:"id"`
+	JobID        string                 `json:"job_id,omitempty"`
+	CredentialID string                 `json:"credential_id,omitempty"`
+	Settings     map[string]string      `json:"settings,omitempty"`
+	Module       string                 `json:"module"`
+	Target       string                 `json:"target"`
+	Started      time.Time              `json:"started"`
+	Finished     time.Time              `json:"finished"`
+	Status       string                 `json:"status"`
+	Summary      string                 `json:"summary"`
+	Error        string                 `json:"error,omitempty"`
+	Ports        []portStatus           `json:"ports,omitempty"`
+	Devices      []networkDevice        `json:"devices,omitempty"`
+	Findings     []vulnerabilityFinding `json:"findings,omitempty"`
+	Skipped      []skippedHost          `json:"skipped,omitempty"`
+}
+
+func newScanRecord(module, target string) scanRecord {
+	return scanRecord{
+		ID:      newID(),
+		Module:  module,
+		Target:  target,
+		Started: time.Now(),
+	}
+}
+
+func (r *scanRecord) finish(canceled bool, err error) {
+	r.Finished = time.Now()
+	switch {
+	case errors.Is(err, errShutdown):
+		r.Status = runIncomplete
+	case err != nil:
+		r.Status = runFailed
+		r.Error = err.Error()
+		r.Summary = fmt.Sprintf("Failed: %v", err)
+		return
+	case canceled:
+		r.Status = runStopped
+	default:
+		r.Status = runCompleted
+	}
+	r.Summary = r.describe()
+	if len(r.Skipped) > 0 {
+		r.Summary += fmt.Sprintf(" %d host(s) skipped.", len(r.Skipped))
+	}
+	if r.Status == runIncomplete {
+		r.Summary = "Incomplete, Rodent was closed. " + r.Summary
+	}
+}
+
+func (r scanRecord) describe() string {
+	switch r.Module {
+	case moduleScanner:
+		open := 0
+		for _, ps := range r.Ports {
+			if ps.Status == "open" {
+				open++
+			}
+		}
+		summary := fmt.Sprintf("%d open port(s) of %d checked.", open, len(r.Ports))
+		if len(r.Findings) > 0 {
+			summary += fmt.Sprintf(" %d policy violation(s).", len(r.Findings))
+		}
+		return summary
+	case moduleMapper:
+		return fmt.Sprintf("%d host(s) responded.", len(r.Devices))
+	case moduleVulnerability:
+		return fmt.Sprintf("%d finding(s).", len(r.Findings))
+	case moduleTraceroute:
+		return fmt.Sprintf("%d route(s) traced.", len(r.Devices))
+	default:
+		return ""
+	}
+}
+
+func (r scanRecord) String() string {
+	return fmt.Sprintf("%s  %-21s %-20s %-9s %s",
+		r.Started.Format("2006-01-02 15:04"), r.Module, r.Target, r.Status, r.Summary)
+}
+
+type historyStore struct {
+	mu        sync.Mutex
+	loadOnce  sync.Once
+	records   []scanRecord
+	listeners []func(scanRecord)
+}
+
+var defaultHistory = &historyStore{}
+
+func scanHistory() *historyStore {
+	defaultHistory.loadOnce.Do(func() {
+		var records []scanRecord
+		if err := loadJSON(historyFile, &records); err != nil {
+			log.Printf("history: %v", err)
+		}
+		defaultHistory.records = records
+	})
+	return defaultHistory
+}
+
+func (h *historyStore) add(rec scanRecord) {
+	h.mu.Lock()
+	h.records = append(h.records, rec)
+	if len(h.records) > maxHistoryRecords {
+		h.records = append([]scanRecord(nil), h.records[len(h.records)-maxHistoryRecords:]...)
+	}
+	// Saving under the lock keeps concurrent runs from writing an older
+	// snapshot over a newer one.
+	if err := saveJSON(historyFile, h.records); err != nil {
+		log.Printf("history: %v", err)
+	}
+	listeners := append([]func(scanRecord){}, h.listeners...)
+	h.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(rec)
+	}
+}
+
+func (h *historyStore) subscribe(fn func(scanRecord)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners = append(h.listeners, fn)
+}
+
+// recent returns up to limit records, newest first.
+func (h *historyStore) recent(limit int) []scanRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]scanRecord, 0, len(h.records))
+	for i := len(h.records) - 1; i >= 0; i-- {
+		out = append(out, h.records[i])
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+func (h *historyStore) latest(module, target string) (scanRecord, bool) {
+	for _, rec := range h.recent(0) {
+		if rec.Module == module && strings.EqualFold(rec.Target, target) && rec.Status == runCompleted {
+			return rec, true
+		}
+	}
+	return scanRecord{}, false
+}
+
+// previous returns the latest completed run of the same module and target
+// that started before rec.
+func (h *historyStore) previous(rec scanRecord) (scanRecord, bool) {
+	for _, candidate := range h.recent(0) {
+		if candidate.ID == rec.ID || candidate.Status != runCompleted {
+			continue
+		}
+		if candidate.Module == rec.Module && candidate.Target == rec.Target && candidate.Started.Before(rec.Started) {
+			return candidate, true
+		}
+	}
+	return scanRecord{}, false
+}
+
+func (h *historyStore) forJob(jobID string, limit int) []scanRecord {
+	var out []scanRecord
+	for _, rec := range h.recent(0) {
+		if rec.JobID == jobID {
+			out = append(out, rec)
+			if limit > 0 && len(out) == limit {
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,199 @@
+package modules
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const nameTimeout = time.Second
+
+// resolveHostname asks reverse DNS, the host's mDNS responder and its NetBIOS
+// name service at the same time, and prefers the answers in that order, so a
+// reverse DNS name is returned without waiting for the others. The mDNS and
+// NetBIOS queries go to the host itself, so they are left out while a proxy
+// chain is active.
+func resolveHostname(src *sourceBinding, ip net.IP) string {
+	direct := !defaultProxy.current().active()
+	lookups := []struct {
+		fn  func() string
+		use bool
+	}{
+		{func() string { return reverseDNS(ip) }, true},
+		{func() string { return mdnsName(src, ip) }, direct},
+		{func() string { return netbiosName(src, ip) }, direct && ip.To4() != nil},
+	}
+	results := make([]chan string, len(lookups))
+	for i, lookup := range lookups {
+		results[i] = make(chan string, 1)
+		if !lookup.use {
+			results[i] <- ""
+			continue
+		}
+		go func(result chan string, fn func() string) {
+			result <- fn()
+		}(results[i], lookup.fn)
+	}
+	for _, result := range results {
+		if name := <-result; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+func reverseDNS(ip net.IP) string {
+	ctx, cancel := context.WithTimeout(context.Background(), nameTimeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip.String())
+	if err != nil || len(names) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(names[0], ".")
+}
+
+// scanName is the name to hand to the Scanner: the hostname when it resolves
+// back to the device, otherwise the address.
+func (dev networkDevice) scanName() string {
+	if dev.Hostname == "" {
+		return dev.IP
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), nameTimeout)
+	defer cancel()
+	ips, _ := net.DefaultResolver.LookupIP(ctx, "ip", dev.Hostname)
+	for _, ip := range ips {
+		if ip.String() == dev.IP {
+			return dev.Hostname
+		}
+	}
+	return dev.IP
+}
+
+// queryUDP sends query to the host and hands replies from it to match until
+// one matches or the timeout passes.
+func queryUDP(src *sourceBinding, ip net.IP, port int, query []byte, match func([]byte) bool) {
+	release := defaultGuard.acquireSocket()
+	defer release()
+	var laddr *net.UDPAddr
+	if src != nil {
+		laddr = &net.UDPAddr{IP: src.ip}
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(nameTimeout))
+	if _, err := conn.WriteToUDP(query, &net.UDPAddr{IP: ip, Port: port}); err != nil {
+		return
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFromUDP(buf)
+		if err != nil || (peer.IP.Equal(ip) && match(buf[:n])) {
+			return
+		}
+	}
+}
+
+// mdnsName sends a legacy unicast query for the reverse name straight to the
+// host's mDNS port, which responders answer from port 5353 to the sender.
+func mdnsName(src *sourceBinding, ip net.IP) string {
+	reverse, err := dnsmessage.NewName(reverseName(ip))
+	if err != nil {
+		return ""
+	}
+	id := uint16(rand.Intn(1 << 16))
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id},
+		Questions: []dnsmessage.Question{{Name: reverse, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return ""
+	}
+	var name string
+	queryUDP(src, ip, 5353, query, func(reply []byte) bool {
+		var msg dnsmessage.Message
+		if msg.Unpack(reply) != nil || !msg.Response {
+			return false
+		}
+		for _, answer := range msg.Answers {
+			if ptr, ok := answer.Body.(*dnsmessage.PTRResource); ok {
+				name = strings.TrimSuffix(ptr.PTR.String(), ".")
+				return true
+			}
+		}
+		return false
+	})
+	return name
+}
+
+func reverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", v4[3], v4[2], v4[1], v4[0])
+	}
+	var b strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%x.%x.", ip[i]&0xf, ip[i]>>4)
+	}
+	return b.String() + "ip6.arpa."
+}
+
+// netbiosName sends a node status request and returns the unique workstation
+// name from the reply.
+func netbiosName(src *sourceBinding, ip net.IP) string {
+	id := uint16(rand.Intn(1 << 16))
+	query := make([]byte, 12, 50)
+	binary.BigEndian.PutUint16(query[0:], id)
+	binary.BigEndian.PutUint16(query[4:], 1)
+	query = append(query, 0x20)
+	for i := 0; i < 16; i++ {
+		c := byte(0)
+		if i == 0 {
+			c = '*'
+		}
+		query = append(query, 'A'+c>>4, 'A'+c&0x0f)
+	}
+	query = append(query, 0, 0x00, 0x21, 0x00, 0x01)
+
+	var name string
+	queryUDP(src, ip, 137, query, func(reply []byte) bool {
+		if len(reply) < 12 || binary.BigEndian.Uint16(reply) != id {
+			return false
+		}
+		name = parseNodeStatus(reply)
+		return true
+	})
+	return name
+}
+
+func parseNodeStatus(reply []byte) string {
+	i := 12
+	for i < len(reply) && reply[i] != 0 {
+		if reply[i]&0xc0 == 0xc0 {
+			i++
+			break
+		}
+		i += int(reply[i]) + 1
+	}
+	i += 1 + 10
+	if i >= len(reply) {
+		return ""
+	}
+	count := int(reply[i])
+	i++
+	for n := 0; n < count && i+18 <= len(reply); n, i = n+1, i+18 {
+		suffix := reply[i+15]
+		group := binary.BigEndian.Uint16(reply[i+16:])&0x8000 != 0
+		if suffix == 0x00 && !group {
+			return strings.TrimSpace(string(reply[i : i+15]))
+		}
+	}
+	return ""
+}
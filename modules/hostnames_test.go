@@ -0,0 +1,58 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+type netbiosEntry struct {
+	name  string
+	group bool
+}
+
+// nodeStatusReply builds a NetBIOS node status response. The answer name is
+// written in full, or as a pointer to the header when compressed.
+func nodeStatusReply(compressed bool, names ...netbiosEntry) []byte {
+	reply := make([]byte, 12)
+	if compressed {
+		reply = append(reply, 0xc0, 0x0c)
+	} else {
+		reply = append(reply, 0x20)
+		reply = append(reply, bytes.Repeat([]byte{'A'}, 32)...)
+		reply = append(reply, 0)
+	}
+	reply = append(reply, 0, 0x21, 0, 1, 0, 0, 0, 0, 0, 0)
+	reply = append(reply, byte(len(names)))
+	for _, n := range names {
+		entry := make([]byte, 18)
+		copy(entry, fmt.Sprintf("%-15s", n.name))
+		if n.group {
+			binary.BigEndian.PutUint16(entry[16:], 0x8400)
+		} else {
+			binary.BigEndian.PutUint16(entry[16:], 0x0400)
+		}
+		reply = append(reply, entry...)
+	}
+	return reply
+}
+
+func TestParseNodeStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply []byte
+		want  string
+	}{
+		{"unique after group", nodeStatusReply(false, netbiosEntry{"WORKGROUP", true}, netbiosEntry{"FILESRV", false}), "FILESRV"},
+		{"compressed name", nodeStatusReply(true, netbiosEntry{"PRINTER", false}), "PRINTER"},
+		{"only groups", nodeStatusReply(false, netbiosEntry{"WORKGROUP", true}), ""},
+		{"truncated", nodeStatusReply(false, netbiosEntry{"FILESRV", false})[:60], ""},
+		{"header only", make([]byte, 12), ""},
+	}
+	for _, tt := range tests {
+		if got := parseNodeStatus(tt.reply); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func jobModules() []string {
+	return []string{moduleScanner, moduleMapper, moduleVulnerability}
+}
+
+type jobSpec struct {
+	Module       string   `json:"module"`
+	Target       string   `json:"target"`
+	CredentialID string   `json:"credential_id,omitempty"`
+	Acknowledged []string `json:"acknowledged,omitempty"`
+	Categories   []string `json:"categories,omitempty"`
+	Source       string   `json:"source,omitempty"`
+	Discovery    string   `json:"discovery,omitempty"`
+	Trace        string   `json:"trace,omitempty"`
+}
+
+func runSettings(spec jobSpec) map[string]string {
+	settings := map[string]string{}
+	switch spec.Module {
+	case moduleScanner:
+		ports := make([]int, 0, len(portCatalog()))
+		for _, def := range portCatalog() {
+			ports = append(ports, def.Port)
+		}
+		settings["ports"] = formatPortList(ports, nil)
+		settings["timeout"] = "500ms"
+	case moduleMapper:
+		strategy, _ := parseDiscovery(spec.Discovery)
+		settings["discovery"] = discoveryLabels[strategy]
+		settings["discovery ports"] = formatPortList(discoveryPorts(), nil)
+		settings["timeout"] = "150ms"
+		settings["max hosts"] = strconv.Itoa(defaultGuard.current().MaxHosts)
+	case moduleVulnerability:
+		allowed := allowedCategories(spec.Categories)
+		ports := make([]int, 0, len(vulnerabilityRules()))
+		seen := map[int]bool{}
+		for _, rule := range vulnerabilityRules() {
+			if allowed[rule.Category] && !seen[rule.Port] {
+				seen[rule.Port] = true
+				ports = append(ports, rule.Port)
+			}
+		}
+		var categories []string
+		for _, c := range checkCategories() {
+			if allowed[c] {
+				categories = append(categories, c)
+			}
+		}
+		settings["rule ports"] = formatPortList(ports, nil)
+		settings["check categories"] = strings.Join(categories, ", ")
+		settings["timeout"] = "500ms"
+	case moduleTraceroute:
+		method, _ := parseTrace(spec.Trace)
+		settings["method"] = traceLabels[method]
+		settings["max hops"] = strconv.Itoa(traceMaxHops)
+		settings["timeout"] = traceTimeout.String()
+	}
+	if spec.CredentialID != "" {
+		settings["credential"] = spec.CredentialID
+	}
+	if spec.Source != "" {
+		settings["source"] = spec.Source
+	}
+	if chain := defaultProxy.current(); chain.active() {
+		settings["proxy"] = chain.String()
+	}
+	if len(spec.Acknowledged) > 0 {
+		settings["acknowledged address space"] = strings.Join(spec.Acknowledged, ", ")
+	}
+	if list := defaultExclusions.current(); !list.empty() {
+		settings["exclusions"] = fmt.Sprintf("%d entries, %d file(s)", len(list.Entries), len(list.Files))
+	}
+	return settings
+}
+
+func beginRun(spec jobSpec, jobID string) scanRecord {
+	record := newScanRecord(spec.Module, spec.Target)
+	record.JobID = jobID
+	record.CredentialID = spec.CredentialID
+	record.Settings = runSettings(spec)
+	if jobID != "" {
+		record.Settings["trigger"] = "scheduled"
+	} else {
+		record.Settings["trigger"] = "manual"
+	}
+	defaultAudit.recordRun(auditScanStarted, record)
+	return record
+}
+
+func endRun(record scanRecord) {
+	scanHistory().add(record)
+	switch record.Status {
+	case runFailed:
+		defaultAudit.recordRun(auditScanFailed, record)
+	case runStopped, runIncomplete:
+		defaultAudit.recordRun(auditScanStopped, record)
+	default:
+		defaultAudit.recordRun(auditScanFinished, record)
+	}
+}
+
+// runHeadless runs a job under the run control found in ctx, if any, so the
+// caller can pause it.
+func runHeadless(ctx context.Context, spec jobSpec, jobID string) scanRecord {
+	return runControlled(ctx, spec, beginRun(spec, jobID), nil)
+}
+
+// resumeHeadless continues an interrupted run from its checkpoint. The run
+// gets a new record; its settings note the run it resumes.
+func resumeHeadless(ctx context.Context, cp runCheckpoint) scanRecord {
+	record := newScanRecord(cp.Module, cp.Target)
+	record.JobID = cp.Record.JobID
+	record.CredentialID = cp.CredentialID
+	record.Settings = runSettings(cp.jobSpec)
+	record.Settings["trigger"] = "resumed"
+	record.Settings["resumed from"] = fmt.Sprintf("%s (started %s)", cp.Record.ID, cp.Record.Started.Format(time.RFC1123))
+	defaultAudit.recordRun(auditScanStarted, record)
+	defaultCheckpoints.remove(cp.Record.ID)
+	return runControlled(ctx, cp.jobSpec, record, &cp)
+}
+
+func runControlled(ctx context.Context, spec jobSpec, record scanRecord, prior *runCheckpoint) scanRecord {
+	rc := runControlFrom(ctx)
+	if rc == nil {
+		rc = newRunControl()
+		ctx = withRunControl(ctx, rc)
+	}
+	rc.track(spec, record, prior)
+	ctx, stop := guardContext(ctx)
+	defer stop()
+	runSpec(ctx, spec, &record)
+	rc.finish(record)
+	endRun(record)
+	return record
+}
+
+func runSpec(ctx context.Context, spec jobSpec, record *scanRecord) {
+	module, target := spec.Module, spec.Target
+
+	var cred *credential
+	if spec.CredentialID != "" {
+		c, err := defaultVault.resolve(spec.CredentialID, target)
+		if err != nil {
+			record.finish(false, fmt.Errorf("credential %s: %w", spec.CredentialID, err))
+			return
+		}
+		cred = &c
+	}
+	src, err := parseSource(spec.Source)
+	if err != nil {
+		record.finish(false, fmt.Errorf("source: %w", err))
+		return
+	}
+	nw := liveNetwork{src: src}
+
+	switch module {
+	case moduleScanner:
+		hosts, err := expandTargets(target)
+		if err != nil {
+			record.finish(false, fmt.Errorf("invalid target list %q: %w", target, err))
+			return
+		}
+		for _, host := range hosts {
+			if net.ParseIP(host) == nil {
+				if _, err := net.LookupIP(host); err != nil {
+					record.finish(false, fmt.Errorf("unable to resolve %s: %w", host, err))
+					return
+				}
+			}
+		}
+		pins, err := checkScopeHosts(module, hosts)
+		if err != nil {
+			record.finish(false, fmt.Errorf("refused: %w", err))
+			return
+		}
+		if err := preflight(spec, sensitiveAddresses(hosts), record); err != nil {
+			record.finish(false, fmt.Errorf("refused: %w", err))
+			return
+		}
+		probe, skipped, err := partitionExcluded(hosts)
+		if err != nil {
+			record.finish(false, err)
+			return
+		}
+		record.Skipped = skipped
+		ports, late, canceled := scanHosts(ctx, pinNetwork(nw, pins), probe, len(hosts) > 1, nil)
+		record.Ports = ports
+		record.Skipped = append(record.Skipped, late...)
+		if !canceled {
+			record.Findings = baselineFindings(withoutSkipped(probe, late), ports)
+		}
+		record.finish(canceled, guardCause(ctx))
+	case moduleMapper:
+		normalized, err := normalizeSubnet(target)
+		if err != nil {
+			record.finish(false, fmt.Errorf("invalid subnet %q: %w", target, err))
+			return
+		}
+		_, ipnet, err := net.ParseCIDR(normalized)
+		if err != nil {
+			record.finish(false, fmt.Errorf("unable to parse subnet %q: %w", target, err))
+			return
+		}
+		record.Target = ipnet.String()
+		if err := checkScopeSubnet(module, ipnet); err != nil {
+			record.finish(false, fmt.Errorf("refused: %w", err))
+			return
+		}
+		if err := preflight(spec, sensitiveNetwork(ipnet), record); err != nil {
+			record.finish(false, fmt.Errorf("refused: %w", err))
+			return
+		}
+		excluded, err := defaultExclusions.current().compile()
+		if err != nil {
+			record.finish(false, err)
+			return
+		}
+		discovery := newHostDiscovery(nw, spec.Discovery)
+		devices, skipped, canceled := mapSubnet(ctx, discovery, ipnet, excluded, nil, nil, nil)
+		discovery.note(record)
+		record.Devices = devices
+		record.Skipped = skipped
+		record.finish(canceled, guardCause(ctx))
+	case moduleVulnerability:
+		if _, err := net.LookupIP(target); err != nil {
+			record.finish(false, fmt.Errorf("unable to resolve %s: %w", target, err))
+			return
+		}
+		pins, err := checkScopeHosts(module, []string{target})
+		if err != nil {
+			record.finish(false, fmt.Errorf("refused: %w", err))
+			return
+		}
+		if err := preflight(spec, sensitiveAddresses([]string{target}), record); err != nil {
+			record.finish(false, fmt.Errorf("refused: %w", err))
+			return
+		}
+		probe, skipped, err := partitionExcluded([]string{target})
+		if err != nil {
+			record.finish(false, err)
+			return
+		}
+		record.Skipped = skipped
+		if len(probe) == 0 {
+			record.finish(false, nil)
+			return
+		}
+		findings, canceled := checkVulnerabilities(ctx, pinNetwork(nw, pins), target, spec.Categories, cred)
+		record.Findings = findings
+		record.finish(canceled, guardCause(ctx))
+	default:
+		record.finish(false, fmt.Errorf("unknown module %q", module))
+	}
+}
@@ -2,15 +2,39 @@ package modules
 
 import "fyne.io/fyne/v2"
 
+const (
+	moduleScanner       = "Scanner"
+	moduleMapper        = "Network Mapper"
+	moduleVulnerability = "Vulnerability Scanner"
+	moduleTraceroute    = "Traceroute"
+)
+
 type Module interface {
 	Name() string
 	Content() fyne.CanvasObject
 }
 
+var showModule func(name string)
+
+// SetNavigator lets modules bring another module to the front, such as the
+// Network Mapper sending devices to the Scanner.
+func SetNavigator(fn func(name string)) {
+	showModule = fn
+}
+
 func Registered() []Module {
 	return []Module{
 		&scannerModule{},
 		&networkMapperModule{},
 		&vulnerabilityModule{},
+		&schedulerModule{},
+		&monitoringModule{},
+		&baselineModule{},
+		&vaultModule{},
+		&engagementModule{},
+		&proxyModule{},
+		&auditModule{},
+		&reportsModule{},
+		&testBenchModule{},
 	}
 }
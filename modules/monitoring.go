@@ -0,0 +1,647 @@
+package modules
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	monitorsFile     = "monitors.json"
+	maxStoredAlerts  = 500
+	anyModuleOption  = "Any module"
+	anySeverityLevel = "Any"
+)
+
+const (
+	changeNewDevice      = "New device"
+	changeDeviceGone     = "Device disappeared"
+	changePortOpened     = "Port opened"
+	changePortClosed     = "Port closed"
+	changeNewFinding     = "New finding"
+	changeFindingCleared = "Finding resolved"
+)
+
+func monitorEvents() []string {
+	return []string{
+		changeNewDevice,
+		changeDeviceGone,
+		changePortOpened,
+		changePortClosed,
+		changeNewFinding,
+		changeFindingCleared,
+	}
+}
+
+func severityLevels() []string {
+	return []string{"Low", "Medium", "High", "Critical"}
+}
+
+func severityRank(severity string) int {
+	for i, level := range severityLevels() {
+		if strings.EqualFold(level, severity) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+type monitorPolicy struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Module      string   `json:"module,omitempty"`
+	Target      string   `json:"target,omitempty"`
+	Events      []string `json:"events"`
+	MinSeverity string   `json:"min_severity,omitempty"`
+	Enabled     bool     `json:"enabled"`
+}
+
+type monitorChange struct {
+	Kind     string
+	Severity string
+	Detail   string
+}
+
+type monitorAlert struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	PolicyID   string    `json:"policy_id"`
+	PolicyName string    `json:"policy_name"`
+	Module     string    `json:"module"`
+	Target     string    `json:"target"`
+	Kind       string    `json:"kind"`
+	Detail     string    `json:"detail"`
+}
+
+func (a monitorAlert) String() string {
+	return fmt.Sprintf("%s  [%s] %s on %s: %s",
+		a.Time.Format("2006-01-02 15:04"), a.PolicyName, a.Kind, a.Target, a.Detail)
+}
+
+type monitorState struct {
+	Policies []monitorPolicy `json:"policies"`
+	Alerts   []monitorAlert  `json:"alerts"`
+}
+
+type changeMonitor struct {
+	mu        sync.Mutex
+	loadOnce  sync.Once
+	startOnce sync.Once
+	state     monitorState
+	listeners []func()
+}
+
+var defaultMonitor = &changeMonitor{}
+
+func (c *changeMonitor) load() {
+	c.loadOnce.Do(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if err := loadJSON(monitorsFile, &c.state); err != nil {
+			log.Printf("monitoring: %v", err)
+		}
+	})
+}
+
+func (c *changeMonitor) start() {
+	c.load()
+	c.startOnce.Do(func() {
+		scanHistory().subscribe(c.evaluate)
+	})
+}
+
+func (c *changeMonitor) evaluate(rec scanRecord) {
+	if rec.Status != runCompleted {
+		return
+	}
+	prev, ok := scanHistory().previous(rec)
+	if !ok {
+		return
+	}
+	changes := diffRecords(prev, rec)
+	if len(changes) == 0 {
+		return
+	}
+
+	var raised []monitorAlert
+	c.mu.Lock()
+	for _, policy := range c.state.Policies {
+		if !policy.Enabled || !policy.matchesRecord(rec) {
+			continue
+		}
+		for _, change := range changes {
+			if !policy.matchesChange(change) {
+				continue
+			}
+			raised = append(raised, monitorAlert{
+				ID:         newID(),
+				Time:       rec.Finished,
+				PolicyID:   policy.ID,
+				PolicyName: policy.Name,
+				Module:     rec.Module,
+				Target:     rec.Target,
+				Kind:       change.Kind,
+				Detail:     change.Detail,
+			})
+		}
+	}
+	if len(raised) > 0 {
+		c.state.Alerts = append(c.state.Alerts, raised...)
+		if len(c.state.Alerts) > maxStoredAlerts {
+			c.state.Alerts = append([]monitorAlert(nil), c.state.Alerts[len(c.state.Alerts)-maxStoredAlerts:]...)
+		}
+		c.saveLocked()
+	}
+	c.mu.Unlock()
+
+	for _, alert := range raised {
+		notifyUser(fmt.Sprintf("Rodent: %s", alert.Kind), fmt.Sprintf("%s on %s: %s", alert.PolicyName, alert.Target, alert.Detail))
+	}
+	if len(raised) > 0 {
+		c.changed()
+	}
+}
+
+func (p monitorPolicy) matchesRecord(rec scanRecord) bool {
+	if p.Module != "" && p.Module != rec.Module {
+		return false
+	}
+	return targetMatches(p.Target, rec.Target)
+}
+
+func (p monitorPolicy) matchesChange(change monitorChange) bool {
+	wanted := false
+	for _, event := range p.Events {
+		if event == change.Kind {
+			wanted = true
+			break
+		}
+	}
+	if !wanted {
+		return false
+	}
+	if change.Severity != "" && p.MinSeverity != "" {
+		return severityRank(change.Severity) >= severityRank(p.MinSeverity)
+	}
+	return true
+}
+
+func targetMatches(pattern, target string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return true
+	}
+	if strings.EqualFold(pattern, target) {
+		return true
+	}
+	_, patternNet, err := net.ParseCIDR(pattern)
+	if err != nil {
+		return false
+	}
+	if ip := net.ParseIP(target); ip != nil {
+		return patternNet.Contains(ip)
+	}
+	if _, targetNet, err := net.ParseCIDR(target); err == nil {
+		patternOnes, _ := patternNet.Mask.Size()
+		targetOnes, _ := targetNet.Mask.Size()
+		return patternNet.Contains(targetNet.IP) && targetOnes >= patternOnes
+	}
+	return false
+}
+
+func diffRecords(prev, cur scanRecord) []monitorChange {
+	var changes []monitorChange
+
+	// Hosts skipped by the current run were not probed, so their absence
+	// is not a change.
+	skipped := map[string]bool{}
+	for _, skip := range cur.Skipped {
+		skipped[skip.Host] = true
+	}
+	wasSkipped := func(host string) bool {
+		return skipped[host] || (host == "" && skipped[cur.Target])
+	}
+
+	prevOpen := map[string]bool{}
+	for _, ps := range prev.Ports {
+		prevOpen[ps.key()] = ps.Status == "open"
+	}
+	curOpen := map[string]bool{}
+	for _, ps := range cur.Ports {
+		open := ps.Status == "open"
+		curOpen[ps.key()] = open
+		if open && !prevOpen[ps.key()] {
+			changes = append(changes, monitorChange{
+				Kind:   changePortOpened,
+				Detail: fmt.Sprintf("%s is now open", ps.label()),
+			})
+		}
+	}
+	for _, ps := range prev.Ports {
+		if ps.Status == "open" && !curOpen[ps.key()] && !wasSkipped(ps.Host) {
+			changes = append(changes, monitorChange{
+				Kind:   changePortClosed,
+				Detail: fmt.Sprintf("%s is no longer open", ps.label()),
+			})
+		}
+	}
+
+	prevDevices := map[string]bool{}
+	for _, dev := range prev.Devices {
+		prevDevices[dev.IP] = true
+	}
+	curDevices := map[string]bool{}
+	for _, dev := range cur.Devices {
+		curDevices[dev.IP] = true
+		if !prevDevices[dev.IP] {
+			changes = append(changes, monitorChange{
+				Kind:   changeNewDevice,
+				Detail: fmt.Sprintf("%s appeared (%s)", dev.IP, dev.OS),
+			})
+		}
+	}
+	for _, dev := range prev.Devices {
+		if !curDevices[dev.IP] && !wasSkipped(dev.IP) {
+			changes = append(changes, monitorChange{
+				Kind:   changeDeviceGone,
+				Detail: fmt.Sprintf("%s no longer responds", dev.IP),
+			})
+		}
+	}
+
+	prevFindings := map[string]bool{}
+	for _, f := range prev.Findings {
+		prevFindings[f.Service] = true
+	}
+	curFindings := map[string]bool{}
+	for _, f := range cur.Findings {
+		curFindings[f.Service] = true
+		if !prevFindings[f.Service] {
+			changes = append(changes, monitorChange{
+				Kind:     changeNewFinding,
+				Severity: f.Severity,
+				Detail:   fmt.Sprintf("[%s] %s - %s", f.Severity, f.Service, f.Description),
+			})
+		}
+	}
+	for _, f := range prev.Findings {
+		if !curFindings[f.Service] && !wasSkipped("") {
+			changes = append(changes, monitorChange{
+				Kind:     changeFindingCleared,
+				Severity: f.Severity,
+				Detail:   fmt.Sprintf("[%s] %s no longer detected", f.Severity, f.Service),
+			})
+		}
+	}
+
+	return changes
+}
+
+func (c *changeMonitor) saveLocked() {
+	if err := saveJSON(monitorsFile, c.state); err != nil {
+		log.Printf("monitoring: %v", err)
+	}
+}
+
+func (c *changeMonitor) policies() []monitorPolicy {
+	c.load()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]monitorPolicy(nil), c.state.Policies...)
+}
+
+// alerts returns stored alerts, newest first.
+func (c *changeMonitor) alerts() []monitorAlert {
+	c.load()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]monitorAlert, 0, len(c.state.Alerts))
+	for i := len(c.state.Alerts) - 1; i >= 0; i-- {
+		out = append(out, c.state.Alerts[i])
+	}
+	return out
+}
+
+func (c *changeMonitor) addPolicy(policy monitorPolicy) error {
+	if len(policy.Events) == 0 {
+		return fmt.Errorf("select at least one change to watch for")
+	}
+	if strings.Contains(policy.Target, "/") {
+		if _, _, err := net.ParseCIDR(policy.Target); err != nil {
+			return fmt.Errorf("invalid subnet %q", policy.Target)
+		}
+	}
+	if policy.Name == "" {
+		policy.Name = strings.Join(policy.Events, ", ")
+	}
+
+	c.load()
+	c.mu.Lock()
+	policy.ID = newID()
+	policy.Enabled = true
+	c.state.Policies = append(c.state.Policies, policy)
+	c.saveLocked()
+	c.mu.Unlock()
+
+	c.changed()
+	return nil
+}
+
+func (c *changeMonitor) setEnabled(id string, enabled bool) {
+	c.mu.Lock()
+	for i := range c.state.Policies {
+		if c.state.Policies[i].ID == id {
+			c.state.Policies[i].Enabled = enabled
+		}
+	}
+	c.saveLocked()
+	c.mu.Unlock()
+	c.changed()
+}
+
+func (c *changeMonitor) removePolicy(id string) {
+	c.mu.Lock()
+	for i := range c.state.Policies {
+		if c.state.Policies[i].ID == id {
+			c.state.Policies = append(c.state.Policies[:i], c.state.Policies[i+1:]...)
+			break
+		}
+	}
+	c.saveLocked()
+	c.mu.Unlock()
+	c.changed()
+}
+
+func (c *changeMonitor) clearAlerts() {
+	c.mu.Lock()
+	c.state.Alerts = nil
+	c.saveLocked()
+	c.mu.Unlock()
+	c.changed()
+}
+
+func (c *changeMonitor) subscribe(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, fn)
+}
+
+func (c *changeMonitor) changed() {
+	c.mu.Lock()
+	listeners := append([]func(){}, c.listeners...)
+	c.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+func AlertBar() fyne.CanvasObject {
+	label := widget.NewLabel("")
+	label.Truncation = fyne.TextTruncateEllipsis
+	dismiss := widget.NewButton("Dismiss", nil)
+	bar := container.NewBorder(nil, nil, widget.NewIcon(theme.WarningIcon()), dismiss, label)
+	dismiss.OnTapped = bar.Hide
+	bar.Hide()
+
+	lastSeen := ""
+	if alerts := defaultMonitor.alerts(); len(alerts) > 0 {
+		lastSeen = alerts[0].ID
+	}
+	defaultMonitor.subscribe(func() {
+		alerts := defaultMonitor.alerts()
+		if len(alerts) == 0 || alerts[0].ID == lastSeen {
+			return
+		}
+		latest := alerts[0]
+		lastSeen = latest.ID
+		runOnMain(func() {
+			label.SetText(latest.String())
+			bar.Show()
+		})
+	})
+
+	return bar
+}
+
+type monitoringModule struct {
+	content        fyne.CanvasObject
+	nameEntry      *widget.Entry
+	moduleSelect   *widget.Select
+	targetEntry    *widget.Entry
+	eventsGroup    *widget.CheckGroup
+	severitySelect *widget.Select
+	statusLabel    *widget.Label
+	policyList     *widget.List
+	alertList      *widget.List
+	toggleButton   *widget.Button
+	deleteButton   *widget.Button
+	policies       []monitorPolicy
+	alerts         []monitorAlert
+	selected       int
+}
+
+func (m *monitoringModule) Name() string {
+	return "Monitoring"
+}
+
+func (m *monitoringModule) Content() fyne.CanvasObject {
+	if m.content != nil {
+		return m.content
+	}
+
+	m.selected = -1
+
+	m.nameEntry = widget.NewEntry()
+	m.nameEntry.SetPlaceHolder("Policy name (optional)")
+
+	m.moduleSelect = widget.NewSelect(append([]string{anyModuleOption}, jobModules()...), nil)
+	m.moduleSelect.SetSelectedIndex(0)
+
+	m.targetEntry = widget.NewEntry()
+	m.targetEntry.SetPlaceHolder("Host or subnet (blank = any)")
+
+	m.severitySelect = widget.NewSelect(append([]string{anySeverityLevel}, severityLevels()...), nil)
+	m.severitySelect.SetSelected("Critical")
+
+	m.eventsGroup = widget.NewCheckGroup(monitorEvents(), nil)
+	m.eventsGroup.Horizontal = true
+	m.eventsGroup.SetSelected([]string{changeNewDevice, changePortOpened, changeNewFinding})
+
+	addButton := widget.NewButton("Add Policy", m.addPolicy)
+
+	rowHeight := m.targetEntry.MinSize().Height
+	formRow := container.NewHBox(
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(170, rowHeight)), m.nameEntry),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(190, rowHeight)), m.moduleSelect),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(200, rowHeight)), m.targetEntry),
+		widget.NewLabel("Min. severity"),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(110, rowHeight)), m.severitySelect),
+		addButton,
+		layout.NewSpacer(),
+	)
+
+	m.statusLabel = widget.NewLabel("Each completed run is compared with the previous run for the same target.")
+
+	m.policyList = widget.NewList(
+		func() int { return len(m.policies) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i int, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(describePolicy(m.policies[i]))
+		},
+	)
+	m.policyList.OnSelected = func(id widget.ListItemID) {
+		m.selected = id
+		m.updateActions()
+	}
+	m.policyList.OnUnselected = func(widget.ListItemID) {
+		m.selected = -1
+		m.updateActions()
+	}
+	policyScroll := container.NewVScroll(m.policyList)
+	policyScroll.SetMinSize(fyne.NewSize(0, 110))
+
+	m.toggleButton = widget.NewButton("Disable", m.toggleSelected)
+	m.deleteButton = widget.NewButton("Delete", m.deleteSelected)
+	policyActions := container.NewHBox(m.toggleButton, m.deleteButton, layout.NewSpacer())
+
+	m.alertList = widget.NewList(
+		func() int { return len(m.alerts) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i int, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(m.alerts[i].String())
+		},
+	)
+	alertScroll := container.NewVScroll(m.alertList)
+	alertScroll.SetMinSize(fyne.NewSize(0, 150))
+	clearButton := widget.NewButton("Clear Alerts", defaultMonitor.clearAlerts)
+
+	m.content = container.NewVBox(
+		widget.NewLabelWithStyle("Monitoring", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Raise alerts when scan results change between runs."),
+		formRow,
+		m.eventsGroup,
+		m.statusLabel,
+		widget.NewCard("Policies", "", container.NewVBox(policyScroll, policyActions)),
+		widget.NewCard("Alerts", "", container.NewVBox(alertScroll, container.NewHBox(clearButton, layout.NewSpacer()))),
+	)
+
+	defaultMonitor.subscribe(func() { m.queueOnMain(m.reload) })
+	m.reload()
+
+	return m.content
+}
+
+func describePolicy(p monitorPolicy) string {
+	state := "on "
+	if !p.Enabled {
+		state = "off"
+	}
+	module := p.Module
+	if module == "" {
+		module = "any module"
+	}
+	target := p.Target
+	if target == "" {
+		target = "any target"
+	}
+	line := fmt.Sprintf("[%s] %-20s %-21s %-18s %s", state, p.Name, module, target, strings.Join(p.Events, ", "))
+	if p.MinSeverity != "" {
+		line += fmt.Sprintf(" (severity >= %s)", p.MinSeverity)
+	}
+	return line
+}
+
+func (m *monitoringModule) addPolicy() {
+	policy := monitorPolicy{
+		Name:   strings.TrimSpace(m.nameEntry.Text),
+		Target: strings.TrimSpace(m.targetEntry.Text),
+		Events: append([]string(nil), m.eventsGroup.Selected...),
+	}
+	if m.moduleSelect.Selected != anyModuleOption {
+		policy.Module = m.moduleSelect.Selected
+	}
+	if m.severitySelect.Selected != anySeverityLevel {
+		policy.MinSeverity = m.severitySelect.Selected
+	}
+	if err := defaultMonitor.addPolicy(policy); err != nil {
+		m.setStatus(fmt.Sprintf("Unable to add policy: %v", err))
+		return
+	}
+	m.nameEntry.SetText("")
+	m.targetEntry.SetText("")
+	m.setStatus("Policy added.")
+}
+
+func (m *monitoringModule) selectedPolicy() (monitorPolicy, bool) {
+	if m.selected < 0 || m.selected >= len(m.policies) {
+		return monitorPolicy{}, false
+	}
+	return m.policies[m.selected], true
+}
+
+func (m *monitoringModule) toggleSelected() {
+	if policy, ok := m.selectedPolicy(); ok {
+		defaultMonitor.setEnabled(policy.ID, !policy.Enabled)
+	}
+}
+
+func (m *monitoringModule) deleteSelected() {
+	if policy, ok := m.selectedPolicy(); ok {
+		defaultMonitor.removePolicy(policy.ID)
+		m.policyList.UnselectAll()
+	}
+}
+
+func (m *monitoringModule) reload() {
+	m.policies = defaultMonitor.policies()
+	m.alerts = defaultMonitor.alerts()
+	if m.selected >= len(m.policies) {
+		m.selected = -1
+	}
+	m.policyList.Refresh()
+	m.alertList.Refresh()
+	m.updateActions()
+}
+
+func (m *monitoringModule) updateActions() {
+	policy, ok := m.selectedPolicy()
+	for _, btn := range []*widget.Button{m.toggleButton, m.deleteButton} {
+		if ok {
+			btn.Enable()
+		} else {
+			btn.Disable()
+		}
+	}
+	if ok && !policy.Enabled {
+		m.toggleButton.SetText("Enable")
+	} else {
+		m.toggleButton.SetText("Disable")
+	}
+}
+
+func (m *monitoringModule) setStatus(text string) {
+	if m.statusLabel != nil {
+		m.statusLabel.SetText(text)
+	}
+}
+
+func (m *monitoringModule) queueOnMain(fn func()) {
+	if app := fyne.CurrentApp(); app != nil {
+		if drv := app.Driver(); drv != nil {
+			if runner, ok := drv.(interface{ RunOnMain(func()) }); ok {
+				runner.RunOnMain(fn)
+				return
+			}
+		}
+	}
+	fn()
+}
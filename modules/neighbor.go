@@ -0,0 +1,103 @@
+package modules
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const neighborTableFile = "/proc/net/arp"
+
+const (
+	macRouted  = "n/a (routed)"
+	macLocal   = "n/a (loopback)"
+	macUnknown = "unknown"
+)
+
+// readNeighborTable parses the kernel ARP table. Incomplete entries, which
+// the kernel keeps for addresses that did not answer, are left out.
+func readNeighborTable(r io.Reader) map[string]string {
+	table := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		if err != nil || flags&0x2 == 0 {
+			continue
+		}
+		mac, err := net.ParseMAC(fields[3])
+		if err != nil || isZeroMAC(mac) {
+			continue
+		}
+		table[fields[0]] = mac.String()
+	}
+	return table
+}
+
+func isZeroMAC(mac net.HardwareAddr) bool {
+	for _, b := range mac {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// localLink reports whether ip is on a directly connected segment, and the
+// hardware address of the interface when ip is one of ours.
+func localLink(ip net.IP) (bool, string) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return false, ""
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || !ipnet.Contains(ip) {
+				continue
+			}
+			if ipnet.IP.Equal(ip) {
+				return true, iface.HardwareAddr.String()
+			}
+			return true, ""
+		}
+	}
+	return false, ""
+}
+
+// neighborMAC looks up the MAC address of a host after it has been probed,
+// when the probe made the kernel resolve it. Hosts behind a router have no
+// MAC address we can see.
+func neighborMAC(ip net.IP) string {
+	onLink, own := localLink(ip)
+	switch {
+	case ip.IsLoopback():
+		return macLocal
+	case own != "":
+		return own
+	case !onLink:
+		return macRouted
+	case ip.To4() == nil:
+		return macUnknown
+	}
+	file, err := os.Open(neighborTableFile)
+	if err != nil {
+		return macUnknown
+	}
+	defer file.Close()
+	if mac, ok := readNeighborTable(file)[ip.String()]; ok {
+		return mac
+	}
+	return macUnknown
+}
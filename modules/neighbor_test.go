@@ -0,0 +1,26 @@
+package modules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadNeighborTable(t *testing.T) {
+	table := `IP address       HW type     Flags       HW address            Mask     Device
+192.0.2.1        0x1         0x2         52:54:00:12:34:56     *        eth0
+192.0.2.9        0x1         0x0         00:00:00:00:00:00     *        eth0
+192.0.2.10       0x1         0x6         00:1B:21:3A:4F:10     *        eth0
+192.0.2.11       0x1         0x2         00:00:00:00:00:00     *        eth0
+192.0.2.12       0x1         0x2         not-a-mac             *        eth0
+short line
+`
+	got := readNeighborTable(strings.NewReader(table))
+	want := map[string]string{
+		"192.0.2.1":  "52:54:00:12:34:56",
+		"192.0.2.10": "00:1b:21:3a:4f:10",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readNeighborTable = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,278 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	routeTableFile  = "/proc/net/route"
+	route6TableFile = "/proc/net/ipv6_route"
+)
+
+const (
+	routeUp      = 0x1
+	routeGateway = 0x2
+	routeReject  = 0x200
+)
+
+// maxSuggestedPrefix is the widest interface network offered as a subnet.
+// Wider networks are offered as the /24 around the address instead.
+const maxSuggestedPrefix = 22
+
+// localNetwork is what this machine knows about the networks it is on. The
+// routes come from the kernel tables, which only Linux has; elsewhere the
+// list is empty.
+type localNetwork struct {
+	Interfaces []localInterface
+	Routes     []localRoute
+}
+
+type localInterface struct {
+	Name     string
+	MAC      string
+	MTU      int
+	Loopback bool
+	Addrs    []*net.IPNet
+}
+
+type localRoute struct {
+	Destination *net.IPNet
+	Gateway     net.IP
+	Interface   string
+	Metric      int
+}
+
+// subnetSuggestion is a subnet the Network Mapper offers with one click.
+type subnetSuggestion struct {
+	CIDR string
+	Via  string
+}
+
+func (s subnetSuggestion) label() string {
+	return fmt.Sprintf("%s (%s)", s.CIDR, s.Via)
+}
+
+func detectNetwork() localNetwork {
+	var n localNetwork
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		li := localInterface{Name: iface.Name, MAC: iface.HardwareAddr.String(), MTU: iface.MTU, Loopback: iface.Flags&net.FlagLoopback != 0}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				li.Addrs = append(li.Addrs, ipnet)
+			}
+		}
+		if len(li.Addrs) > 0 {
+			n.Interfaces = append(n.Interfaces, li)
+		}
+	}
+	for _, table := range []struct {
+		path  string
+		parse func(io.Reader) []localRoute
+	}{{routeTableFile, readRouteTable}, {route6TableFile, readRoute6Table}} {
+		file, err := os.Open(table.path)
+		if err != nil {
+			continue
+		}
+		n.Routes = append(n.Routes, table.parse(file)...)
+		file.Close()
+	}
+	sort.SliceStable(n.Routes, func(i, j int) bool {
+		return n.Routes[i].Metric < n.Routes[j].Metric
+	})
+	return n
+}
+
+// readRouteTable parses the IPv4 routing table, where addresses and masks
+// are hex in host byte order.
+func readRouteTable(r io.Reader) []localRoute {
+	var routes []localRoute
+	scanner := bufio.NewScanner(r)
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		dst, err1 := routeHex4(fields[1])
+		gw, err2 := routeHex4(fields[2])
+		mask, err3 := routeHex4(fields[7])
+		flags, err4 := strconv.ParseUint(fields[3], 16, 32)
+		metric, _ := strconv.Atoi(fields[6])
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil || flags&routeUp == 0 || flags&routeReject != 0 {
+			continue
+		}
+		route := localRoute{Destination: &net.IPNet{IP: dst, Mask: net.IPMask(mask)}, Interface: fields[0], Metric: metric}
+		if flags&routeGateway != 0 {
+			route.Gateway = gw
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+func routeHex4(s string) (net.IP, error) {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	ip := make(net.IP, 4)
+	binary.LittleEndian.PutUint32(ip, uint32(v))
+	return ip, nil
+}
+
+// readRoute6Table parses the IPv6 routing table. Host routes for the
+// machine's own addresses, multicast and loopback routes are left out.
+func readRoute6Table(r io.Reader) []localRoute {
+	var routes []localRoute
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[9] == "lo" {
+			continue
+		}
+		dst, err1 := hex.DecodeString(fields[0])
+		prefix, err2 := strconv.ParseUint(fields[1], 16, 8)
+		gw, err3 := hex.DecodeString(fields[4])
+		metric, err4 := strconv.ParseUint(fields[5], 16, 32)
+		flags, err5 := strconv.ParseUint(fields[8], 16, 32)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil || len(dst) != 16 || len(gw) != 16 {
+			continue
+		}
+		if flags&routeUp == 0 || flags&routeReject != 0 || prefix == 128 || net.IP(dst).IsMulticast() {
+			continue
+		}
+		route := localRoute{Destination: &net.IPNet{IP: dst, Mask: net.CIDRMask(int(prefix), 128)}, Interface: fields[9], Metric: int(metric)}
+		if flags&routeGateway != 0 {
+			route.Gateway = gw
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+func (r localRoute) isDefault() bool {
+	ones, _ := r.Destination.Mask.Size()
+	return ones == 0
+}
+
+func (r localRoute) String() string {
+	dst := r.Destination.String()
+	if r.isDefault() {
+		dst = "default"
+	}
+	if r.Gateway != nil {
+		return fmt.Sprintf("%s via %s dev %s", dst, r.Gateway, r.Interface)
+	}
+	return fmt.Sprintf("%s dev %s", dst, r.Interface)
+}
+
+// defaultGateways returns the default routes, IPv4 first, lowest metric
+// first within each family.
+func (n localNetwork) defaultGateways() []localRoute {
+	var v4, v6 []localRoute
+	for _, r := range n.Routes {
+		switch {
+		case !r.isDefault() || r.Gateway == nil:
+		case r.Gateway.To4() != nil:
+			v4 = append(v4, r)
+		default:
+			v6 = append(v6, r)
+		}
+	}
+	return append(v4, v6...)
+}
+
+// suggestedSubnets lists the IPv4 networks of the interfaces, the one with
+// the default route first, followed by the networks routed through a
+// gateway. Link-local and point-to-point addresses are left out.
+func (n localNetwork) suggestedSubnets() []subnetSuggestion {
+	primary := ""
+	if gateways := n.defaultGateways(); len(gateways) > 0 && gateways[0].Gateway.To4() != nil {
+		primary = gateways[0].Interface
+	}
+	var suggestions []subnetSuggestion
+	seen := map[string]bool{}
+	add := func(ipnet *net.IPNet, via string, first bool) {
+		cidr := ipnet.String()
+		if seen[cidr] {
+			return
+		}
+		seen[cidr] = true
+		s := subnetSuggestion{CIDR: cidr, Via: via}
+		if first {
+			suggestions = append([]subnetSuggestion{s}, suggestions...)
+		} else {
+			suggestions = append(suggestions, s)
+		}
+	}
+	for _, iface := range n.Interfaces {
+		if iface.Loopback {
+			continue
+		}
+		for _, addr := range iface.Addrs {
+			ip := addr.IP.To4()
+			ones, _ := addr.Mask.Size()
+			if ip == nil || ip.IsLinkLocalUnicast() || ones > 30 {
+				continue
+			}
+			if ones < maxSuggestedPrefix {
+				ones = 24
+			}
+			mask := net.CIDRMask(ones, 32)
+			add(&net.IPNet{IP: ip.Mask(mask), Mask: mask}, iface.Name, iface.Name == primary)
+		}
+	}
+	for _, r := range n.Routes {
+		ones, _ := r.Destination.Mask.Size()
+		if r.Gateway == nil || r.Destination.IP.To4() == nil || ones < 16 || ones > 30 {
+			continue
+		}
+		add(r.Destination, "via "+r.Gateway.String(), false)
+	}
+	return suggestions
+}
+
+// lines describes the interfaces, default gateways and routes, one item per
+// line.
+func (n localNetwork) lines() []string {
+	var lines []string
+	gateways := n.defaultGateways()
+	if len(gateways) == 0 {
+		lines = append(lines, "Default gateway: unknown")
+	}
+	for _, r := range gateways {
+		lines = append(lines, fmt.Sprintf("Default gateway: %s (%s)", r.Gateway, r.Interface))
+	}
+	lines = append(lines, "Interfaces:")
+	for _, iface := range n.Interfaces {
+		line := "  " + iface.Name
+		if iface.MAC != "" {
+			line += " " + iface.MAC
+		}
+		lines = append(lines, fmt.Sprintf("%s MTU %d", line, iface.MTU))
+		for _, addr := range iface.Addrs {
+			lines = append(lines, "    "+addr.String())
+		}
+	}
+	if len(n.Routes) > 0 {
+		lines = append(lines, "Routes:")
+		for _, r := range n.Routes {
+			lines = append(lines, "  "+r.String())
+		}
+	}
+	return lines
+}
@@ -0,0 +1,57 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadRouteTable(t *testing.T) {
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	010200C0	0003	0	0	100	00000000	0	0	0
+eth0	000200C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+wg0	0000630A	0100000A	0003	0	0	0	0000FFFF	0	0	0
+eth0	0000A8C0	00000000	0201	0	0	0	0000FFFF	0	0	0
+eth1	0000A8C0	00000000	0000	0	0	0	0000FFFF	0	0	0
+`
+	routes := readRouteTable(strings.NewReader(table))
+	var got []string
+	for _, r := range routes {
+		got = append(got, r.String())
+	}
+	want := []string{
+		"default via 192.0.2.1 dev eth0",
+		"192.0.2.0/24 dev eth0",
+		"10.99.0.0/16 via 10.0.0.1 dev wg0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("readRouteTable =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if routes[0].Metric != 100 {
+		t.Errorf("metric = %d, want 100", routes[0].Metric)
+	}
+
+	n := localNetwork{Routes: routes}
+	if gateways := n.defaultGateways(); len(gateways) != 1 || gateways[0].Gateway.String() != "192.0.2.1" {
+		t.Errorf("defaultGateways = %v", gateways)
+	}
+}
+
+func TestReadRoute6Table(t *testing.T) {
+	table := `20010db8000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+20010db8000000000000000000000002 80 00000000000000000000000000000000 00 00000000000000000000000000000000 00000000 00000001 00000000 00000001     eth0
+ff000000000000000000000000000000 08 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+00000000000000000000000000000001 80 00000000000000000000000000000000 00 00000000000000000000000000000000 00000000 00000001 00000000 00000001       lo
+`
+	var got []string
+	for _, r := range readRoute6Table(strings.NewReader(table)) {
+		got = append(got, r.String())
+	}
+	want := []string{
+		"2001:db8::/64 dev eth0",
+		"default via fe80::1 dev eth0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("readRoute6Table =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
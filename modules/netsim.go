@@ -0,0 +1,398 @@
+package modules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	simOpen     = "open"
+	simClosed   = "closed"
+	simFiltered = "filtered"
+)
+
+// probeNetwork is what scans dial through: the live network, or a simulated
+// one declared in a fixture file.
+type probeNetwork interface {
+	dialTCP(address string, timeout time.Duration) (net.Conn, error)
+	hardwareAddr(ip net.IP) string
+	dialStack(address string, timeout time.Duration) (net.Conn, *tcpStack, error)
+	ping(ip net.IP, timeout time.Duration) (int, error)
+	arpSweep(ctx context.Context, targets []net.IP) (map[string]string, error)
+	hostname(ip net.IP) string
+	announcements(ctx context.Context, ipnet *net.IPNet, allowed func(net.IP) bool) (map[string]*announcement, error)
+	trace(ctx context.Context, ip net.IP, method string) ([]traceHop, error)
+}
+
+type liveNetwork struct {
+	src *sourceBinding
+}
+
+func (n liveNetwork) dialTCP(address string, timeout time.Duration) (net.Conn, error) {
+	return dialTCP(n.src, address, timeout)
+}
+
+func (n liveNetwork) hardwareAddr(ip net.IP) string {
+	return neighborMAC(ip)
+}
+
+func (n liveNetwork) dialStack(address string, timeout time.Duration) (net.Conn, *tcpStack, error) {
+	return dialStack(n.src, address, timeout)
+}
+
+func (n liveNetwork) ping(ip net.IP, timeout time.Duration) (int, error) {
+	return pingHost(n.src, ip, timeout)
+}
+
+func (n liveNetwork) arpSweep(ctx context.Context, targets []net.IP) (map[string]string, error) {
+	return arpSweep(ctx, n.src, targets)
+}
+
+func (n liveNetwork) hostname(ip net.IP) string {
+	return resolveHostname(n.src, ip)
+}
+
+func (n liveNetwork) announcements(ctx context.Context, ipnet *net.IPNet, allowed func(net.IP) bool) (map[string]*announcement, error) {
+	return listenAnnouncements(ctx, n.src, ipnet, allowed)
+}
+
+func (n liveNetwork) trace(ctx context.Context, ip net.IP, method string) ([]traceHop, error) {
+	return traceRoute(ctx, n.src, ip, method)
+}
+
+type simPort struct {
+	Port      int    `json:"port"`
+	State     string `json:"state"`
+	LatencyMS int    `json:"latency_ms,omitempty"`
+	Banner    string `json:"banner,omitempty"`
+}
+
+type simHost struct {
+	Address   string    `json:"address"`
+	MAC       string    `json:"mac,omitempty"`
+	Names     []string  `json:"names,omitempty"`
+	Default   string    `json:"default,omitempty"`
+	NoPing    bool      `json:"no_ping,omitempty"`
+	LatencyMS int       `json:"latency_ms,omitempty"`
+	Ports     []simPort `json:"ports"`
+	Announce  string    `json:"announce,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Services  []string  `json:"services,omitempty"`
+	TTL       int       `json:"ttl,omitempty"`
+	Window    int       `json:"window,omitempty"`
+	Options   string    `json:"tcp_options,omitempty"`
+	Route     []string  `json:"route,omitempty"`
+}
+
+// simFixture declares virtual hosts. Ports a host does not list take the
+// host's default state (closed when unset); undeclared hosts are filtered.
+// Hosts answer ICMP unless no_ping is set, and ARP when they have a MAC.
+// Hosts with announce, model or services advertise them over multicast.
+// Replies carry the host's ttl (64 when unset), and SYN-ACKs its window and
+// tcp_options, written as option letters in the order fingerprints use.
+// Traces pass the routers in route before reaching the host.
+type simFixture struct {
+	Hosts []simHost `json:"hosts"`
+}
+
+func (f simFixture) validate() error {
+	seen := map[string]bool{}
+	for _, host := range f.Hosts {
+		if net.ParseIP(host.Address) == nil {
+			return fmt.Errorf("host %q: address must be an IP address", host.Address)
+		}
+		for _, key := range append([]string{host.Address}, host.Names...) {
+			key = strings.ToLower(key)
+			if seen[key] {
+				return fmt.Errorf("host %q is declared twice", key)
+			}
+			seen[key] = true
+		}
+		if host.MAC != "" {
+			if _, err := net.ParseMAC(host.MAC); err != nil {
+				return fmt.Errorf("host %s: invalid MAC %q", host.Address, host.MAC)
+			}
+		}
+		for _, hop := range host.Route {
+			if net.ParseIP(hop) == nil {
+				return fmt.Errorf("host %s: route hop %q must be an IP address", host.Address, hop)
+			}
+		}
+		if err := validSimState(host.Default, true); err != nil {
+			return fmt.Errorf("host %s: %w", host.Address, err)
+		}
+		ports := map[int]bool{}
+		for _, p := range host.Ports {
+			if p.Port < 1 || p.Port > 65535 {
+				return fmt.Errorf("host %s: invalid port %d", host.Address, p.Port)
+			}
+			if ports[p.Port] {
+				return fmt.Errorf("host %s: port %d is declared twice", host.Address, p.Port)
+			}
+			ports[p.Port] = true
+			if err := validSimState(p.State, false); err != nil {
+				return fmt.Errorf("host %s port %d: %w", host.Address, p.Port, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validSimState(state string, optional bool) error {
+	switch state {
+	case simOpen, simClosed, simFiltered:
+		return nil
+	case "":
+		if optional {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid state %q, use open, closed or filtered", state)
+}
+
+func (f simFixture) targets() []string {
+	targets := make([]string, 0, len(f.Hosts))
+	for _, host := range f.Hosts {
+		targets = append(targets, host.Address)
+	}
+	return targets
+}
+
+func readFixture(r io.Reader) (simFixture, error) {
+	var f simFixture
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return f, fmt.Errorf("invalid fixture: %w", err)
+	}
+	if err := f.validate(); err != nil {
+		return f, fmt.Errorf("invalid fixture: %w", err)
+	}
+	return f, nil
+}
+
+func loadFixture(path string) (simFixture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return simFixture{}, err
+	}
+	defer file.Close()
+	return readFixture(file)
+}
+
+// sampleFixture is used by the Test Bench until a fixture file is loaded.
+func sampleFixture() simFixture {
+	return simFixture{Hosts: []simHost{
+		{Address: "10.99.0.10", MAC: "00:1b:21:3a:4f:10", Names: []string{"web.sim"}, Window: 65160, Options: "MSTNW", Ports: []simPort{
+			{Port: 22, State: simOpen, Banner: "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13\r\n"},
+			{Port: 80, State: simOpen, LatencyMS: 20, Banner: "HTTP/1.1 200 OK\r\n"},
+			{Port: 443, State: simOpen, LatencyMS: 20},
+			{Port: 8080, State: simFiltered},
+		}},
+		{Address: "10.99.0.20", Names: []string{"cache.sim"}, Default: simFiltered, Ports: []simPort{
+			{Port: 6379, State: simOpen, Banner: "+PONG\r\n"},
+		}},
+		{Address: "10.99.0.25", Names: []string{"printer.sim"}, Default: simFiltered,
+			Announce: "Office Printer", Model: "LaserJet 4250", Services: []string{"_ipp._tcp", "_printer._tcp"}},
+		{Address: "10.99.0.30", MAC: "00:50:56:9c:01:30", Names: []string{"desktop.sim"}, LatencyMS: 40, TTL: 128, Window: 64240, Options: "MNWNNS", Ports: []simPort{
+			{Port: 3389, State: simOpen},
+			{Port: 445, State: simOpen},
+		}},
+		{Address: "10.99.0.40", Names: []string{"slow.sim"}, Ports: []simPort{
+			{Port: 21, State: simOpen, LatencyMS: 1000, Banner: "220 ready\r\n"},
+		}},
+		{Address: "10.99.0.50", Names: []string{"quiet.sim"}, NoPing: true, Ports: []simPort{
+			{Port: 443, State: simOpen},
+		}},
+		{Address: "10.99.0.60", NoPing: true, Default: simFiltered,
+			Announce: "Living Room TV", Services: []string{"upnp:MediaRenderer"}},
+		{Address: "10.99.1.10", Names: []string{"files.sim"}, Route: []string{"10.99.0.1", "10.99.1.1"}, LatencyMS: 15, Window: 65535, Options: "MNWST", Ports: []simPort{
+			{Port: 22, State: simOpen, Banner: "SSH-2.0-OpenSSH_9.3 FreeBSD-20230316\r\n"},
+			{Port: 445, State: simOpen},
+		}},
+	}}
+}
+
+type simNetwork struct {
+	hosts map[string]simHost
+}
+
+func newSimNetwork(f simFixture) *simNetwork {
+	n := &simNetwork{hosts: map[string]simHost{}}
+	for _, host := range f.Hosts {
+		n.hosts[host.Address] = host
+		for _, name := range host.Names {
+			n.hosts[strings.ToLower(name)] = host
+		}
+	}
+	return n
+}
+
+func (n *simNetwork) lookup(address string) (simHost, simPort, error) {
+	hostPart, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		return simHost{}, simPort{}, err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return simHost{}, simPort{}, fmt.Errorf("invalid port %q", portText)
+	}
+	if ip := net.ParseIP(hostPart); ip != nil {
+		hostPart = ip.String()
+	}
+	host, ok := n.hosts[strings.ToLower(hostPart)]
+	if !ok {
+		return simHost{}, simPort{Port: port, State: simFiltered}, nil
+	}
+	for _, p := range host.Ports {
+		if p.Port == port {
+			if p.LatencyMS == 0 {
+				p.LatencyMS = host.LatencyMS
+			}
+			return host, p, nil
+		}
+	}
+	state := host.Default
+	if state == "" {
+		state = simClosed
+	}
+	return host, simPort{Port: port, State: state, LatencyMS: host.LatencyMS}, nil
+}
+
+// hardwareAddr reports the fixture MAC; hosts without one are treated as
+// routed.
+func (n *simNetwork) hardwareAddr(ip net.IP) string {
+	if host, ok := n.hosts[ip.String()]; ok && host.MAC != "" {
+		return host.MAC
+	}
+	return macRouted
+}
+
+func (n *simNetwork) ping(ip net.IP, timeout time.Duration) (int, error) {
+	host, ok := n.hosts[ip.String()]
+	if !ok || host.NoPing {
+		return 0, nil
+	}
+	latency := time.Duration(host.LatencyMS) * time.Millisecond
+	if latency >= timeout {
+		time.Sleep(timeout)
+		return 0, nil
+	}
+	time.Sleep(latency)
+	return host.ttl(), nil
+}
+
+func (h simHost) ttl() int {
+	if h.TTL == 0 {
+		return 64
+	}
+	return h.TTL
+}
+
+func (n *simNetwork) dialStack(address string, timeout time.Duration) (net.Conn, *tcpStack, error) {
+	conn, err := n.dialTCP(address, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	host, _, _ := n.lookup(address)
+	return conn, &tcpStack{TTL: host.ttl(), Window: host.Window, Options: host.Options}, nil
+}
+
+func (n *simNetwork) hostname(ip net.IP) string {
+	if host, ok := n.hosts[ip.String()]; ok && len(host.Names) > 0 {
+		return host.Names[0]
+	}
+	return ""
+}
+
+func (n *simNetwork) announcements(ctx context.Context, ipnet *net.IPNet, allowed func(net.IP) bool) (map[string]*announcement, error) {
+	found := map[string]*announcement{}
+	for key, host := range n.hosts {
+		ip := net.ParseIP(host.Address)
+		if key != host.Address || !ipnet.Contains(ip) || !allowed(ip) {
+			continue
+		}
+		if host.Announce != "" || host.Model != "" || len(host.Services) > 0 {
+			found[key] = &announcement{Name: host.Announce, Model: host.Model, Services: host.Services}
+		}
+	}
+	return found, nil
+}
+
+// trace passes the host's route, then reaches the host unless the probe
+// would be dropped: ICMP when it ignores pings, UDP and TCP when the probed
+// port is filtered.
+func (n *simNetwork) trace(ctx context.Context, ip net.IP, method string) ([]traceHop, error) {
+	host, ok := n.hosts[ip.String()]
+	if !ok {
+		return []traceHop{{TTL: 1}}, nil
+	}
+	var hops []traceHop
+	share := float64(host.LatencyMS) / float64(len(host.Route)+1)
+	for i, hop := range host.Route {
+		hops = append(hops, traceHop{TTL: i + 1, IP: hop, RTTMS: share * float64(i+1)})
+	}
+	reached := !host.NoPing
+	switch method {
+	case traceUDP:
+		_, port, _ := n.lookup(net.JoinHostPort(host.Address, strconv.Itoa(tracePortBase+len(hops)+1)))
+		reached = port.State != simFiltered
+	case traceTCP:
+		_, port, _ := n.lookup(net.JoinHostPort(host.Address, strconv.Itoa(tracePort)))
+		reached = port.State != simFiltered
+	}
+	last := traceHop{TTL: len(hops) + 1}
+	if reached {
+		last.IP, last.RTTMS = host.Address, float64(host.LatencyMS)
+	}
+	return append(hops, last), nil
+}
+
+// arpSweep treats the hosts with a MAC as the local segment.
+func (n *simNetwork) arpSweep(ctx context.Context, targets []net.IP) (map[string]string, error) {
+	results := map[string]string{}
+	for _, ip := range targets {
+		if host, ok := n.hosts[ip.String()]; ok && host.MAC != "" {
+			results[ip.String()] = host.MAC
+		}
+	}
+	return results, nil
+}
+
+// dialTCP answers from the fixture. Filtered ports fail with a timeout
+// straight away rather than after the timeout, so runs stay fast; a latency
+// at or above the timeout is reported as a timeout after waiting it out.
+func (n *simNetwork) dialTCP(address string, timeout time.Duration) (net.Conn, error) {
+	_, port, err := n.lookup(address)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+	latency := time.Duration(port.LatencyMS) * time.Millisecond
+	if latency >= timeout {
+		time.Sleep(timeout)
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	}
+	time.Sleep(latency)
+
+	switch port.State {
+	case simOpen:
+		client, server := net.Pipe()
+		go io.Copy(io.Discard, server)
+		go func() {
+			if port.Banner != "" {
+				server.Write([]byte(port.Banner))
+			}
+		}()
+		return client, nil
+	case simClosed:
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	default:
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	}
+}
@@ -0,0 +1,219 @@
+package modules
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSimScanPorts(t *testing.T) {
+	nw := newSimNetwork(sampleFixture())
+	tests := []struct {
+		target string
+		other  string
+		ports  map[int]string
+	}{
+		{"10.99.0.10", "closed", map[int]string{22: "open", 80: "open", 443: "open", 8080: "filtered (timeout)"}},
+		{"web.sim", "closed", map[int]string{22: "open", 80: "open", 443: "open", 8080: "filtered (timeout)"}},
+		{"10.99.0.20", "filtered (timeout)", map[int]string{6379: "open"}},
+		{"10.99.0.40", "closed", map[int]string{21: "filtered (timeout)"}},
+		{"10.99.0.99", "filtered (timeout)", nil},
+	}
+	for _, tt := range tests {
+		statuses, canceled := scanPorts(context.Background(), nw, tt.target, nil)
+		if canceled {
+			t.Errorf("%s: canceled", tt.target)
+		}
+		if len(statuses) != len(portCatalog()) {
+			t.Errorf("%s: %d statuses, want %d", tt.target, len(statuses), len(portCatalog()))
+		}
+		for _, ps := range statuses {
+			want, ok := tt.ports[ps.Port]
+			if !ok {
+				want = tt.other
+			}
+			if ps.Status != want {
+				t.Errorf("%s port %d: %q, want %q", tt.target, ps.Port, ps.Status, want)
+			}
+		}
+	}
+}
+
+func TestSimScanHosts(t *testing.T) {
+	nw := newSimNetwork(sampleFixture())
+	hosts := []string{"10.99.0.10", "10.99.0.30"}
+	statuses, skipped, canceled := scanHosts(context.Background(), nw, hosts, true, nil)
+	if canceled || len(skipped) > 0 {
+		t.Fatalf("canceled %v, skipped %v", canceled, skipped)
+	}
+	var open []string
+	for _, ps := range statuses {
+		if ps.Status == "open" {
+			open = append(open, ps.label())
+		}
+	}
+	want := []string{
+		"10.99.0.10 22/tcp (SSH)",
+		"10.99.0.10 80/tcp (HTTP)",
+		"10.99.0.10 443/tcp (HTTPS)",
+		"10.99.0.30 3389/tcp (RDP)",
+	}
+	if !reflect.DeepEqual(open, want) {
+		t.Errorf("open ports = %v, want %v", open, want)
+	}
+}
+
+func TestSimScanHostsAfterScopeWindow(t *testing.T) {
+	if err := defaultScope.set(engagementScope{Allowed: []string{"10.99.0.0/16"}, NotAfter: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { defaultScope.set(engagementScope{}) })
+
+	nw := newSimNetwork(sampleFixture())
+	updates := 0
+	statuses, skipped, _ := scanHosts(context.Background(), nw, []string{"10.99.0.10", "web.sim"}, true, func(host string, port int, status string) {
+		if status != statusOutOfScope {
+			t.Errorf("%s:%d updated to %q", host, port, status)
+		}
+		updates++
+	})
+	if len(statuses) != 0 || len(skipped) != 2 {
+		t.Errorf("got %d statuses and %v skipped, want every host skipped", len(statuses), skipped)
+	}
+	if updates != 2*len(portCatalog()) {
+		t.Errorf("%d updates, want one per port of each host", updates)
+	}
+}
+
+// mapFixture has one host for each way of being found: only over ARP, only
+// by ping, only by an open port, and only by announcing itself.
+func mapFixture() simFixture {
+	return simFixture{Hosts: []simHost{
+		{Address: "10.77.0.1", MAC: "02:00:00:00:00:01", NoPing: true, Default: simFiltered},
+		{Address: "10.77.0.2", Default: simFiltered},
+		{Address: "10.77.0.3", NoPing: true, Ports: []simPort{{Port: 22, State: simOpen}}},
+		{Address: "10.77.0.4", NoPing: true, Default: simFiltered, Announce: "Speaker", Services: []string{"_airplay._tcp"}},
+		{Address: "10.77.0.5", Ports: []simPort{{Port: 22, State: simOpen}}},
+	}}
+}
+
+func TestSimMapSubnet(t *testing.T) {
+	nw := newSimNetwork(mapFixture())
+	_, ipnet, _ := net.ParseCIDR("10.77.0.0/29")
+	excluded, _ := parseAddressSet([]string{"10.77.0.5"})
+	tests := []struct {
+		strategy string
+		want     []string
+	}{
+		{discoveryTCP, []string{"10.77.0.3"}},
+		{discoveryICMP, []string{"10.77.0.2", "10.77.0.4"}},
+		{discoveryBoth, []string{"10.77.0.2", "10.77.0.3", "10.77.0.4"}},
+		{discoveryARP, []string{"10.77.0.1", "10.77.0.2", "10.77.0.3", "10.77.0.4"}},
+	}
+	for _, tt := range tests {
+		streamed := map[string]bool{}
+		handled, total := 0, 0
+		devices, skipped, canceled := mapSubnet(context.Background(), newHostDiscovery(nw, tt.strategy), ipnet, excluded,
+			func(device networkDevice) { streamed[device.IP] = true },
+			nil,
+			func(done, of int) { handled, total = done, of })
+		if canceled {
+			t.Errorf("%s: canceled", tt.strategy)
+		}
+		var got []string
+		for _, device := range devices {
+			got = append(got, device.IP)
+			if !streamed[device.IP] {
+				t.Errorf("%s: %s was not streamed", tt.strategy, device.IP)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: devices = %v, want %v", tt.strategy, got, tt.want)
+		}
+		if len(streamed) != len(devices) {
+			t.Errorf("%s: streamed %d devices, returned %d", tt.strategy, len(streamed), len(devices))
+		}
+		if len(skipped) != 1 || skipped[0].Host != "10.77.0.5" || skipped[0].Status != statusExcluded {
+			t.Errorf("%s: skipped = %v, want 10.77.0.5 excluded", tt.strategy, skipped)
+		}
+		if handled != 6 || total != 6 {
+			t.Errorf("%s: progress %d/%d, want 6/6", tt.strategy, handled, total)
+		}
+	}
+}
+
+func TestSimMapSubnetDevices(t *testing.T) {
+	nw := newSimNetwork(sampleFixture())
+	_, ipnet, _ := net.ParseCIDR("10.99.0.0/24")
+	devices, _, _ := mapSubnet(context.Background(), newHostDiscovery(nw, discoveryBoth), ipnet, addressSet{}, nil, nil, nil)
+	byIP := map[string]networkDevice{}
+	for _, device := range devices {
+		byIP[device.IP] = device
+	}
+	tests := []struct {
+		ip, hostname, mac, os string
+	}{
+		{"10.99.0.10", "web.sim", "00:1b:21:3a:4f:10", "Linux"},
+		{"10.99.0.30", "desktop.sim", "00:50:56:9c:01:30", "Windows"},
+		{"10.99.0.25", "printer.sim", macRouted, "Unknown"},
+		{"10.99.0.60", "", macRouted, "Unknown"},
+	}
+	for _, tt := range tests {
+		device, ok := byIP[tt.ip]
+		if !ok {
+			t.Errorf("%s not found", tt.ip)
+			continue
+		}
+		if device.Hostname != tt.hostname || device.MAC != tt.mac || device.OS != tt.os {
+			t.Errorf("%s = %q %q %q, want %q %q %q", tt.ip, device.Hostname, device.MAC, device.OS, tt.hostname, tt.mac, tt.os)
+		}
+	}
+}
+
+func TestSimMapSubnetStopped(t *testing.T) {
+	nw := newSimNetwork(sampleFixture())
+	_, ipnet, _ := net.ParseCIDR("10.99.0.0/24")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	streamed := 0
+	devices, _, canceled := mapSubnet(ctx, newHostDiscovery(nw, discoveryBoth), ipnet, addressSet{}, func(networkDevice) {
+		streamed++
+		cancel()
+	}, nil, nil)
+	if !canceled {
+		t.Fatal("run was not stopped")
+	}
+	if len(devices) != streamed {
+		t.Errorf("returned %d devices, %d were streamed", len(devices), streamed)
+	}
+}
+
+func TestSimCheckVulnerabilities(t *testing.T) {
+	nw := newSimNetwork(sampleFixture())
+	tests := []struct {
+		target     string
+		categories []string
+		want       []string
+	}{
+		{"10.99.0.10", checkCategories(), []string{"SSH (22/tcp)", "HTTP (80/tcp)", "HTTPS (443/tcp)"}},
+		{"10.99.0.30", checkCategories(), []string{"RDP (3389/tcp)"}},
+		{"10.99.0.20", checkCategories(), []string{"Redis (6379/tcp)", "Redis unauthenticated (6379/tcp)"}},
+		{"10.99.0.20", []string{"safe"}, []string{"Redis (6379/tcp)"}},
+		{"10.99.0.99", checkCategories(), []string{"Informational"}},
+	}
+	for _, tt := range tests {
+		findings, canceled := checkVulnerabilities(context.Background(), nw, tt.target, tt.categories, nil)
+		if canceled {
+			t.Errorf("%s: canceled", tt.target)
+		}
+		var got []string
+		for _, f := range findings {
+			got = append(got, f.Service)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: findings = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
@@ -2,39 +2,127 @@ package modules
 
 import (
 	"context"
-	"crypto/md5"
 	"fmt"
 	"net"
-	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
 )
 
+const maxSubnetChoices = 6
+
 type networkMapperModule struct {
 	content     fyne.CanvasObject
 	subnetEntry *widget.Entry
+	subnetBox   *fyne.Container
+	sourceEntry *widget.SelectEntry
+	discovery   *widget.Select
 	runButton   *widget.Button
+	pauseButton *widget.Button
 	statusLabel *widget.Label
+	vendorLabel *widget.Label
+	traceMethod *widget.Select
 	resultsList *widget.List
 	devices     []networkDevice
+	mapped      *net.IPNet
+	selected    int
 	cancel      context.CancelFunc
+	control     *runControl
 	running     bool
 }
 
 type networkDevice struct {
-	IP     string
-	MAC    string
-	Vendor string
-	OS     string
+	IP           string     `json:"ip"`
+	Hostname     string     `json:"hostname,omitempty"`
+	MAC          string     `json:"mac"`
+	Vendor       string     `json:"vendor"`
+	Class        string     `json:"class,omitempty"`
+	OS           string     `json:"os"`
+	OSConfidence int        `json:"os_confidence,omitempty"`
+	Fingerprint  string     `json:"fingerprint,omitempty"`
+	Name         string     `json:"friendly_name,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	Services     []string   `json:"services,omitempty"`
+	Route        []traceHop `json:"route,omitempty"`
+	Status       string     `json:"-"`
+}
+
+// merge adds what the device announced about itself.
+func (dev *networkDevice) merge(a *announcement) {
+	if a == nil {
+		return
+	}
+	if dev.Hostname == "" {
+		dev.Hostname = a.Hostname
+	}
+	dev.Name = a.Name
+	dev.Model = a.Model
+	dev.Services = a.Services
+}
+
+// details lists what is known about the device, one field per line.
+func (dev networkDevice) details() string {
+	lines := []string{"Address: " + dev.IP}
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, label+": "+value)
+		}
+	}
+	add("Hostname", dev.Hostname)
+	add("MAC", dev.MAC)
+	add("Vendor", dev.Vendor)
+	add("Address class", dev.Class)
+	if dev.OSConfidence > 0 {
+		add("OS", fmt.Sprintf("%s (%d%% confidence)", dev.OS, dev.OSConfidence))
+	} else {
+		add("OS", dev.OS)
+	}
+	add("Fingerprint", dev.Fingerprint)
+	add("Name", dev.Name)
+	add("Model", dev.Model)
+	add("Services", strings.Join(dev.Services, ", "))
+	add("Route", routeText(dev.Route))
+	return strings.Join(lines, "\n")
+}
+
+func (dev networkDevice) row() string {
+	if dev.Status != "" {
+		return fmt.Sprintf("%-15s %s", dev.IP, dev.Status)
+	}
+	hostname, vendor := dev.Hostname, dev.Vendor
+	if hostname == "" {
+		hostname = "-"
+	}
+	if vendor == "" {
+		vendor = "-"
+	}
+	osName := dev.OS
+	if dev.OSConfidence > 0 {
+		osName = fmt.Sprintf("%s (%d%%)", dev.OS, dev.OSConfidence)
+	}
+	row := fmt.Sprintf("%-15s %-24s %-18s %-28s %-22s %s", dev.IP, hostname, dev.MAC, vendor, dev.Class, osName)
+	var details []string
+	for _, s := range []string{dev.Name, dev.Model} {
+		if s != "" {
+			details = append(details, s)
+		}
+	}
+	if len(dev.Services) > 0 {
+		details = append(details, "["+strings.Join(dev.Services, ", ")+"]")
+	}
+	if len(details) > 0 {
+		row += "  " + strings.Join(details, " ")
+	}
+	return row
 }
 
 func (m *networkMapperModule) Name() string {
-	return "Network Mapper"
+	return moduleMapper
 }
 
 func (m *networkMapperModule) Content() fyne.CanvasObject {
@@ -44,13 +132,24 @@ func (m *networkMapperModule) Content() fyne.CanvasObject {
 
 	m.subnetEntry = widget.NewEntry()
 	m.subnetEntry.SetPlaceHolder("Subnet (e.g. 192.168.1.0/24)")
+	m.subnetBox = container.NewHBox()
+	m.refreshSubnets()
 
+	m.sourceEntry = newSourceEntry()
+	m.discovery = widget.NewSelect(discoveryOptions(), nil)
+	m.discovery.SetSelectedIndex(0)
 	m.runButton = widget.NewButton("Run Network Mapper", m.toggleRun)
 
 	entryField := container.New(layout.NewGridWrapLayout(fyne.NewSize(260, m.subnetEntry.MinSize().Height)), m.subnetEntry)
 	buttonWrap := container.New(layout.NewGridWrapLayout(fyne.NewSize(200, m.runButton.MinSize().Height)), m.runButton)
 	buttonSpacer := container.New(layout.NewGridWrapLayout(fyne.NewSize(12, m.runButton.MinSize().Height)), widget.NewLabel(""))
-	entryRow := container.NewHBox(entryField, buttonSpacer, buttonWrap, layout.NewSpacer())
+	sourceField := container.New(layout.NewGridWrapLayout(fyne.NewSize(240, m.subnetEntry.MinSize().Height)), m.sourceEntry)
+	sourceSpacer := container.New(layout.NewGridWrapLayout(fyne.NewSize(12, m.runButton.MinSize().Height)), widget.NewLabel(""))
+	discoveryField := container.New(layout.NewGridWrapLayout(fyne.NewSize(140, m.subnetEntry.MinSize().Height)), m.discovery)
+	m.pauseButton = widget.NewButton("Pause", m.togglePause)
+	m.pauseButton.Disable()
+	pauseWrap := container.New(layout.NewGridWrapLayout(fyne.NewSize(100, m.runButton.MinSize().Height)), m.pauseButton)
+	entryRow := container.NewHBox(entryField, sourceSpacer, sourceField, discoveryField, buttonSpacer, buttonWrap, pauseWrap, layout.NewSpacer())
 
 	m.statusLabel = widget.NewLabel("Idle. Provide a subnet and click Run.")
 
@@ -58,20 +157,34 @@ func (m *networkMapperModule) Content() fyne.CanvasObject {
 		func() int { return len(m.devices) },
 		func() fyne.CanvasObject { return widget.NewLabel("") },
 		func(i int, obj fyne.CanvasObject) {
-			dev := m.devices[i]
-			obj.(*widget.Label).SetText(fmt.Sprintf("%-15s %-18s %-20s %s", dev.IP, dev.MAC, dev.Vendor, dev.OS))
+			obj.(*widget.Label).SetText(m.devices[i].row())
 		},
 	)
+	m.selected = -1
+	m.resultsList.OnSelected = func(id widget.ListItemID) { m.selected = id }
+	m.resultsList.OnUnselected = func(widget.ListItemID) { m.selected = -1 }
 
 	scroll := container.NewVScroll(m.resultsList)
 	scroll.SetMinSize(fyne.NewSize(0, 300))
 
+	m.vendorLabel = widget.NewLabel(defaultVendors.describe())
+	vendorButton := widget.NewButton("Update Vendors...", m.importVendors)
+	sendButton := widget.NewButton("Send to Scanner", m.sendToScanner)
+	m.traceMethod = widget.NewSelect(traceOptions(), nil)
+	m.traceMethod.SetSelectedIndex(0)
+	traceButton := widget.NewButton("Trace Routes", m.traceRoutes)
+	topologyButton := widget.NewButton("Topology", m.showTopology)
+	traceField := container.New(layout.NewGridWrapLayout(fyne.NewSize(110, m.subnetEntry.MinSize().Height)), m.traceMethod)
+	vendorRow := container.NewHBox(m.vendorLabel, layout.NewSpacer(), traceField, traceButton, topologyButton, sendButton, vendorButton)
+
 	m.content = container.NewVBox(
 		widget.NewLabelWithStyle("Network Mapper", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		widget.NewLabel("Automatically discover devices on a target subnet."),
 		entryRow,
+		m.subnetBox,
+		newProxyNotice(moduleMapper),
 		m.statusLabel,
-		widget.NewCard("Discovered Devices", "IP/MAC/vendor/OS fingerprinting results.", container.NewMax(scroll)),
+		widget.NewCard("Discovered Devices", "IP/hostname/MAC/vendor/address class/OS fingerprinting results. Select a device to trace or send only that one.", container.NewVBox(container.NewMax(scroll), vendorRow)),
 	)
 
 	return m.content
@@ -104,58 +217,312 @@ func (m *networkMapperModule) toggleRun() {
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	m.cancel = cancel
-	m.devices = nil
-	m.resultsList.Refresh()
-	m.setRunning(true)
-	m.setStatus(fmt.Sprintf("Mapping %s ...", normalized))
+	if err := checkScopeSubnet(moduleMapper, ipnet); err != nil {
+		m.setStatus(fmt.Sprintf("Refused: %v.", err))
+		return
+	}
+
+	src, err := parseSource(m.sourceEntry.Text)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Invalid source: %v.", err))
+		return
+	}
 
-	go m.performMapping(ctx, ipnet)
+	ranges := sensitiveNetwork(ipnet)
+	discovery, _ := parseDiscovery(m.discovery.Selected)
+	spec := jobSpec{Module: moduleMapper, Target: ipnet.String(), Source: src.source(), Discovery: discovery}
+	err = confirmRun(spec, ranges, func(acknowledged []string) {
+		spec.Acknowledged = acknowledged
+		m.control = newRunControl()
+		ctx, cancel := guardContext(withRunControl(context.Background(), m.control))
+		m.cancel = cancel
+		m.devices = nil
+		m.mapped = ipnet
+		m.selected = -1
+		m.resultsList.UnselectAll()
+		m.resultsList.Refresh()
+		m.setRunning(true)
+		m.setStatus(fmt.Sprintf("Mapping %s ...", normalized))
+
+		go func() {
+			defer cancel()
+			m.performMapping(ctx, spec, src, ipnet, ranges)
+		}()
+	})
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Refused: %v.", err))
+	}
 }
 
-func (m *networkMapperModule) performMapping(ctx context.Context, ipnet *net.IPNet) {
-	const maxHosts = 256
+func (m *networkMapperModule) performMapping(ctx context.Context, spec jobSpec, src *sourceBinding, ipnet *net.IPNet, ranges []sensitiveRange) {
+	record := beginRun(spec, "")
+	excluded, err := defaultExclusions.current().compile()
+	if err == nil {
+		err = preflight(spec, ranges, &record)
+	}
+	if err != nil {
+		record.finish(false, err)
+		endRun(record)
+		m.queueStatus(fmt.Sprintf("Mapping failed: %v.", err))
+		m.setRunning(false)
+		return
+	}
+	rc := runControlFrom(ctx)
+	rc.track(spec, record, nil)
+	discovery := newHostDiscovery(liveNetwork{src: src}, spec.Discovery)
+	responded := 0
+	found := func(device networkDevice) {
+		responded++
+		m.queueAppendDevice(device)
+	}
+	devices, skipped, canceled := mapSubnet(ctx, discovery, ipnet, excluded, found, func(skip skippedHost) {
+		m.queueAppendDevice(networkDevice{IP: skip.Host, Status: skip.reason()})
+	}, func(done, total int) {
+		m.queueStatus(fmt.Sprintf("Mapping %s ... %d/%d addresses probed, %d host(s) responded.", ipnet, done, total, responded))
+	})
+	discovery.note(&record)
+	record.Devices = devices
+	record.Skipped = skipped
+	record.finish(canceled, guardCause(ctx))
+	rc.finish(record)
+	endRun(record)
+
+	skippedNote := ""
+	if len(skipped) > 0 {
+		skippedNote = fmt.Sprintf(" %d host(s) skipped.", len(skipped))
+	}
+	switch {
+	case canceled:
+		m.queueStatus("Network mapper stopped.")
+	case len(devices) == 0:
+		m.queueStatus("Mapping finished. No responsive hosts detected." + skippedNote)
+	default:
+		m.queueStatus(fmt.Sprintf("Mapping finished. %d host(s) responded.%s", len(devices), skippedNote))
+	}
+	m.setRunning(false)
+}
+
+// mapBatch is how many addresses the mapper plans ahead, so that the ARP
+// sweep covers them in one go. Up to mapWorkers addresses of a batch are
+// probed at once, each holding at most a dial and a raw socket.
+const (
+	mapBatch   = 256
+	mapWorkers = 64
+)
+
+type mapStep struct {
+	ip   net.IP
+	skip *skippedHost
+}
+
+func mapConcurrency() int {
+	return max(1, min(mapWorkers, defaultGuard.current().MaxOpenSockets/2))
+}
+
+type mapResult struct {
+	index  int
+	device *networkDevice
+}
+
+// mapSubnet probes the subnet a batch at a time. Workers hand their results
+// to the calling goroutine, which is the only one to call found, skip and
+// progress. Devices reach found as they respond, while the checkpoint
+// advances in address order, so a resumed run starts after the last address
+// every earlier one was handled for. progress receives the number of
+// addresses handled out of the total.
+func mapSubnet(ctx context.Context, discovery *hostDiscovery, ipnet *net.IPNet, excluded addressSet, found func(networkDevice), skip func(skippedHost), progress func(done, total int)) ([]networkDevice, []skippedHost, bool) {
+	guard := defaultGuard.current()
+	maxHosts := guard.MaxHosts
+	workers := mapConcurrency()
+	probed := 0
 	cur := append(net.IP(nil), ipnet.IP...)
 	broadcast := broadcastIP(ipnet)
-	discovered := 0
+	scope := defaultScope.current()
+	rc := runControlFrom(ctx)
+	prior := rc.resumed()
+	var devices []networkDevice
+	var skipped []skippedHost
+	handled := 0
+	total := subnetSize(ipnet)
+	report := func() {
+		handled++
+		if progress != nil {
+			progress(handled, total)
+		}
+	}
+	skipHost := func(host skippedHost) {
+		skipped = append(skipped, host)
+		rc.progress(func(cp *runCheckpoint) {
+			cp.Cursor = host.Host
+			cp.Record.Skipped = append(cp.Record.Skipped, host)
+		})
+		if skip != nil {
+			skip(host)
+		}
+		report()
+	}
 
-	for {
-		cur = incrementIP(cur)
-		if !ipnet.Contains(cur) || cur.Equal(broadcast) {
-			break
+	if last := net.ParseIP(prior.Cursor); last != nil && ipnet.Contains(last) {
+		if len(cur) == net.IPv4len {
+			last = last.To4()
 		}
+		cur = last
+		probed = prior.Done
+		devices = append(devices, prior.Record.Devices...)
+		skipped = append(skipped, prior.Record.Skipped...)
+		handled = probed + len(skipped)
+		for _, device := range devices {
+			if found != nil {
+				found(device)
+			}
+		}
+		for _, host := range skipped {
+			if skip != nil {
+				skip(host)
+			}
+		}
+	}
 
-		select {
-		case <-ctx.Done():
-			m.queueStatus("Network mapper stopped.")
-			m.setRunning(false)
-			return
-		default:
+	discovery.listen(ctx, ipnet, func(ip net.IP) bool {
+		return scope.checkIP(ip) == nil && excluded.excludedIP(ip) == ""
+	})
+
+	inspect := func(ip net.IP) *networkDevice {
+		probe, ok := discovery.probe(ip)
+		if !ok {
+			return nil
 		}
+		mac := discovery.mac(ip)
+		match := probe.fingerprint(defaultSignatures)
+		device := &networkDevice{
+			IP:           ip.String(),
+			Hostname:     discovery.nw.hostname(ip),
+			MAC:          mac,
+			Vendor:       defaultVendors.lookup(mac),
+			Class:        addressClass(ip),
+			OS:           match.Name,
+			OSConfidence: match.Confidence,
+			Fingerprint:  match.Evidence,
+		}
+		device.merge(discovery.announced[ip.String()])
+		return device
+	}
 
-		if checkHost(cur.String()) {
-			device := networkDevice{
-				IP:     cur.String(),
-				MAC:    pseudoMACFromIP(cur),
-				Vendor: guessVendorFromIP(cur),
-				OS:     guessOS(cur.String()),
+	for finished := false; !finished; {
+		var steps []mapStep
+		var targets []net.IP
+		for len(steps) < mapBatch {
+			cur = incrementIP(cur)
+			if !ipnet.Contains(cur) || cur.Equal(broadcast) {
+				finished = true
+				break
+			}
+			if scope.checkIP(cur) != nil {
+				steps = append(steps, mapStep{ip: cur, skip: &skippedHost{Host: cur.String(), Status: statusOutOfScope}})
+				continue
 			}
-			discovered++
-			m.queueAppendDevice(device)
+			if entry := excluded.excludedIP(cur); entry != "" {
+				steps = append(steps, mapStep{ip: cur, skip: &skippedHost{Host: cur.String(), Status: statusExcluded, Entry: entry}})
+				continue
+			}
+			if probed+len(targets) == maxHosts {
+				finished = true
+				break
+			}
+			steps = append(steps, mapStep{ip: cur})
+			targets = append(targets, cur)
 		}
 
-		if discovered >= maxHosts {
-			break
+		if !rc.wait(ctx) {
+			return devices, skipped, true
+		}
+		discovery.sweep(ctx, targets)
+
+		// The dispatcher stops starting workers when the run is stopped and
+		// closes results once the running ones are done, so every device
+		// that was found is collected.
+		results := make(chan mapResult)
+		go func() {
+			var wg sync.WaitGroup
+			sem := make(chan struct{}, workers)
+			for i, step := range steps {
+				if step.skip != nil {
+					continue
+				}
+				if !rc.wait(ctx) {
+					break
+				}
+				sem <- struct{}{}
+				wg.Add(1)
+				go func(i int, ip net.IP) {
+					defer wg.Done()
+					results <- mapResult{index: i, device: inspect(ip)}
+					<-sem
+				}(i, step.ip)
+			}
+			wg.Wait()
+			close(results)
+		}()
+
+		done := make([]bool, len(steps))
+		answers := make([]*networkDevice, len(steps))
+		next := 0
+		commit := func() {
+			for ; next < len(steps); next++ {
+				step := steps[next]
+				if step.skip != nil {
+					skipHost(*step.skip)
+					continue
+				}
+				if !done[next] {
+					return
+				}
+				probed++
+				device := answers[next]
+				if device != nil {
+					devices = append(devices, *device)
+				}
+				rc.progress(func(cp *runCheckpoint) {
+					cp.Cursor = step.ip.String()
+					cp.Done = probed
+					if device != nil {
+						cp.Record.Devices = append(cp.Record.Devices, *device)
+					}
+				})
+			}
+		}
+		commit()
+		for result := range results {
+			done[result.index] = true
+			answers[result.index] = result.device
+			if result.device != nil && found != nil {
+				found(*result.device)
+			}
+			report()
+			commit()
+		}
+		if ctx.Err() != nil {
+			for i := next; i < len(steps); i++ {
+				if answers[i] != nil {
+					devices = append(devices, *answers[i])
+				}
+			}
+			return devices, skipped, true
 		}
 	}
 
-	if discovered == 0 {
-		m.queueStatus("Mapping finished. No responsive hosts detected.")
-	} else {
-		m.queueStatus(fmt.Sprintf("Mapping finished. %d host(s) responded.", discovered))
+	return devices, skipped, false
+}
+
+// subnetSize is the number of host addresses in the subnet, leaving out the
+// network and broadcast addresses of IPv4 subnets.
+func subnetSize(ipnet *net.IPNet) int {
+	ones, bits := ipnet.Mask.Size()
+	size := 1 << min(bits-ones, 48)
+	if bits == 32 && bits-ones > 1 {
+		size -= 2
 	}
-	m.setRunning(false)
+	return size
 }
 
 func (m *networkMapperModule) queueAppendDevice(device networkDevice) {
@@ -177,8 +544,238 @@ func (m *networkMapperModule) setStatus(text string) {
 	}
 }
 
+// sendToScanner hands the selected device, or every responsive device, to the
+// Scanner by hostname where the name resolves back to the device.
+func (m *networkMapperModule) sendToScanner() {
+	var devices []networkDevice
+	for i, dev := range m.devices {
+		if dev.Status == "" && (m.selected < 0 || m.selected == i) {
+			devices = append(devices, dev)
+		}
+	}
+	if len(devices) == 0 {
+		m.setStatus("No discovered devices to send to the Scanner.")
+		return
+	}
+	m.setStatus("Resolving device names ...")
+	go func() {
+		hosts := make([]string, 0, len(devices))
+		for _, dev := range devices {
+			hosts = append(hosts, dev.scanName())
+		}
+		m.queueOnMain(func() {
+			m.setStatus(fmt.Sprintf("Sent %d device(s) to the Scanner.", len(hosts)))
+			sendToScanner(hosts)
+		})
+	}()
+}
+
+// refreshSubnets offers the networks this machine is on, and suggests the
+// first as the placeholder.
+func (m *networkMapperModule) refreshSubnets() {
+	suggestions := detectNetwork().suggestedSubnets()
+	objects := []fyne.CanvasObject{widget.NewLabel("Local subnets:")}
+	if len(suggestions) == 0 {
+		objects = append(objects, widget.NewLabel("none detected"))
+	}
+	for i, s := range suggestions {
+		if i == maxSubnetChoices {
+			break
+		}
+		cidr := s.CIDR
+		objects = append(objects, widget.NewButton(s.label(), func() { m.subnetEntry.SetText(cidr) }))
+	}
+	objects = append(objects, widget.NewButton("Refresh", m.refreshSubnets))
+	m.subnetBox.Objects = objects
+	m.subnetBox.Refresh()
+	if len(suggestions) > 0 {
+		m.subnetEntry.SetPlaceHolder(fmt.Sprintf("Subnet (e.g. %s)", suggestions[0].CIDR))
+	}
+}
+
+// traceRoutes traces the route to the selected device, or to every
+// responsive device, then shows the topology. Traces are runs like any
+// other: scope checked, confirmed, recorded and stopped with the run button.
+func (m *networkMapperModule) traceRoutes() {
+	if m.running {
+		return
+	}
+	method, _ := parseTrace(m.traceMethod.Selected)
+	src, err := parseSource(m.sourceEntry.Text)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Invalid source: %v.", err))
+		return
+	}
+	var targets []int
+	for i, dev := range m.devices {
+		if dev.Status == "" && (m.selected < 0 || m.selected == i) {
+			targets = append(targets, i)
+		}
+	}
+	if len(targets) == 0 {
+		m.setStatus("No discovered devices to trace.")
+		return
+	}
+	ips := make([]string, len(targets))
+	for i, idx := range targets {
+		ips[i] = m.devices[idx].IP
+		if _, err := checkScope(moduleTraceroute, ips[i]); err != nil {
+			m.setStatus(fmt.Sprintf("Refused: %v.", err))
+			return
+		}
+	}
+
+	ranges := sensitiveAddresses(ips)
+	spec := jobSpec{Module: moduleTraceroute, Target: strings.Join(ips, ", "), Source: src.source(), Trace: method}
+	err = confirmRun(spec, ranges, func(acknowledged []string) {
+		spec.Acknowledged = acknowledged
+		m.control = newRunControl()
+		ctx, cancel := guardContext(withRunControl(context.Background(), m.control))
+		m.cancel = cancel
+		m.setRunning(true)
+		m.pauseButton.Disable()
+		m.setStatus(fmt.Sprintf("Tracing routes to %d device(s) ...", len(ips)))
+
+		go func() {
+			defer cancel()
+			m.performTraces(ctx, spec, src, targets, ips, ranges)
+		}()
+	})
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Refused: %v.", err))
+	}
+}
+
+func (m *networkMapperModule) performTraces(ctx context.Context, spec jobSpec, src *sourceBinding, targets []int, ips []string, ranges []sensitiveRange) {
+	record := beginRun(spec, "")
+	probe, skipped, err := partitionExcluded(ips)
+	if err == nil {
+		err = preflight(spec, ranges, &record)
+	}
+	if err != nil {
+		record.finish(false, err)
+		endRun(record)
+		m.queueOnMain(func() {
+			m.setStatus(fmt.Sprintf("Traceroute failed: %v.", err))
+			m.setRunning(false)
+		})
+		return
+	}
+	record.Skipped = skipped
+
+	routes, errs, canceled := traceHosts(ctx, liveNetwork{src: src}, probe, spec.Trace)
+	traced := map[string][]traceHop{}
+	failed := 0
+	var firstErr error
+	for i, ip := range probe {
+		switch {
+		case errs[i] != nil:
+			failed++
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+		case routes[i] != nil:
+			traced[ip] = routes[i]
+			record.Devices = append(record.Devices, networkDevice{IP: ip, Route: routes[i]})
+		}
+	}
+	if failed > 0 {
+		record.Settings["failed traces"] = fmt.Sprintf("%d (%v)", failed, firstErr)
+	}
+	runErr := guardCause(ctx)
+	if runErr == nil && len(traced) == 0 && firstErr != nil {
+		runErr = firstErr
+	}
+	record.finish(canceled, runErr)
+	endRun(record)
+
+	m.queueOnMain(func() {
+		m.setRunning(false)
+		for i, idx := range targets {
+			if route, ok := traced[ips[i]]; ok && idx < len(m.devices) && m.devices[idx].IP == ips[i] {
+				m.devices[idx].Route = route
+			}
+		}
+		m.resultsList.Refresh()
+		switch {
+		case canceled:
+			m.setStatus(fmt.Sprintf("Traceroute stopped after %d route(s).", len(traced)))
+			return
+		case len(traced) == 0 && firstErr != nil:
+			m.setStatus(fmt.Sprintf("Traceroute failed: %v.", firstErr))
+			return
+		}
+		status := fmt.Sprintf("Traced %d route(s).", len(traced))
+		if firstErr != nil {
+			status += fmt.Sprintf(" Some traces failed: %v.", firstErr)
+		}
+		m.setStatus(status)
+		m.showTopology()
+	})
+}
+
+func (m *networkMapperModule) showTopology() {
+	win := activeWindow()
+	if win == nil {
+		return
+	}
+	var devices []networkDevice
+	for _, dev := range m.devices {
+		if dev.Status == "" {
+			devices = append(devices, dev)
+		}
+	}
+	if len(devices) == 0 {
+		m.setStatus("No discovered devices to show. Run the mapper first.")
+		return
+	}
+	showTopology(devices, m.mapped, win)
+}
+
+func (m *networkMapperModule) importVendors() {
+	win := activeWindow()
+	if win == nil {
+		return
+	}
+	dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+		if err != nil || r == nil {
+			return
+		}
+		defer r.Close()
+		if err := defaultVendors.importFrom(r); err != nil {
+			m.setStatus(fmt.Sprintf("Vendor update failed: %v.", err))
+			return
+		}
+		m.vendorLabel.SetText(defaultVendors.describe())
+		m.setStatus(fmt.Sprintf("Vendor database updated from %s.", r.URI().Name()))
+	}, win)
+}
+
+func (m *networkMapperModule) togglePause() {
+	if !m.running || m.control == nil {
+		return
+	}
+	if m.control.paused() {
+		m.control.unpause()
+		m.pauseButton.SetText("Pause")
+		m.setStatus("Mapping resumed.")
+		return
+	}
+	m.control.pause()
+	m.pauseButton.SetText("Resume")
+	m.setStatus("Mapping paused. Progress is checkpointed and can be resumed after a restart.")
+}
+
 func (m *networkMapperModule) setRunning(active bool) {
 	m.running = active
+	if m.pauseButton != nil {
+		m.pauseButton.SetText("Pause")
+		if active {
+			m.pauseButton.Enable()
+		} else {
+			m.pauseButton.Disable()
+		}
+	}
 	if m.runButton != nil {
 		if active {
 			m.runButton.SetText("Stop Network Mapper")
@@ -204,27 +801,8 @@ func (m *networkMapperModule) queueOnMain(fn func()) {
 	fn()
 }
 
-func checkHost(ip string) bool {
-	ports := []int{22, 80, 443, 3389}
-	for _, port := range ports {
-		if checkPort(ip, port, 150*time.Millisecond) {
-			return true
-		}
-	}
-	return false
-}
-
-func guessOS(ip string) string {
-	switch {
-	case checkPort(ip, 3389, 150*time.Millisecond):
-		return "Likely Windows (RDP)"
-	case checkPort(ip, 22, 150*time.Millisecond):
-		return "Likely Linux/Unix (SSH)"
-	case checkPort(ip, 80, 150*time.Millisecond):
-		return "Likely Web Appliance"
-	default:
-		return "Unknown"
-	}
+func discoveryPorts() []int {
+	return []int{22, 80, 443, 3389}
 }
 
 func normalizeSubnet(input string) (string, error) {
@@ -259,6 +837,17 @@ func incrementIP(ip net.IP) net.IP {
 	return next
 }
 
+func decrementIP(ip net.IP) net.IP {
+	prev := append(net.IP(nil), ip...)
+	for i := len(prev) - 1; i >= 0; i-- {
+		prev[i]--
+		if prev[i] != 0xff {
+			break
+		}
+	}
+	return prev
+}
+
 func broadcastIP(network *net.IPNet) net.IP {
 	if network == nil {
 		return nil
@@ -271,46 +860,9 @@ func broadcastIP(network *net.IPNet) net.IP {
 	return ip
 }
 
-func pseudoMACFromIP(ip net.IP) string {
-	if ip == nil {
-		return "00:00:00:00:00:00"
-	}
-	data := ip.To16()
-	if data == nil {
-		data = ip
-	}
-	sum := md5.Sum(data)
-	return fmt.Sprintf("02:%02x:%02x:%02x:%02x:%02x", sum[0], sum[1], sum[2], sum[3], sum[4])
-}
-
-func guessVendorFromIP(ip net.IP) string {
-	if ip == nil {
-		return "Unknown"
-	}
-	if ip.IsLoopback() {
-		return "Loopback"
-	}
-	if v4 := ip.To4(); v4 != nil {
-		switch {
-		case v4[0] == 10:
-			return "Private (10.x)"
-		case v4[0] == 172 && v4[1] >= 16 && v4[1] <= 31:
-			return "Private (172.16/12)"
-		case v4[0] == 192 && v4[1] == 168:
-			return "Private (192.168.x.x)"
-		default:
-			return "Public/Unknown"
-		}
-	}
-	return "Unknown"
-}
-
-func checkPort(ip string, port int, timeout time.Duration) bool {
-	addr := net.JoinHostPort(ip, strconv.Itoa(port))
-	conn, err := net.DialTimeout("tcp", addr, timeout)
-	if err != nil {
-		return false
-	}
-	conn.Close()
-	return true
+// addressClass describes the address space a host is in, such as
+// "Private (192.168.x.x)".
+func addressClass(ip net.IP) string {
+	_, label := classifyIP(ip)
+	return label
 }
@@ -0,0 +1,52 @@
+package modules
+
+import (
+	"log"
+
+	"fyne.io/fyne/v2"
+)
+
+var headless bool
+
+func notifyUser(title, content string) {
+	if headless {
+		log.Printf("%s: %s", title, content)
+		return
+	}
+	if app := fyne.CurrentApp(); app != nil {
+		app.SendNotification(fyne.NewNotification(title, content))
+		return
+	}
+	log.Printf("%s: %s", title, content)
+}
+
+func runOnMain(fn func()) {
+	if headless {
+		fn()
+		return
+	}
+	if app := fyne.CurrentApp(); app != nil {
+		if drv := app.Driver(); drv != nil {
+			if runner, ok := drv.(interface{ RunOnMain(func()) }); ok {
+				runner.RunOnMain(fn)
+				return
+			}
+		}
+	}
+	fn()
+}
+
+func activeWindow() fyne.Window {
+	if headless {
+		return nil
+	}
+	app := fyne.CurrentApp()
+	if app == nil {
+		return nil
+	}
+	windows := app.Driver().AllWindows()
+	if len(windows) == 0 {
+		return nil
+	}
+	return windows[0]
+}
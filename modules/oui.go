@@ -0,0 +1,231 @@
+package modules
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const ouiFile = "oui.csv"
+
+const (
+	vendorUnknown = "Unknown"
+	vendorLocal   = "Locally administered"
+)
+
+// The bundled registry is the IEEE MA-L oui.txt, gzipped. Refresh it with
+// go generate.
+//
+//go:generate sh -c "curl -fsSL https://standards-oui.ieee.org/oui/oui.txt | gzip -9n > oui.txt.gz"
+//go:embed oui.txt.gz
+var bundledOUI []byte
+
+func readBundledOUI() (map[string]string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(bundledOUI))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	return parseOUI(data)
+}
+
+// parseOUI reads the IEEE registry, either oui.txt or the MA-L, MA-M and
+// MA-S CSV exports. Prefixes are keyed by their upper-case hex digits, so a
+// 24, 28 or 36 bit assignment is 6, 7 or 9 digits long.
+func parseOUI(data []byte) (map[string]string, error) {
+	vendors := map[string]string{}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("Registry,")) {
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid OUI file: %w", err)
+		}
+		for _, rec := range records[1:] {
+			if len(rec) < 3 {
+				continue
+			}
+			prefix := strings.ToUpper(strings.TrimSpace(rec[1]))
+			if validPrefix(prefix) {
+				vendors[prefix] = strings.TrimSpace(rec[2])
+			}
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			prefix, name, ok := strings.Cut(scanner.Text(), "(hex)")
+			if !ok {
+				continue
+			}
+			prefix = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(prefix), "-", ""))
+			if validPrefix(prefix) {
+				vendors[prefix] = strings.TrimSpace(name)
+			}
+		}
+	}
+	if len(vendors) == 0 {
+		return nil, errors.New("invalid OUI file: no assignments found")
+	}
+	return vendors, nil
+}
+
+func validPrefix(prefix string) bool {
+	switch len(prefix) {
+	case 6, 7, 9:
+	default:
+		return false
+	}
+	_, err := hex.DecodeString(prefix + strings.Repeat("0", len(prefix)%2))
+	return err == nil
+}
+
+// vendorDB resolves MAC addresses to manufacturers. It starts from the
+// bundled registry, with the assignments imported into the data directory
+// on top.
+type vendorDB struct {
+	mu       sync.Mutex
+	loadOnce sync.Once
+	vendors  map[string]string
+	imported map[string]string
+}
+
+var defaultVendors = &vendorDB{}
+
+func (db *vendorDB) load() {
+	db.loadOnce.Do(func() {
+		vendors, err := readBundledOUI()
+		if err != nil {
+			log.Printf("vendors: bundled registry: %v", err)
+			vendors = map[string]string{}
+		}
+		imported := db.readImported()
+		for prefix, name := range imported {
+			vendors[prefix] = name
+		}
+		db.mu.Lock()
+		db.vendors = vendors
+		db.imported = imported
+		db.mu.Unlock()
+	})
+}
+
+func (db *vendorDB) readImported() map[string]string {
+	path, err := dataPath(ouiFile)
+	if err != nil {
+		return map[string]string{}
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}
+	}
+	if err == nil {
+		var vendors map[string]string
+		if vendors, err = parseOUI(data); err == nil {
+			return vendors
+		}
+	}
+	log.Printf("vendors: %v", err)
+	return map[string]string{}
+}
+
+// lookup reports the manufacturer of mac. Values that are not MAC
+// addresses, such as macRouted, have no vendor.
+func (db *vendorDB) lookup(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return ""
+	}
+	if hw[0]&0x02 != 0 {
+		return vendorLocal
+	}
+	db.load()
+	digits := strings.ToUpper(hex.EncodeToString(hw))
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, n := range []int{9, 7, 6} {
+		if name, ok := db.vendors[digits[:n]]; ok {
+			return name
+		}
+	}
+	return vendorUnknown
+}
+
+func (db *vendorDB) describe() string {
+	db.load()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.imported) == 0 {
+		return fmt.Sprintf("Vendor database: bundled, %d prefixes.", len(db.vendors))
+	}
+	return fmt.Sprintf("Vendor database: %d prefixes, %d imported.", len(db.vendors), len(db.imported))
+}
+
+// importFrom merges a registry file into the database, so that the MA-M and
+// MA-S exports add to the MA-L assignments rather than replace them. The
+// imported assignments are kept in the data directory for later runs.
+func (db *vendorDB) importFrom(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	vendors, err := parseOUI(data)
+	if err != nil {
+		return err
+	}
+	db.load()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	imported := make(map[string]string, len(db.imported)+len(vendors))
+	for prefix, name := range db.imported {
+		imported[prefix] = name
+	}
+	for prefix, name := range vendors {
+		imported[prefix] = name
+	}
+	if err := saveFile(ouiFile, formatOUI(imported)); err != nil {
+		return err
+	}
+	for prefix, name := range vendors {
+		db.vendors[prefix] = name
+	}
+	db.imported = imported
+	return nil
+}
+
+// formatOUI writes assignments in the IEEE CSV layout that parseOUI reads.
+func formatOUI(vendors map[string]string) []byte {
+	prefixes := make([]string, 0, len(vendors))
+	for prefix := range vendors {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"Registry", "Assignment", "Organization Name", "Organization Address"})
+	for _, prefix := range prefixes {
+		registry := "MA-L"
+		switch len(prefix) {
+		case 7:
+			registry = "MA-M"
+		case 9:
+			registry = "MA-S"
+		}
+		w.Write([]string{registry, prefix, vendors[prefix], ""})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
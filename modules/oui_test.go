@@ -0,0 +1,32 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVendorImportMerges(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	db := &vendorDB{}
+	maL := "Registry,Assignment,Organization Name,Organization Address\nMA-L,F0D1A9,Example Labs,\n"
+	maM := "Registry,Assignment,Organization Name,Organization Address\nMA-M,70B3D51,Example Sensors,\n"
+	for _, data := range []string{maL, maM} {
+		if err := db.importFrom(strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]string{
+		"f0:d1:a9:00:00:01": "Example Labs",
+		"70:b3:d5:1a:00:01": "Example Sensors",
+		"00:00:0c:00:00:01": "Cisco Systems, Inc",
+	}
+	for _, reloaded := range []*vendorDB{db, {}} {
+		for mac, want := range tests {
+			if got := reloaded.lookup(mac); got != want {
+				t.Errorf("lookup(%s) = %q, want %q", mac, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,536 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+const proxyFile = "proxy.json"
+
+const (
+	proxySOCKS5 = "socks5"
+	proxyHTTP   = "http"
+)
+
+// proxyHopTimeout bounds each proxy handshake on top of the probe timeout,
+// so slow proxies do not turn every port into a timeout.
+const proxyHopTimeout = 3 * time.Second
+
+type proxyHop struct {
+	Type         string `json:"type"`
+	Address      string `json:"address"`
+	CredentialID string `json:"credential_id,omitempty"`
+}
+
+func (h proxyHop) String() string {
+	if h.CredentialID != "" {
+		return fmt.Sprintf("%s://%s@%s", h.Type, h.CredentialID, h.Address)
+	}
+	return fmt.Sprintf("%s://%s", h.Type, h.Address)
+}
+
+// parseProxyHop accepts socks5://host:port and http://host:port, with an
+// optional vault credential ID as the user part: socks5://cred-id@host:port.
+func parseProxyHop(input string) (proxyHop, error) {
+	u, err := url.Parse(strings.TrimSpace(input))
+	if err != nil || u.Host == "" {
+		return proxyHop{}, fmt.Errorf("invalid proxy %q, use socks5://host:port or http://host:port", input)
+	}
+	hop := proxyHop{Type: strings.ToLower(u.Scheme), Address: u.Host}
+	switch hop.Type {
+	case "socks5h":
+		hop.Type = proxySOCKS5
+	case proxySOCKS5, proxyHTTP:
+	default:
+		return proxyHop{}, fmt.Errorf("unsupported proxy type %q", u.Scheme)
+	}
+	if _, _, err := net.SplitHostPort(hop.Address); err != nil {
+		return proxyHop{}, fmt.Errorf("proxy %q needs a port", input)
+	}
+	if u.User != nil {
+		hop.CredentialID = u.User.Username()
+	}
+	return hop, nil
+}
+
+type proxyChain struct {
+	Enabled bool       `json:"enabled"`
+	Hops    []proxyHop `json:"hops,omitempty"`
+}
+
+func (c proxyChain) active() bool {
+	return c.Enabled && len(c.Hops) > 0
+}
+
+func (c proxyChain) String() string {
+	parts := make([]string, 0, len(c.Hops))
+	for _, hop := range c.Hops {
+		parts = append(parts, hop.String())
+	}
+	return strings.Join(parts, " -> ")
+}
+
+var errProxyUnavailable = errors.New("proxy unavailable")
+
+// proxyError carries SOCKS replies so that probes can still tell a refused
+// port from an unreachable one.
+type proxyError struct {
+	msg     string
+	timeout bool
+}
+
+func (e *proxyError) Error() string   { return e.msg }
+func (e *proxyError) Timeout() bool   { return e.timeout }
+func (e *proxyError) Temporary() bool { return e.timeout }
+
+func (c proxyChain) dial(src *sourceBinding, address string, timeout time.Duration) (net.Conn, error) {
+	if !c.active() {
+		return src.dial(address, timeout)
+	}
+
+	budget := timeout + time.Duration(len(c.Hops))*proxyHopTimeout
+	conn, err := src.dial(c.Hops[0].Address, proxyHopTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", errProxyUnavailable, c.Hops[0].Address, err)
+	}
+	conn.SetDeadline(time.Now().Add(budget))
+
+	for i, hop := range c.Hops {
+		next := address
+		if i+1 < len(c.Hops) {
+			next = c.Hops[i+1].Address
+		}
+		conn, err = hop.connect(conn, next)
+		if err != nil {
+			conn.Close()
+			var replyErr *proxyError
+			if i+1 == len(c.Hops) && errors.As(err, &replyErr) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", errProxyUnavailable, err)
+		}
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (h proxyHop) credentials() (string, string, error) {
+	if h.CredentialID == "" {
+		return "", "", nil
+	}
+	host, _, _ := net.SplitHostPort(h.Address)
+	c, err := defaultVault.resolve(h.CredentialID, host)
+	if err != nil {
+		return "", "", fmt.Errorf("proxy %s: %w", h.Address, err)
+	}
+	return c.Username, string(c.secret), nil
+}
+
+func (h proxyHop) connect(conn net.Conn, address string) (net.Conn, error) {
+	user, pass, err := h.credentials()
+	if err != nil {
+		return conn, err
+	}
+	if h.Type == proxyHTTP {
+		return httpConnect(conn, address, user, pass)
+	}
+	return conn, socks5Connect(conn, address, user, pass)
+}
+
+func socks5Connect(conn net.Conn, address, user, pass string) error {
+	methods := []byte{0x00}
+	if user != "" {
+		methods = []byte{0x02}
+	}
+	greeting := append([]byte{0x05, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	if reply[0] != 0x05 || reply[1] == 0xff {
+		return fmt.Errorf("socks5: no acceptable authentication method")
+	}
+
+	if reply[1] == 0x02 {
+		if len(user) > 255 || len(pass) > 255 {
+			return fmt.Errorf("socks5: credentials too long")
+		}
+		auth := []byte{0x01, byte(len(user))}
+		auth = append(auth, user...)
+		auth = append(auth, byte(len(pass)))
+		auth = append(auth, pass...)
+		if _, err := conn.Write(auth); err != nil {
+			return fmt.Errorf("socks5: %w", err)
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return fmt.Errorf("socks5: %w", err)
+		}
+		if reply[1] != 0x00 {
+			return fmt.Errorf("socks5: authentication failed")
+		}
+	}
+
+	host, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return fmt.Errorf("socks5: invalid port %q", portText)
+	}
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if v4 := ip.To4(); v4 != nil {
+			req = append(append(req, 0x01), v4...)
+		} else {
+			req = append(append(req, 0x04), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("socks5: hostname too long")
+		}
+		req = append(append(req, 0x03, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	if head[1] != 0x00 {
+		return socks5Error(head[1])
+	}
+	var skip int
+	switch head[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return fmt.Errorf("socks5: %w", err)
+		}
+		skip = int(n[0]) + 2
+	default:
+		return fmt.Errorf("socks5: malformed reply")
+	}
+	if _, err := io.ReadFull(conn, make([]byte, skip)); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	return nil
+}
+
+func socks5Error(code byte) error {
+	switch code {
+	case 0x03:
+		return &proxyError{msg: "socks5: network unreachable", timeout: true}
+	case 0x04:
+		return &proxyError{msg: "socks5: host unreachable", timeout: true}
+	case 0x05:
+		return &proxyError{msg: "socks5: connection refused"}
+	case 0x06:
+		return &proxyError{msg: "socks5: TTL expired", timeout: true}
+	case 0x02:
+		return &proxyError{msg: "socks5: connection not allowed by ruleset"}
+	default:
+		return &proxyError{msg: fmt.Sprintf("socks5: request failed (code %d)", code)}
+	}
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func httpConnect(conn net.Conn, address, user, pass string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if user != "" {
+		token := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+token)
+	}
+	if err := req.Write(conn); err != nil {
+		return conn, fmt.Errorf("http proxy: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return conn, fmt.Errorf("http proxy: %w", err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusGatewayTimeout:
+		return conn, &proxyError{msg: "http proxy: gateway timeout", timeout: true}
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return conn, fmt.Errorf("http proxy: authentication required")
+	default:
+		return conn, &proxyError{msg: fmt.Sprintf("http proxy: %s", resp.Status)}
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// proxyAccuracy explains how each scan type behaves through a proxy.
+func proxyAccuracy() [][2]string {
+	return [][2]string{
+		{moduleScanner, "Accurate through SOCKS5: open, closed and unreachable are reported by the proxy. " +
+			"Through HTTP CONNECT closed and filtered ports look the same, and many proxies only allow port 443."},
+		{moduleMapper, "Discovery still works over TCP but is much slower. MAC addresses and vendors describe " +
+			"nothing real on the far side of a proxy, and OS guesses only reflect open ports."},
+		{moduleVulnerability, "Accurate: checks connect and send their payloads through the chain."},
+		{"Hostnames", "SOCKS5 resolves hostnames on the proxy; scope and exclusion checks still resolve them locally."},
+	}
+}
+
+func proxyNotice(module string) string {
+	chain := defaultProxy.current()
+	if !chain.active() {
+		return ""
+	}
+	for _, note := range proxyAccuracy() {
+		if note[0] == module {
+			return fmt.Sprintf("Probes go through proxy %s. %s", chain, note[1])
+		}
+	}
+	return fmt.Sprintf("Probes go through proxy %s.", chain)
+}
+
+// newProxyNotice returns a label that follows the proxy configuration.
+func newProxyNotice(module string) *widget.Label {
+	label := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	label.Wrapping = fyne.TextWrapWord
+	update := func() {
+		text := proxyNotice(module)
+		label.SetText(text)
+		if text == "" {
+			label.Hide()
+		} else {
+			label.Show()
+		}
+	}
+	defaultProxy.subscribe(func() { runOnMain(update) })
+	update()
+	return label
+}
+
+type proxyStore struct {
+	mu        sync.Mutex
+	loadOnce  sync.Once
+	chain     proxyChain
+	listeners []func()
+}
+
+var defaultProxy = &proxyStore{}
+
+func (s *proxyStore) current() proxyChain {
+	s.loadOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := loadJSON(proxyFile, &s.chain); err != nil {
+			log.Printf("proxy: %v", err)
+		}
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.chain
+}
+
+func (s *proxyStore) set(chain proxyChain) error {
+	if chain.Enabled && len(chain.Hops) == 0 {
+		return errors.New("add at least one proxy before enabling the chain")
+	}
+	s.current()
+	s.mu.Lock()
+	s.chain = chain
+	err := saveJSON(proxyFile, chain)
+	listeners := append([]func(){}, s.listeners...)
+	s.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+	return err
+}
+
+func (s *proxyStore) subscribe(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+type proxyModule struct {
+	content      fyne.CanvasObject
+	enabledCheck *widget.Check
+	hopsEntry    *widget.Entry
+	testEntry    *widget.Entry
+	statusLabel  *widget.Label
+}
+
+func (m *proxyModule) Name() string {
+	return "Proxy"
+}
+
+func (m *proxyModule) Content() fyne.CanvasObject {
+	if m.content != nil {
+		return m.content
+	}
+
+	m.enabledCheck = widget.NewCheck("Route probes through the proxy chain", nil)
+	m.hopsEntry = widget.NewMultiLineEntry()
+	m.hopsEntry.SetPlaceHolder("One proxy per line, first hop first\nsocks5://10.0.0.5:1080\nhttp://cred-id@proxy.client.example:3128")
+	m.hopsEntry.SetMinRowsVisible(4)
+
+	saveButton := widget.NewButton("Save", m.save)
+	m.testEntry = widget.NewEntry()
+	m.testEntry.SetPlaceHolder("host:port to test")
+	testButton := widget.NewButton("Test Connection", m.test)
+	rowHeight := m.testEntry.MinSize().Height
+	actionRow := container.NewHBox(
+		saveButton,
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(24, rowHeight)), widget.NewLabel("")),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(200, rowHeight)), m.testEntry),
+		testButton,
+		layout.NewSpacer(),
+	)
+
+	m.statusLabel = widget.NewLabel("")
+	m.statusLabel.Wrapping = fyne.TextWrapWord
+
+	var notes []string
+	for _, note := range proxyAccuracy() {
+		notes = append(notes, fmt.Sprintf("%s: %s", note[0], note[1]))
+	}
+	notesLabel := widget.NewLabel(strings.Join(notes, "\n\n"))
+	notesLabel.Wrapping = fyne.TextWrapWord
+
+	m.content = container.NewVBox(
+		widget.NewLabelWithStyle("Proxy Chain", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("SOCKS5 and HTTP CONNECT proxies for Scanner, Network Mapper and Vulnerability Scanner probes."),
+		m.enabledCheck,
+		m.hopsEntry,
+		actionRow,
+		m.statusLabel,
+		widget.NewCard("Accuracy Through a Proxy", "Proxy logins come from the credential vault (the user part of the URL is a credential ID).", notesLabel),
+	)
+
+	defaultProxy.subscribe(func() { m.queueOnMain(m.reload) })
+	m.reload()
+
+	return m.content
+}
+
+func (m *proxyModule) reload() {
+	chain := defaultProxy.current()
+	m.enabledCheck.SetChecked(chain.Enabled)
+	lines := make([]string, 0, len(chain.Hops))
+	for _, hop := range chain.Hops {
+		lines = append(lines, hop.String())
+	}
+	m.hopsEntry.SetText(strings.Join(lines, "\n"))
+}
+
+func (m *proxyModule) save() {
+	chain := proxyChain{Enabled: m.enabledCheck.Checked}
+	for _, line := range splitLines(m.hopsEntry.Text) {
+		hop, err := parseProxyHop(line)
+		if err != nil {
+			m.setStatus(err.Error())
+			return
+		}
+		chain.Hops = append(chain.Hops, hop)
+	}
+	if err := defaultProxy.set(chain); err != nil {
+		m.setStatus(fmt.Sprintf("Unable to save proxy chain: %v", err))
+		return
+	}
+	m.setStatus("Proxy chain saved.")
+}
+
+func (m *proxyModule) test() {
+	address := strings.TrimSpace(m.testEntry.Text)
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		m.setStatus("Enter the test target as host:port.")
+		return
+	}
+	pins, err := checkScopeHosts(m.Name(), []string{host})
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Refused: %v.", err))
+		return
+	}
+	_, skipped, err := partitionExcluded([]string{host})
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Unable to check exclusions: %v.", err))
+		return
+	}
+	if len(skipped) > 0 {
+		m.setStatus(fmt.Sprintf("Target %s.", skipped[0]))
+		return
+	}
+	m.setStatus(fmt.Sprintf("Connecting to %s ...", address))
+	go func() {
+		start := time.Now()
+		conn, err := pinNetwork(liveNetwork{}, pins).dialTCP(address, 2*time.Second)
+		m.queueOnMain(func() {
+			if err != nil {
+				m.setStatus(fmt.Sprintf("Connection to %s failed: %v", address, err))
+				return
+			}
+			conn.Close()
+			m.setStatus(fmt.Sprintf("Connected to %s in %s.", address, time.Since(start).Round(time.Millisecond)))
+		})
+	}()
+}
+
+func (m *proxyModule) setStatus(text string) {
+	if m.statusLabel != nil {
+		m.statusLabel.SetText(text)
+	}
+}
+
+func (m *proxyModule) queueOnMain(fn func()) {
+	if app := fyne.CurrentApp(); app != nil {
+		if drv := app.Driver(); drv != nil {
+			if runner, ok := drv.(interface{ RunOnMain(func()) }); ok {
+				runner.RunOnMain(fn)
+				return
+			}
+		}
+	}
+	fn()
+}
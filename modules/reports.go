@@ -1,37 +1,117 @@
 package modules
 
 import (
+	"fmt"
+	"strings"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/widget"
 )
 
-type reportsModule struct{}
+const recentReportLimit = 200
+
+type reportsModule struct {
+	content     fyne.CanvasObject
+	reportList  *widget.List
+	detailLabel *widget.Label
+	records     []scanRecord
+}
 
 func (m *reportsModule) Name() string {
 	return "Reports"
 }
 
 func (m *reportsModule) Content() fyne.CanvasObject {
-	reportList := widget.NewList(
-		func() int { return len(mockReports) },
+	if m.content != nil {
+		return m.content
+	}
+
+	m.reportList = widget.NewList(
+		func() int { return len(m.records) },
 		func() fyne.CanvasObject { return widget.NewLabel("") },
 		func(i int, obj fyne.CanvasObject) {
-			obj.(*widget.Label).SetText(mockReports[i])
+			obj.(*widget.Label).SetText(m.records[i].String())
 		},
 	)
+	m.reportList.OnSelected = func(id widget.ListItemID) {
+		if id < len(m.records) {
+			m.detailLabel.SetText(reportDetails(m.records[id]))
+		}
+	}
 
-	return container.NewBorder(
-		nil, nil, nil, nil,
-		container.NewVBox(
-			widget.NewLabelWithStyle("Recent Reports", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-			reportList,
-		),
+	m.detailLabel = widget.NewLabel("Select a report to view its results.")
+	m.detailLabel.Wrapping = fyne.TextWrapWord
+
+	listScroll := container.NewVScroll(m.reportList)
+	listScroll.SetMinSize(fyne.NewSize(0, 220))
+	detailScroll := container.NewVScroll(m.detailLabel)
+	detailScroll.SetMinSize(fyne.NewSize(0, 200))
+
+	m.content = container.NewVBox(
+		widget.NewLabelWithStyle("Recent Reports", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Results of manual and scheduled runs, newest first."),
+		widget.NewCard("History", "", container.NewMax(listScroll)),
+		widget.NewCard("Details", "", container.NewMax(detailScroll)),
 	)
+
+	scanHistory().subscribe(func(scanRecord) { m.queueOnMain(m.reload) })
+	m.reload()
+
+	return m.content
+}
+
+func (m *reportsModule) reload() {
+	m.records = scanHistory().recent(recentReportLimit)
+	m.reportList.UnselectAll()
+	m.reportList.Refresh()
+}
+
+func reportDetails(rec scanRecord) string {
+	lines := []string{
+		fmt.Sprintf("Module: %s", rec.Module),
+		fmt.Sprintf("Target: %s", rec.Target),
+		fmt.Sprintf("Started: %s", rec.Started.Format("2006-01-02 15:04:05")),
+		fmt.Sprintf("Finished: %s", rec.Finished.Format("2006-01-02 15:04:05")),
+		fmt.Sprintf("Status: %s", rec.Status),
+		fmt.Sprintf("Summary: %s", rec.Summary),
+	}
+	if rec.JobID != "" {
+		lines = append(lines, fmt.Sprintf("Scheduled job: %s", rec.JobID))
+	}
+	if rec.CredentialID != "" {
+		lines = append(lines, fmt.Sprintf("Credential: %s", rec.CredentialID))
+	}
+	if rec.Error != "" {
+		lines = append(lines, fmt.Sprintf("Error: %s", rec.Error))
+	}
+
+	for _, ps := range rec.Ports {
+		if ps.Status == "open" {
+			lines = append(lines, ps.row())
+		}
+	}
+	for _, dev := range rec.Devices {
+		lines = append(lines, dev.row())
+	}
+	for _, f := range rec.Findings {
+		lines = append(lines, f.line())
+	}
+	for _, skip := range rec.Skipped {
+		lines = append(lines, skip.String())
+	}
+
+	return strings.Join(lines, "\n")
 }
 
-var mockReports = []string{
-	"Daily Scan - OK",
-	"Credential Audit - Attention",
-	"Network Surface - OK",
+func (m *reportsModule) queueOnMain(fn func()) {
+	if app := fyne.CurrentApp(); app != nil {
+		if drv := app.Driver(); drv != nil {
+			if runner, ok := drv.(interface{ RunOnMain(func()) }); ok {
+				runner.RunOnMain(fn)
+				return
+			}
+		}
+	}
+	fn()
 }
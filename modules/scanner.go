@@ -17,37 +17,55 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
+const maxScanTargets = 1024
+
 type portStatus struct {
-	Port    int
-	Service string
-	Status  string
+	Host    string `json:"host,omitempty"`
+	Port    int    `json:"port"`
+	Service string `json:"service"`
+	Status  string `json:"status"`
 }
 
 type scannerModule struct {
 	content      fyne.CanvasObject
 	targetEntry  *widget.Entry
+	sourceEntry  *widget.SelectEntry
 	scanButton   *widget.Button
+	pauseButton  *widget.Button
 	statusLabel  *widget.Label
 	detailsLabel *widget.Label
 	systemLabel  *widget.Label
 	resultsList  *widget.List
 	portStatuses []portStatus
-	portIndex    map[int]int
+	portIndex    map[string]int
 	scanCancel   context.CancelFunc
+	control      *runControl
 	scanning     bool
 }
 
 func (m *scannerModule) Name() string {
-	return "Scanner"
+	return moduleScanner
+}
+
+// pendingScanTarget is filled in by other modules and moves into the target
+// field the next time the Scanner is shown.
+var pendingScanTarget string
+
+func sendToScanner(hosts []string) {
+	pendingScanTarget = strings.Join(hosts, ", ")
+	if showModule != nil {
+		showModule(moduleScanner)
+	}
 }
 
 func (m *scannerModule) Content() fyne.CanvasObject {
 	if m.content != nil {
+		m.takePendingTarget()
 		return m.content
 	}
 
 	m.targetEntry = widget.NewEntry()
-	m.targetEntry.SetPlaceHolder("Target hostname or IP")
+	m.targetEntry.SetPlaceHolder("Hosts, IPs, ranges or CIDRs")
 
 	m.scanButton = widget.NewButton("Scan", m.startScan)
 	buttonMin := m.scanButton.MinSize()
@@ -57,7 +75,11 @@ func (m *scannerModule) Content() fyne.CanvasObject {
 	entryContainer := container.New(layout.NewGridWrapLayout(fyne.NewSize(240, m.targetEntry.MinSize().Height)), m.targetEntry)
 	buttonContainer := container.New(layout.NewGridWrapLayout(buttonWidth), m.scanButton)
 	entrySpacer := container.New(layout.NewGridWrapLayout(fyne.NewSize(8, m.targetEntry.MinSize().Height)), widget.NewLabel(""))
-	formRow := container.NewHBox(entryContainer, entrySpacer, buttonContainer)
+	m.pauseButton = widget.NewButton("Pause", m.togglePause)
+	pauseContainer := container.New(layout.NewGridWrapLayout(buttonWidth), m.pauseButton)
+	formRow := container.NewHBox(entryContainer, entrySpacer, buttonContainer, pauseContainer)
+	m.sourceEntry = newSourceEntry()
+	sourceRow := container.NewHBox(container.New(layout.NewGridWrapLayout(fyne.NewSize(240, m.targetEntry.MinSize().Height)), m.sourceEntry))
 
 	m.detailsLabel = widget.NewLabel("No target selected.")
 	m.detailsLabel.Wrapping = fyne.TextWrapWord
@@ -85,7 +107,7 @@ func (m *scannerModule) Content() fyne.CanvasObject {
 	overviewLabel := widget.NewLabelWithStyle("Scanner Overview", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
 	descriptionLabel := widget.NewLabel("Configure and monitor scanning tasks.")
 
-	leftColumn := container.NewVBox(overviewLabel, descriptionLabel, formRow)
+	leftColumn := container.NewVBox(overviewLabel, descriptionLabel, formRow, sourceRow)
 	const columnsGap float32 = 16
 	columnsSpacer := container.New(layout.NewGridWrapLayout(fyne.NewSize(columnsGap, detailsCard.MinSize().Height)), widget.NewLabel(""))
 	headerRow := container.NewHBox(leftColumn, columnsSpacer, boxesRow, layout.NewSpacer())
@@ -96,8 +118,7 @@ func (m *scannerModule) Content() fyne.CanvasObject {
 		func() int { return len(m.portStatuses) },
 		func() fyne.CanvasObject { return widget.NewLabel("") },
 		func(i int, obj fyne.CanvasObject) {
-			ps := m.portStatuses[i]
-			obj.(*widget.Label).SetText(fmt.Sprintf("%-5d/tcp %-16s %s", ps.Port, ps.Service, ps.Status))
+			obj.(*widget.Label).SetText(m.portStatuses[i].row())
 		},
 	)
 
@@ -109,13 +130,24 @@ func (m *scannerModule) Content() fyne.CanvasObject {
 
 	m.content = container.NewVBox(
 		headerRow,
+		newProxyNotice(moduleScanner),
 		m.statusLabel,
 		resultsCard,
 	)
 
+	m.takePendingTarget()
 	return m.content
 }
 
+func (m *scannerModule) takePendingTarget() {
+	if pendingScanTarget == "" || m.scanning {
+		return
+	}
+	m.targetEntry.SetText(pendingScanTarget)
+	pendingScanTarget = ""
+	m.setStatus("Targets received from the Network Mapper. Click Scan to begin.")
+}
+
 func (m *scannerModule) startScan() {
 	if m.scanning {
 		m.requestStop()
@@ -128,15 +160,60 @@ func (m *scannerModule) startScan() {
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	m.scanCancel = cancel
-	m.setScanActive(true)
-	m.setStatus(fmt.Sprintf("Scanning %s...", target))
-	m.updateTargetDetails(target)
-	m.populateSystemDetails()
-	m.initPortStatuses("pending")
+	hosts, err := expandTargets(target)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Invalid target list: %v.", err))
+		return
+	}
+
+	pins, err := checkScopeHosts(moduleScanner, hosts)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Refused: %v.", err))
+		return
+	}
+
+	src, err := parseSource(m.sourceEntry.Text)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Invalid source: %v.", err))
+		return
+	}
+
+	ranges := sensitiveAddresses(hosts)
+	spec := jobSpec{Module: moduleScanner, Target: target, Source: src.source()}
+	err = confirmRun(spec, ranges, func(acknowledged []string) {
+		spec.Acknowledged = acknowledged
+		m.control = newRunControl()
+		ctx, cancel := guardContext(withRunControl(context.Background(), m.control))
+		m.scanCancel = cancel
+		m.setScanActive(true)
+		m.setStatus(fmt.Sprintf("Scanning %s...", target))
+		m.updateTargetDetails(hosts)
+		m.populateSystemDetails()
+		m.clearPortStatuses()
+
+		go func() {
+			defer cancel()
+			m.performScan(ctx, spec, pinNetwork(liveNetwork{src: src}, pins), hosts, ranges)
+		}()
+	})
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Refused: %v.", err))
+	}
+}
 
-	go m.performScan(ctx, target)
+func (m *scannerModule) togglePause() {
+	if !m.scanning || m.control == nil {
+		return
+	}
+	if m.control.paused() {
+		m.control.unpause()
+		m.pauseButton.SetText("Pause")
+		m.setStatus("Scan resumed.")
+		return
+	}
+	m.control.pause()
+	m.pauseButton.SetText("Resume")
+	m.setStatus("Scan paused. Progress is checkpointed and can be resumed after a restart.")
 }
 
 func (m *scannerModule) requestStop() {
@@ -149,47 +226,258 @@ func (m *scannerModule) requestStop() {
 	m.setStatus("Stopping current scan...")
 }
 
-func (m *scannerModule) performScan(ctx context.Context, target string) {
-	ports := portCatalog()
-	canceled := false
-
-scanLoop:
-	for _, def := range ports {
-		select {
-		case <-ctx.Done():
-			canceled = true
-			break scanLoop
-		default:
-		}
-
-		port := def.Port
-		address := net.JoinHostPort(target, strconv.Itoa(port))
-
+func (m *scannerModule) performScan(ctx context.Context, spec jobSpec, nw probeNetwork, hosts []string, ranges []sensitiveRange) {
+	target := spec.Target
+	record := beginRun(spec, "")
+	probe, skipped, err := partitionExcluded(hosts)
+	if err == nil {
+		err = preflight(spec, ranges, &record)
+	}
+	if err != nil {
+		record.finish(false, err)
+		endRun(record)
 		m.queueOnMain(func() {
-			m.setPortStatus(port, "scanning...")
+			m.setStatus(fmt.Sprintf("Scan failed: %v.", err))
+			m.setScanActive(false)
+			m.scanCancel = nil
 		})
+		return
+	}
+	record.Skipped = skipped
+	rc := runControlFrom(ctx)
+	rc.track(spec, record, nil)
+	m.queueOnMain(func() {
+		m.initPortStatuses(probe, skipped, "pending", len(hosts) > 1)
+	})
 
-		state := m.scanPort(address)
-
+	statuses, late, canceled := scanHosts(ctx, nw, probe, len(hosts) > 1, func(host string, port int, status string) {
 		m.queueOnMain(func() {
-			m.setPortStatus(port, state)
+			m.setPortStatus(host, port, status)
 		})
+	})
+	record.Ports = statuses
+	record.Skipped = append(record.Skipped, late...)
+	skipped = record.Skipped
+	if !canceled {
+		record.Findings = baselineFindings(withoutSkipped(probe, late), statuses)
 	}
+	record.finish(canceled, guardCause(ctx))
+	rc.finish(record)
+	endRun(record)
 
 	m.queueOnMain(func() {
-		if canceled {
+		counts := fmt.Sprintf("%d ports", len(statuses))
+		if len(hosts) > 1 {
+			counts = fmt.Sprintf("%d hosts, %d ports", len(probe), len(statuses))
+		}
+		if len(skipped) > 0 {
+			counts += fmt.Sprintf(", %d skipped", len(skipped))
+		}
+		switch {
+		case canceled:
 			m.setStatus("Scan stopped.")
-		} else {
-			m.setStatus(fmt.Sprintf("Scan complete for %s (%d ports).", target, len(ports)))
+		case len(record.Findings) > 0:
+			m.setStatus(fmt.Sprintf("Scan complete for %s (%s, %d policy violation(s) - see Vulnerability Scanner).",
+				target, counts, len(record.Findings)))
+		default:
+			m.setStatus(fmt.Sprintf("Scan complete for %s (%s).", target, counts))
 		}
 		m.setScanActive(false)
 		m.scanCancel = nil
 	})
 }
 
-func (m *scannerModule) scanPort(address string) string {
-	conn, err := net.DialTimeout("tcp", address, 500*time.Millisecond)
+// expandTargets turns a comma or whitespace separated list of hostnames, IPs,
+// dash ranges and CIDRs into individual hosts.
+func expandTargets(input string) ([]string, error) {
+	var hosts []string
+	seen := map[string]bool{}
+	add := func(host string) error {
+		if seen[host] {
+			return nil
+		}
+		if len(hosts) == maxScanTargets {
+			return fmt.Errorf("more than %d hosts", maxScanTargets)
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+		return nil
+	}
+
+	tokens := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\n'
+	})
+	for _, token := range tokens {
+		set, err := parseAddressSet([]string{token})
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(token) != nil || !set.hasAddresses() {
+			if err := add(token); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, network := range set.networks {
+			ones, bits := network.Mask.Size()
+			if bits-ones > 16 {
+				return nil, fmt.Errorf("%s is too large", network)
+			}
+			first, last := network.IP, broadcastIP(network)
+			if bits == 32 && bits-ones > 1 {
+				first, last = incrementIP(first), decrementIP(last)
+			}
+			for cur := first; network.Contains(cur); cur = incrementIP(cur) {
+				if err := add(cur.String()); err != nil {
+					return nil, err
+				}
+				if cur.Equal(last) {
+					break
+				}
+			}
+		}
+		for _, r := range set.ranges {
+			for cur := r.start; r.contains(cur); cur = incrementIP(cur) {
+				if err := add(cur.String()); err != nil {
+					return nil, err
+				}
+				if cur.Equal(r.end) {
+					break
+				}
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no targets")
+	}
+	return hosts, nil
+}
+
+// scanHosts probes every host in turn. Results carry the host only when
+// several hosts were requested, so single-target records keep their shape.
+// Hosts that left the scope since the run was confirmed, because its
+// validity window closed, are skipped.
+func scanHosts(ctx context.Context, nw probeNetwork, hosts []string, labelHosts bool, update func(host string, port int, status string)) ([]portStatus, []skippedHost, bool) {
+	var statuses []portStatus
+	var skipped []skippedHost
+	for _, host := range hosts {
+		host := host
+		if defaultScope.current().checkHost(scopeAddress(nw, host)) != nil {
+			skip := skippedHost{Host: host, Status: statusOutOfScope}
+			skipped = append(skipped, skip)
+			for _, def := range portCatalog() {
+				if update != nil {
+					update(host, def.Port, skip.reason())
+				}
+			}
+			continue
+		}
+		ports, canceled := scanPorts(ctx, nw, host, func(port int, status string) {
+			if update != nil {
+				update(host, port, status)
+			}
+		})
+		if labelHosts {
+			for i := range ports {
+				ports[i].Host = host
+			}
+		}
+		statuses = append(statuses, ports...)
+		if canceled {
+			return statuses, skipped, true
+		}
+	}
+	return statuses, skipped, false
+}
+
+func baselineFindings(hosts []string, ports []portStatus) []vulnerabilityFinding {
+	if len(hosts) == 1 {
+		return defaultBaselines.evaluate(hosts[0], ports)
+	}
+	var findings []vulnerabilityFinding
+	for _, host := range hosts {
+		var hostPorts []portStatus
+		for _, ps := range ports {
+			if ps.Host == host {
+				hostPorts = append(hostPorts, ps)
+			}
+		}
+		for _, f := range defaultBaselines.evaluate(host, hostPorts) {
+			f.Service = fmt.Sprintf("%s %s", host, f.Service)
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+func (ps portStatus) key() string {
+	return fmt.Sprintf("%s|%d", ps.Host, ps.Port)
+}
+
+func (ps portStatus) label() string {
+	if ps.Host != "" {
+		return fmt.Sprintf("%s %d/tcp (%s)", ps.Host, ps.Port, ps.Service)
+	}
+	return fmt.Sprintf("%d/tcp (%s)", ps.Port, ps.Service)
+}
+
+func (ps portStatus) row() string {
+	switch {
+	case ps.Port == 0:
+		return fmt.Sprintf("%-15s %s", ps.Host, ps.Status)
+	case ps.Host != "":
+		return fmt.Sprintf("%-15s %-5d/tcp %-16s %s", ps.Host, ps.Port, ps.Service, ps.Status)
+	default:
+		return fmt.Sprintf("%-5d/tcp %-16s %s", ps.Port, ps.Service, ps.Status)
+	}
+}
+
+func scanPorts(ctx context.Context, nw probeNetwork, target string, update func(port int, status string)) ([]portStatus, bool) {
+	defs := portCatalog()
+	statuses := make([]portStatus, 0, len(defs))
+	rc := runControlFrom(ctx)
+	prior := rc.resumed()
+
+	for _, def := range defs {
+		if !rc.wait(ctx) {
+			return statuses, true
+		}
+
+		if ps, ok := prior.priorPort(target, def.Port); ok {
+			statuses = append(statuses, ps)
+			if update != nil {
+				update(def.Port, ps.Status)
+			}
+			continue
+		}
+
+		if update != nil {
+			update(def.Port, "scanning...")
+		}
+
+		address := net.JoinHostPort(target, strconv.Itoa(def.Port))
+		state := scanPort(nw, address)
+		ps := portStatus{Port: def.Port, Service: def.Service, Status: state}
+		statuses = append(statuses, ps)
+		rc.progress(func(cp *runCheckpoint) {
+			ps.Host = target
+			cp.Record.Ports = append(cp.Record.Ports, ps)
+		})
+
+		if update != nil {
+			update(def.Port, state)
+		}
+	}
+
+	return statuses, false
+}
+
+func scanPort(nw probeNetwork, address string) string {
+	conn, err := nw.dialTCP(address, 500*time.Millisecond)
 	if err != nil {
+		if errors.Is(err, errProxyUnavailable) {
+			return "error (proxy unavailable)"
+		}
 		var netErr net.Error
 		if errors.As(err, &netErr) && netErr.Timeout() {
 			return "filtered (timeout)"
@@ -208,16 +496,27 @@ func (m *scannerModule) setStatus(text string) {
 	}
 }
 
-func (m *scannerModule) updateTargetDetails(target string) {
+func (m *scannerModule) updateTargetDetails(hosts []string) {
 	if m.detailsLabel == nil {
 		return
 	}
 
-	if target == "" {
+	if len(hosts) == 0 {
 		m.detailsLabel.SetText("No target selected.")
 		return
 	}
 
+	if len(hosts) > 1 {
+		m.detailsLabel.SetText(strings.Join([]string{
+			fmt.Sprintf("Targets: %d hosts", len(hosts)),
+			fmt.Sprintf("From %s to %s", hosts[0], hosts[len(hosts)-1]),
+			fmt.Sprintf("Last scan: %s", time.Now().Format(time.RFC1123)),
+		}, "\n"))
+		return
+	}
+
+	target := hosts[0]
+
 	lines := []string{
 		fmt.Sprintf("Target: %s", target),
 	}
@@ -262,12 +561,13 @@ func (m *scannerModule) populateSystemDetails() {
 		fmt.Sprintf("GOMAXPROCS: %d", runtime.GOMAXPROCS(0)),
 		fmt.Sprintf("Time: %s", time.Now().Format(time.RFC1123)),
 	}
+	lines = append(lines, detectNetwork().lines()...)
 
 	m.systemLabel.SetText(strings.Join(lines, "\n"))
 }
 
-func (m *scannerModule) setPortStatus(port int, status string) {
-	if idx, ok := m.portIndex[port]; ok {
+func (m *scannerModule) setPortStatus(host string, port int, status string) {
+	if idx, ok := m.portIndex[net.JoinHostPort(host, strconv.Itoa(port))]; ok {
 		m.portStatuses[idx].Status = status
 		m.refreshResults()
 	}
@@ -297,10 +597,8 @@ func (m *scannerModule) resetDisplayState() {
 	m.setScanActive(false)
 	m.clearPortStatuses()
 	m.setStatus("Enter a hostname or IP address to begin scanning.")
-	m.updateTargetDetails("")
-	if m.systemLabel != nil {
-		m.systemLabel.SetText("System info not yet captured.")
-	}
+	m.updateTargetDetails(nil)
+	m.populateSystemDetails()
 }
 
 func (m *scannerModule) setScanActive(active bool) {
@@ -313,19 +611,40 @@ func (m *scannerModule) setScanActive(active bool) {
 		}
 		m.scanButton.Refresh()
 	}
+	if m.pauseButton != nil {
+		m.pauseButton.SetText("Pause")
+		if active {
+			m.pauseButton.Enable()
+		} else {
+			m.pauseButton.Disable()
+		}
+	}
 }
 
-func (m *scannerModule) initPortStatuses(defaultStatus string) {
+func (m *scannerModule) initPortStatuses(hosts []string, skipped []skippedHost, defaultStatus string, labelHosts bool) {
 	defs := portCatalog()
-	m.portStatuses = make([]portStatus, len(defs))
-	m.portIndex = make(map[int]int, len(defs))
-	for i, def := range defs {
-		m.portStatuses[i] = portStatus{
-			Port:    def.Port,
-			Service: def.Service,
-			Status:  defaultStatus,
+	m.portStatuses = make([]portStatus, 0, len(hosts)*len(defs)+len(skipped))
+	m.portIndex = make(map[string]int, len(hosts)*len(defs))
+	for _, host := range hosts {
+		label := ""
+		if labelHosts {
+			label = host
 		}
-		m.portIndex[def.Port] = i
+		for _, def := range defs {
+			m.portIndex[net.JoinHostPort(host, strconv.Itoa(def.Port))] = len(m.portStatuses)
+			m.portStatuses = append(m.portStatuses, portStatus{
+				Host:    label,
+				Port:    def.Port,
+				Service: def.Service,
+				Status:  defaultStatus,
+			})
+		}
+	}
+	for _, skip := range skipped {
+		m.portStatuses = append(m.portStatuses, portStatus{
+			Host:   skip.Host,
+			Status: skip.reason(),
+		})
 	}
 	m.refreshResults()
 }
@@ -0,0 +1,36 @@
+package modules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandTargets(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"example.com", []string{"example.com"}},
+		{"10.0.0.1, 10.0.0.1;host.lan", []string{"10.0.0.1", "host.lan"}},
+		{"10.0.0.0/30", []string{"10.0.0.1", "10.0.0.2"}},
+		{"10.0.0.8/31", []string{"10.0.0.8", "10.0.0.9"}},
+		{"192.168.1.254-255\n10.1.1.1", []string{"192.168.1.254", "192.168.1.255", "10.1.1.1"}},
+		{"2001:db8::/126", []string{"2001:db8::", "2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+	}
+	for _, tt := range tests {
+		got, err := expandTargets(tt.input)
+		if err != nil {
+			t.Errorf("expandTargets(%q): %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandTargets(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"10.0.0.0/8", "10.0.0.0/21", "10.0.0.9-1"} {
+		if _, err := expandTargets(bad); err == nil {
+			t.Errorf("expandTargets(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,829 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+const schedulesFile = "schedules.json"
+
+const noCredentialOption = "No credential"
+
+const (
+	schedulerTick    = 15 * time.Second
+	maxMissedCounted = 1000
+)
+
+type scheduledJob struct {
+	jobSpec
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Cron       string    `json:"cron"`
+	Enabled    bool      `json:"enabled"`
+	Created    time.Time `json:"created"`
+	LastRun    time.Time `json:"last_run,omitempty"`
+	LastStatus string    `json:"last_status,omitempty"`
+	NextRun    time.Time `json:"-"`
+}
+
+type schedulerState struct {
+	Jobs      []scheduledJob `json:"jobs"`
+	LastCheck time.Time      `json:"last_check"`
+}
+
+type jobScheduler struct {
+	mu        sync.Mutex
+	loadOnce  sync.Once
+	startOnce sync.Once
+	ctx       context.Context
+	state     schedulerState
+	running   map[string]context.CancelFunc
+	controls  map[string]*runControl
+	wg        sync.WaitGroup
+	listeners []func()
+}
+
+var defaultScheduler = &jobScheduler{running: map[string]context.CancelFunc{}, controls: map[string]*runControl{}}
+
+func (s *jobScheduler) load() {
+	s.loadOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := loadJSON(schedulesFile, &s.state); err != nil {
+			log.Printf("scheduler: %v", err)
+		}
+		now := time.Now()
+		for i := range s.state.Jobs {
+			s.scheduleNextLocked(&s.state.Jobs[i], now)
+		}
+	})
+}
+
+func (s *jobScheduler) start(ctx context.Context) {
+	s.load()
+	s.startOnce.Do(func() {
+		now := time.Now()
+
+		s.mu.Lock()
+		s.ctx = ctx
+		missed := s.detectMissedLocked(now)
+		s.state.LastCheck = now
+		s.saveLocked()
+		s.mu.Unlock()
+
+		for _, rec := range missed {
+			scanHistory().add(rec)
+			notifyUser("Missed scheduled scan", rec.Summary)
+		}
+		s.changed()
+
+		go s.loop(ctx)
+	})
+}
+
+func (s *jobScheduler) loop(ctx context.Context) {
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.tick(now)
+		}
+	}
+}
+
+func (s *jobScheduler) tick(now time.Time) {
+	var (
+		launch []scheduledJob
+		missed []scanRecord
+	)
+
+	s.mu.Lock()
+	for i := range s.state.Jobs {
+		job := &s.state.Jobs[i]
+		if !job.Enabled || job.NextRun.IsZero() || now.Before(job.NextRun) {
+			continue
+		}
+		if _, busy := s.running[job.ID]; busy {
+			missed = append(missed, missedRecord(*job, job.NextRun, now, 1, "the previous run was still in progress"))
+			job.LastStatus = runMissed
+		} else {
+			launch = append(launch, *job)
+		}
+		s.scheduleNextLocked(job, now)
+	}
+	s.state.LastCheck = now
+	s.saveLocked()
+	s.mu.Unlock()
+
+	for _, rec := range missed {
+		scanHistory().add(rec)
+		notifyUser("Missed scheduled scan", rec.Summary)
+	}
+	for _, job := range launch {
+		s.launch(job)
+	}
+	if len(missed) > 0 || len(launch) > 0 {
+		s.changed()
+	}
+}
+
+func (s *jobScheduler) launch(job scheduledJob) bool {
+	return s.launchRun(job.ID, func(ctx context.Context) scanRecord {
+		return runHeadless(ctx, job.jobSpec, job.ID)
+	})
+}
+
+// resumeRun continues an interrupted run. Runs of scheduled jobs count as a
+// run of their job; other runs are tracked by their record ID.
+func (s *jobScheduler) resumeRun(cp runCheckpoint) bool {
+	id := cp.Record.JobID
+	if id == "" {
+		id = cp.Record.ID
+	}
+	return s.launchRun(id, func(ctx context.Context) scanRecord {
+		return resumeHeadless(ctx, cp)
+	})
+}
+
+func (s *jobScheduler) launchRun(id string, run func(ctx context.Context) scanRecord) bool {
+	s.mu.Lock()
+	if _, busy := s.running[id]; busy {
+		s.mu.Unlock()
+		return false
+	}
+	parent := context.Background()
+	if s.ctx != nil {
+		parent = context.WithoutCancel(s.ctx)
+	}
+	rc := newRunControl()
+	ctx, cancel := context.WithCancel(withRunControl(parent, rc))
+	s.running[id] = cancel
+	s.controls[id] = rc
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+
+		record := run(ctx)
+
+		s.mu.Lock()
+		delete(s.running, id)
+		delete(s.controls, id)
+		name := record.Module
+		if stored := s.findLocked(id); stored != nil {
+			stored.LastRun = record.Started
+			stored.LastStatus = record.Status
+			name = stored.Name
+		}
+		s.saveLocked()
+		s.mu.Unlock()
+
+		if record.Status == runFailed {
+			notifyUser("Scheduled scan failed", fmt.Sprintf("%s (%s): %s", name, record.Target, record.Error))
+		}
+		s.changed()
+	}()
+
+	s.changed()
+	return true
+}
+
+func (s *jobScheduler) setPaused(id string, paused bool) {
+	s.mu.Lock()
+	rc := s.controls[id]
+	s.mu.Unlock()
+	if rc == nil {
+		return
+	}
+	if paused {
+		rc.pause()
+	} else {
+		rc.unpause()
+	}
+	s.changed()
+}
+
+func (s *jobScheduler) isPaused(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.controls[id].paused()
+}
+
+func (s *jobScheduler) detectMissedLocked(now time.Time) []scanRecord {
+	var missed []scanRecord
+	for i := range s.state.Jobs {
+		job := &s.state.Jobs[i]
+		if !job.Enabled {
+			continue
+		}
+		sched, err := parseCron(job.Cron)
+		if err != nil {
+			continue
+		}
+
+		from := job.Created
+		if job.LastRun.After(from) {
+			from = job.LastRun
+		}
+		if s.state.LastCheck.After(from) {
+			from = s.state.LastCheck
+		}
+
+		var first time.Time
+		count := 0
+		for t := sched.next(from); !t.IsZero() && t.Before(now) && count < maxMissedCounted; t = sched.next(t) {
+			if first.IsZero() {
+				first = t
+			}
+			count++
+		}
+		if count > 0 {
+			missed = append(missed, missedRecord(*job, first, now, count, "Rodent was not running"))
+			job.LastStatus = runMissed
+		}
+	}
+	return missed
+}
+
+func missedRecord(job scheduledJob, due, now time.Time, count int, reason string) scanRecord {
+	record := newScanRecord(job.Module, job.Target)
+	record.JobID = job.ID
+	record.Started = due
+	record.Finished = now
+	record.Status = runMissed
+	record.Summary = fmt.Sprintf("%s missed %d run(s) since %s because %s.",
+		job.Name, count, due.Format("2006-01-02 15:04"), reason)
+	return record
+}
+
+func (s *jobScheduler) scheduleNextLocked(job *scheduledJob, now time.Time) {
+	sched, err := parseCron(job.Cron)
+	if err != nil {
+		job.NextRun = time.Time{}
+		return
+	}
+	job.NextRun = sched.next(now)
+}
+
+func (s *jobScheduler) findLocked(id string) *scheduledJob {
+	for i := range s.state.Jobs {
+		if s.state.Jobs[i].ID == id {
+			return &s.state.Jobs[i]
+		}
+	}
+	return nil
+}
+
+func (s *jobScheduler) saveLocked() {
+	if err := saveJSON(schedulesFile, s.state); err != nil {
+		log.Printf("scheduler: %v", err)
+	}
+}
+
+func (s *jobScheduler) jobs() []scheduledJob {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]scheduledJob(nil), s.state.Jobs...)
+}
+
+func (s *jobScheduler) isRunning(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.running[id]
+	return ok
+}
+
+func (s *jobScheduler) addJob(job scheduledJob) error {
+	if strings.TrimSpace(job.Target) == "" {
+		return fmt.Errorf("a target is required")
+	}
+	if _, err := parseCron(job.Cron); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	switch job.Module {
+	case moduleMapper:
+		if _, err := normalizeSubnet(job.Target); err != nil {
+			return fmt.Errorf("invalid subnet: %w", err)
+		}
+	case moduleScanner:
+		if _, err := expandTargets(job.Target); err != nil {
+			return fmt.Errorf("invalid target list: %w", err)
+		}
+	case moduleVulnerability:
+	default:
+		return fmt.Errorf("unknown module %q", job.Module)
+	}
+	if job.CredentialID != "" {
+		if job.Module != moduleVulnerability {
+			return fmt.Errorf("credentials are only used by vulnerability checks")
+		}
+		if _, err := defaultVault.resolve(job.CredentialID, job.Target); err != nil {
+			return err
+		}
+	}
+	if job.Source == sourceAny {
+		job.Source = ""
+	}
+	if _, err := parseSource(job.Source); err != nil {
+		return fmt.Errorf("invalid source: %w", err)
+	}
+	if _, err := parseDiscovery(job.Discovery); err != nil {
+		return err
+	}
+	if job.Name == "" {
+		job.Name = fmt.Sprintf("%s %s", job.Module, job.Target)
+	}
+
+	s.load()
+	s.mu.Lock()
+	job.ID = newID()
+	job.Created = time.Now()
+	job.Enabled = true
+	s.scheduleNextLocked(&job, job.Created)
+	s.state.Jobs = append(s.state.Jobs, job)
+	s.saveLocked()
+	s.mu.Unlock()
+
+	s.changed()
+	return nil
+}
+
+func (s *jobScheduler) setEnabled(id string, enabled bool) {
+	s.mu.Lock()
+	if job := s.findLocked(id); job != nil {
+		job.Enabled = enabled
+		s.scheduleNextLocked(job, time.Now())
+	}
+	s.saveLocked()
+	s.mu.Unlock()
+	s.changed()
+}
+
+func (s *jobScheduler) removeJob(id string) {
+	s.mu.Lock()
+	if cancel, ok := s.running[id]; ok {
+		cancel()
+	}
+	for i := range s.state.Jobs {
+		if s.state.Jobs[i].ID == id {
+			s.state.Jobs = append(s.state.Jobs[:i], s.state.Jobs[i+1:]...)
+			break
+		}
+	}
+	s.saveLocked()
+	s.mu.Unlock()
+	s.changed()
+}
+
+func (s *jobScheduler) wait() {
+	s.wg.Wait()
+}
+
+func (s *jobScheduler) subscribe(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *jobScheduler) changed() {
+	s.mu.Lock()
+	listeners := append([]func(){}, s.listeners...)
+	s.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+type schedulerModule struct {
+	content      fyne.CanvasObject
+	nameEntry    *widget.Entry
+	moduleSelect *widget.Select
+	targetEntry  *widget.Entry
+	cronEntry    *widget.Entry
+	credSelect   *widget.Select
+	sourceEntry  *widget.SelectEntry
+	checkGroup   *widget.CheckGroup
+	discovery    *widget.Select
+	statusLabel  *widget.Label
+	jobList      *widget.List
+	runList      *widget.List
+	resumeList   *widget.List
+	toggleButton *widget.Button
+	runButton    *widget.Button
+	pauseButton  *widget.Button
+	deleteButton *widget.Button
+	jobs         []scheduledJob
+	runs         []scanRecord
+	interrupted  []runCheckpoint
+	selected     int
+	selectedRun  int
+}
+
+func (m *schedulerModule) Name() string {
+	return "Scheduler"
+}
+
+func (m *schedulerModule) Content() fyne.CanvasObject {
+	if m.content != nil {
+		return m.content
+	}
+
+	m.selected = -1
+	m.selectedRun = -1
+
+	m.nameEntry = widget.NewEntry()
+	m.nameEntry.SetPlaceHolder("Job name (optional)")
+
+	m.checkGroup = newCategoryGroup(nil)
+	m.discovery = widget.NewSelect(discoveryOptions(), nil)
+	m.discovery.SetSelectedIndex(0)
+	m.moduleSelect = widget.NewSelect(jobModules(), func(module string) {
+		if module == moduleVulnerability {
+			m.checkGroup.Show()
+		} else {
+			m.checkGroup.Hide()
+		}
+		if module == moduleMapper {
+			m.discovery.Show()
+		} else {
+			m.discovery.Hide()
+		}
+	})
+	m.moduleSelect.SetSelectedIndex(0)
+
+	m.targetEntry = widget.NewEntry()
+	m.targetEntry.SetPlaceHolder("Target host or subnet")
+
+	m.cronEntry = widget.NewEntry()
+	m.cronEntry.SetPlaceHolder("Cron (e.g. 0 2 * * *)")
+
+	m.credSelect = widget.NewSelect(nil, nil)
+	m.credSelect.PlaceHolder = "Credential (vault locked)"
+	m.refreshCredentials()
+
+	m.sourceEntry = newSourceEntry()
+
+	addButton := widget.NewButton("Add Job", m.addJob)
+
+	rowHeight := m.targetEntry.MinSize().Height
+	formRow := container.NewHBox(
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(160, rowHeight)), m.nameEntry),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(190, rowHeight)), m.moduleSelect),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(180, rowHeight)), m.targetEntry),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(150, rowHeight)), m.cronEntry),
+		layout.NewSpacer(),
+	)
+	credentialRow := container.NewHBox(
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(260, rowHeight)), m.credSelect),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(240, rowHeight)), m.sourceEntry),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(140, rowHeight)), m.discovery),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(100, rowHeight)), addButton),
+		layout.NewSpacer(),
+	)
+
+	m.statusLabel = widget.NewLabel("Jobs run while Rodent or the headless daemon (rodent -daemon) is running.")
+
+	m.jobList = widget.NewList(
+		func() int { return len(m.jobs) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i int, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(m.describeJob(m.jobs[i]))
+		},
+	)
+	m.jobList.OnSelected = func(id widget.ListItemID) {
+		m.selected = id
+		m.updateActions()
+	}
+	m.jobList.OnUnselected = func(widget.ListItemID) {
+		m.selected = -1
+		m.updateActions()
+	}
+
+	m.toggleButton = widget.NewButton("Disable", m.toggleSelected)
+	m.runButton = widget.NewButton("Run Now", m.runSelected)
+	m.pauseButton = widget.NewButton("Pause", m.pauseSelected)
+	m.deleteButton = widget.NewButton("Delete", m.deleteSelected)
+	actionRow := container.NewHBox(m.toggleButton, m.runButton, m.pauseButton, m.deleteButton, layout.NewSpacer())
+
+	jobScroll := container.NewVScroll(m.jobList)
+	jobScroll.SetMinSize(fyne.NewSize(0, 150))
+
+	m.runList = widget.NewList(
+		func() int { return len(m.runs) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i int, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(m.runs[i].String())
+		},
+	)
+	runScroll := container.NewVScroll(m.runList)
+	runScroll.SetMinSize(fyne.NewSize(0, 150))
+
+	m.resumeList = widget.NewList(
+		func() int { return len(m.interrupted) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i int, obj fyne.CanvasObject) {
+			obj.(*widget.Label).SetText(m.interrupted[i].String())
+		},
+	)
+	m.resumeList.OnSelected = func(id widget.ListItemID) { m.selectedRun = id }
+	m.resumeList.OnUnselected = func(widget.ListItemID) { m.selectedRun = -1 }
+	resumeButton := widget.NewButton("Resume", m.resumeSelected)
+	discardButton := widget.NewButton("Discard", m.discardSelected)
+	resumeScroll := container.NewVScroll(m.resumeList)
+	resumeScroll.SetMinSize(fyne.NewSize(0, 90))
+
+	m.content = container.NewVBox(
+		widget.NewLabelWithStyle("Scheduler", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Run Scanner, Network Mapper and Vulnerability Scanner jobs on a cron schedule."),
+		formRow,
+		credentialRow,
+		m.checkGroup,
+		m.statusLabel,
+		widget.NewCard("Scheduled Jobs", "Select a job to enable, disable, run or delete it.", container.NewVBox(jobScroll, actionRow)),
+		widget.NewCard("Interrupted Runs", "Stopped or interrupted runs continue from their last checkpoint.",
+			container.NewVBox(resumeScroll, container.NewHBox(resumeButton, discardButton, layout.NewSpacer()))),
+		widget.NewCard("Run History", "Scheduled runs, including missed and failed ones.", container.NewMax(runScroll)),
+	)
+
+	defaultScheduler.subscribe(func() { m.queueOnMain(m.reload) })
+	defaultVault.subscribe(func() { m.queueOnMain(m.refreshCredentials) })
+	defaultCheckpoints.subscribe(func() { m.queueOnMain(m.reloadInterrupted) })
+	scanHistory().subscribe(func(rec scanRecord) {
+		if rec.JobID != "" {
+			m.queueOnMain(m.reload)
+		}
+	})
+	m.reload()
+	m.reloadInterrupted()
+
+	return m.content
+}
+
+func (m *schedulerModule) describeJob(job scheduledJob) string {
+	state := "on "
+	if !job.Enabled {
+		state = "off"
+	}
+	next := "-"
+	if job.Enabled && !job.NextRun.IsZero() {
+		next = job.NextRun.Format("2006-01-02 15:04")
+	}
+	last := job.LastStatus
+	if defaultScheduler.isPaused(job.ID) {
+		last = "paused"
+	} else if defaultScheduler.isRunning(job.ID) {
+		last = "running"
+	}
+	if last == "" {
+		last = "never run"
+	}
+	line := fmt.Sprintf("[%s] %-18s %-21s %-18s %-12s next: %s  last: %s",
+		state, job.Name, job.Module, job.Target, job.Cron, next, strings.ToUpper(last))
+	if job.CredentialID != "" {
+		line += fmt.Sprintf("  credential: %s", job.CredentialID)
+	}
+	if job.Source != "" {
+		line += fmt.Sprintf("  source: %s", job.Source)
+	}
+	if len(job.Categories) > 0 {
+		line += fmt.Sprintf("  checks: %s", strings.Join(job.Categories, ", "))
+	}
+	if job.Discovery != "" {
+		line += fmt.Sprintf("  discovery: %s", discoveryLabels[job.Discovery])
+	}
+	if len(job.Acknowledged) > 0 {
+		line += fmt.Sprintf("  acknowledged: %s", strings.Join(job.Acknowledged, ", "))
+	}
+	return line
+}
+
+func (m *schedulerModule) addJob() {
+	job := scheduledJob{
+		jobSpec: jobSpec{
+			Module:       m.moduleSelect.Selected,
+			Target:       strings.TrimSpace(m.targetEntry.Text),
+			CredentialID: credentialIDFromLabel(m.credSelect.Selected),
+			Source:       strings.TrimSpace(m.sourceEntry.Text),
+		},
+		Name: strings.TrimSpace(m.nameEntry.Text),
+		Cron: strings.TrimSpace(m.cronEntry.Text),
+	}
+	if job.Module == moduleVulnerability {
+		job.Categories = categoriesFromLabels(m.checkGroup.Selected)
+		if len(job.Categories) == 0 {
+			m.setStatus("Select at least one check category.")
+			return
+		}
+	}
+	if job.Module == moduleMapper {
+		job.Discovery, _ = parseDiscovery(m.discovery.Selected)
+	}
+	ranges, err := targetSensitivity(job.Module, job.Target)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Unable to add job: %v", err))
+		return
+	}
+	err = confirmRun(job.jobSpec, ranges, func(acknowledged []string) {
+		job.Acknowledged = acknowledged
+		if err := defaultScheduler.addJob(job); err != nil {
+			m.setStatus(fmt.Sprintf("Unable to add job: %v", err))
+			return
+		}
+		m.nameEntry.SetText("")
+		m.targetEntry.SetText("")
+		m.cronEntry.SetText("")
+		m.credSelect.SetSelectedIndex(0)
+		m.sourceEntry.SetText("")
+		m.discovery.SetSelectedIndex(0)
+		m.setStatus("Job added.")
+	})
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Unable to add job: %v", err))
+	}
+}
+
+func (m *schedulerModule) refreshCredentials() {
+	options := []string{noCredentialOption}
+	creds, err := defaultVault.list()
+	if err == nil {
+		for _, c := range creds {
+			options = append(options, c.label())
+		}
+		m.credSelect.Enable()
+	} else {
+		m.credSelect.Disable()
+	}
+	m.credSelect.Options = options
+	m.credSelect.SetSelectedIndex(0)
+}
+
+func credentialIDFromLabel(label string) string {
+	start := strings.LastIndex(label, "[")
+	if label == noCredentialOption || start < 0 || !strings.HasSuffix(label, "]") {
+		return ""
+	}
+	return label[start+1 : len(label)-1]
+}
+
+func (m *schedulerModule) selectedJob() (scheduledJob, bool) {
+	if m.selected < 0 || m.selected >= len(m.jobs) {
+		return scheduledJob{}, false
+	}
+	return m.jobs[m.selected], true
+}
+
+func (m *schedulerModule) toggleSelected() {
+	job, ok := m.selectedJob()
+	if !ok {
+		return
+	}
+	defaultScheduler.setEnabled(job.ID, !job.Enabled)
+}
+
+func (m *schedulerModule) runSelected() {
+	job, ok := m.selectedJob()
+	if !ok {
+		return
+	}
+	if !defaultScheduler.launch(job) {
+		m.setStatus(fmt.Sprintf("%s is already running.", job.Name))
+		return
+	}
+	m.setStatus(fmt.Sprintf("Started %s.", job.Name))
+}
+
+func (m *schedulerModule) pauseSelected() {
+	job, ok := m.selectedJob()
+	if !ok || !defaultScheduler.isRunning(job.ID) {
+		return
+	}
+	paused := !defaultScheduler.isPaused(job.ID)
+	defaultScheduler.setPaused(job.ID, paused)
+	if paused {
+		m.setStatus(fmt.Sprintf("Paused %s.", job.Name))
+	} else {
+		m.setStatus(fmt.Sprintf("Resumed %s.", job.Name))
+	}
+}
+
+func (m *schedulerModule) selectedInterrupted() (runCheckpoint, bool) {
+	if m.selectedRun < 0 || m.selectedRun >= len(m.interrupted) {
+		return runCheckpoint{}, false
+	}
+	return m.interrupted[m.selectedRun], true
+}
+
+func (m *schedulerModule) resumeSelected() {
+	cp, ok := m.selectedInterrupted()
+	if !ok {
+		return
+	}
+	if !defaultScheduler.resumeRun(cp) {
+		m.setStatus("That job is already running.")
+		return
+	}
+	m.resumeList.UnselectAll()
+	m.setStatus(fmt.Sprintf("Resuming %s of %s.", cp.Module, cp.Record.Target))
+}
+
+func (m *schedulerModule) discardSelected() {
+	cp, ok := m.selectedInterrupted()
+	if !ok {
+		return
+	}
+	defaultCheckpoints.remove(cp.Record.ID)
+	m.resumeList.UnselectAll()
+	m.setStatus(fmt.Sprintf("Discarded the checkpoint of %s.", cp.Record.Target))
+}
+
+func (m *schedulerModule) reloadInterrupted() {
+	m.interrupted = defaultCheckpoints.interrupted()
+	if m.selectedRun >= len(m.interrupted) {
+		m.selectedRun = -1
+	}
+	m.resumeList.Refresh()
+}
+
+func (m *schedulerModule) deleteSelected() {
+	job, ok := m.selectedJob()
+	if !ok {
+		return
+	}
+	defaultScheduler.removeJob(job.ID)
+	m.jobList.UnselectAll()
+	m.setStatus(fmt.Sprintf("Deleted %s.", job.Name))
+}
+
+func (m *schedulerModule) reload() {
+	m.jobs = defaultScheduler.jobs()
+	m.runs = nil
+	for _, rec := range scanHistory().recent(0) {
+		if rec.JobID != "" {
+			m.runs = append(m.runs, rec)
+		}
+	}
+	if m.selected >= len(m.jobs) {
+		m.selected = -1
+	}
+	m.jobList.Refresh()
+	m.runList.Refresh()
+	m.updateActions()
+}
+
+func (m *schedulerModule) updateActions() {
+	job, ok := m.selectedJob()
+	for _, btn := range []*widget.Button{m.toggleButton, m.runButton, m.pauseButton, m.deleteButton} {
+		if ok {
+			btn.Enable()
+		} else {
+			btn.Disable()
+		}
+	}
+	if ok && !job.Enabled {
+		m.toggleButton.SetText("Enable")
+	} else {
+		m.toggleButton.SetText("Disable")
+	}
+	if !ok || !defaultScheduler.isRunning(job.ID) {
+		m.pauseButton.Disable()
+	}
+	if ok && defaultScheduler.isPaused(job.ID) {
+		m.pauseButton.SetText("Resume")
+	} else {
+		m.pauseButton.SetText("Pause")
+	}
+}
+
+func (m *schedulerModule) setStatus(text string) {
+	if m.statusLabel != nil {
+		m.statusLabel.SetText(text)
+	}
+}
+
+func (m *schedulerModule) queueOnMain(fn func()) {
+	if app := fyne.CurrentApp(); app != nil {
+		if drv := app.Driver(); drv != nil {
+			if runner, ok := drv.(interface{ RunOnMain(func()) }); ok {
+				runner.RunOnMain(fn)
+				return
+			}
+		}
+	}
+	fn()
+}
@@ -0,0 +1,594 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+const scopeFile = "scope.json"
+
+const scopeTimeLayout = "2006-01-02 15:04"
+
+type engagementScope struct {
+	Name      string    `json:"name"`
+	Allowed   []string  `json:"allowed"`
+	Denied    []string  `json:"denied,omitempty"`
+	NotBefore time.Time `json:"not_before,omitempty"`
+	NotAfter  time.Time `json:"not_after,omitempty"`
+}
+
+func (s engagementScope) defined() bool {
+	return len(s.Allowed) > 0
+}
+
+func (s engagementScope) label() string {
+	if s.Name != "" {
+		return fmt.Sprintf("%q", s.Name)
+	}
+	return "the engagement scope"
+}
+
+func (s engagementScope) validate() error {
+	if _, err := parseAddressSet(s.Allowed); err != nil {
+		return fmt.Errorf("allowed: %w", err)
+	}
+	if _, err := parseAddressSet(s.Denied); err != nil {
+		return fmt.Errorf("denied: %w", err)
+	}
+	if !s.NotBefore.IsZero() && !s.NotAfter.IsZero() && !s.NotAfter.After(s.NotBefore) {
+		return fmt.Errorf("the validity window ends before it starts")
+	}
+	return nil
+}
+
+func (s engagementScope) checkWindow(now time.Time) error {
+	if !s.NotBefore.IsZero() && now.Before(s.NotBefore) {
+		return fmt.Errorf("%s is not valid until %s", s.label(), s.NotBefore.Format(scopeTimeLayout))
+	}
+	if !s.NotAfter.IsZero() && now.After(s.NotAfter) {
+		return fmt.Errorf("%s expired on %s", s.label(), s.NotAfter.Format(scopeTimeLayout))
+	}
+	return nil
+}
+
+// checkIP is also called for every address during a run, so it enforces the
+// validity window as well and a run stops probing once the window closes.
+func (s engagementScope) checkIP(ip net.IP) error {
+	if !s.defined() {
+		return nil
+	}
+	if err := s.checkWindow(time.Now()); err != nil {
+		return err
+	}
+	allowed, _ := parseAddressSet(s.Allowed)
+	denied, _ := parseAddressSet(s.Denied)
+	if entry := denied.matchingEntry(ip); entry != "" {
+		return fmt.Errorf("%s is in the denied range %s of %s", ip, entry, s.label())
+	}
+	if !allowed.containsIP(ip) {
+		return fmt.Errorf("%s is outside %s", ip, s.label())
+	}
+	return nil
+}
+
+// checkTarget resolves hostnames so that a name pointing outside the
+// allowed networks is refused as well. A name matching an allowed pattern
+// still has to resolve inside the allowed networks when the scope lists any.
+// It returns the addresses it approved; runs dial those instead of resolving
+// the name again (see pinNetwork), so a record that changes after the check
+// cannot lead a probe elsewhere. Without a scope there is nothing to pin.
+func (s engagementScope) checkTarget(target string) ([]net.IP, error) {
+	if !s.defined() {
+		return nil, nil
+	}
+	if err := s.checkWindow(time.Now()); err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(target); ip != nil {
+		return []net.IP{ip}, s.checkIP(ip)
+	}
+
+	allowed, _ := parseAddressSet(s.Allowed)
+	denied, _ := parseAddressSet(s.Denied)
+	if denied.matchesHost(target) {
+		return nil, fmt.Errorf("%s is denied by %s", target, s.label())
+	}
+
+	ips, err := net.LookupIP(target)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %s to check it against %s", target, s.label())
+	}
+	if !allowed.matchesHost(target) && !allowed.hasAddresses() {
+		return nil, fmt.Errorf("%s is outside %s", target, s.label())
+	}
+	for _, ip := range ips {
+		if entry := denied.matchingEntry(ip); entry != "" {
+			return nil, fmt.Errorf("%s resolves to %s, in the denied range %s of %s", target, ip, entry, s.label())
+		}
+		if allowed.hasAddresses() && !allowed.containsIP(ip) {
+			return nil, fmt.Errorf("%s resolves to %s, outside %s", target, ip, s.label())
+		}
+	}
+	return ips, nil
+}
+
+// checkHost repeats the scope check for a host that was accepted before the
+// run started, without resolving names again. Pass the pinned address of a
+// name when there is one.
+func (s engagementScope) checkHost(host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		return s.checkIP(ip)
+	}
+	if !s.defined() {
+		return nil
+	}
+	return s.checkWindow(time.Now())
+}
+
+func (s engagementScope) checkSubnet(network *net.IPNet) error {
+	if !s.defined() {
+		return nil
+	}
+	if err := s.checkWindow(time.Now()); err != nil {
+		return err
+	}
+	allowed, _ := parseAddressSet(s.Allowed)
+	if !allowed.coversNetwork(network) {
+		return fmt.Errorf("%s is not entirely inside %s", network, s.label())
+	}
+	return nil
+}
+
+type scopeStore struct {
+	mu        sync.Mutex
+	loadOnce  sync.Once
+	scope     engagementScope
+	listeners []func()
+}
+
+var defaultScope = &scopeStore{}
+
+func (s *scopeStore) current() engagementScope {
+	s.loadOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err := loadJSON(scopeFile, &s.scope); err != nil {
+			log.Printf("scope: %v", err)
+		}
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scope
+}
+
+func (s *scopeStore) set(scope engagementScope) error {
+	if err := scope.validate(); err != nil {
+		return err
+	}
+	s.current()
+	s.mu.Lock()
+	s.scope = scope
+	err := saveJSON(scopeFile, scope)
+	listeners := append([]func(){}, s.listeners...)
+	s.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+	return err
+}
+
+func (s *scopeStore) subscribe(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+func checkScope(module, target string) ([]net.IP, error) {
+	ips, err := defaultScope.current().checkTarget(target)
+	if err != nil {
+		defaultAudit.recordRefusal(module, target, err.Error())
+	}
+	return ips, err
+}
+
+// checkScopeHosts checks every host and returns the address each name was
+// approved with, preferring IPv4 as most scans are run over it.
+func checkScopeHosts(module string, hosts []string) (map[string]net.IP, error) {
+	pins := map[string]net.IP{}
+	for _, host := range hosts {
+		ips, err := checkScope(module, host)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) != nil || len(ips) == 0 {
+			continue
+		}
+		pin := ips[0]
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				pin = ip
+				break
+			}
+		}
+		pins[strings.ToLower(host)] = pin
+	}
+	return pins, nil
+}
+
+// pinnedNetwork dials the address a name was approved with in place of the
+// name, so neither the resolver nor a proxy resolves it again.
+type pinnedNetwork struct {
+	probeNetwork
+	pins map[string]net.IP
+}
+
+func pinNetwork(nw probeNetwork, pins map[string]net.IP) probeNetwork {
+	if len(pins) == 0 {
+		return nw
+	}
+	return pinnedNetwork{probeNetwork: nw, pins: pins}
+}
+
+func (n pinnedNetwork) pinned(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip, ok := n.pins[strings.ToLower(host)]; ok {
+		return net.JoinHostPort(ip.String(), port)
+	}
+	return address
+}
+
+func (n pinnedNetwork) dialTCP(address string, timeout time.Duration) (net.Conn, error) {
+	return n.probeNetwork.dialTCP(n.pinned(address), timeout)
+}
+
+func (n pinnedNetwork) dialStack(address string, timeout time.Duration) (net.Conn, *tcpStack, error) {
+	return n.probeNetwork.dialStack(n.pinned(address), timeout)
+}
+
+// scopeAddress is what a host is re-checked against during a run: its
+// pinned address when it has one.
+func scopeAddress(nw probeNetwork, host string) string {
+	if n, ok := nw.(pinnedNetwork); ok {
+		if ip, ok := n.pins[strings.ToLower(host)]; ok {
+			return ip.String()
+		}
+	}
+	return host
+}
+
+func checkScopeSubnet(module string, network *net.IPNet) error {
+	err := defaultScope.current().checkSubnet(network)
+	if err != nil {
+		defaultAudit.recordRefusal(module, network.String(), err.Error())
+	}
+	return err
+}
+
+func readEngagementFile(r io.Reader) (engagementScope, error) {
+	var scope engagementScope
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&scope); err != nil {
+		return engagementScope{}, fmt.Errorf("invalid engagement file: %w", err)
+	}
+	if !scope.defined() {
+		return engagementScope{}, fmt.Errorf("engagement file lists no allowed targets")
+	}
+	return scope, scope.validate()
+}
+
+type engagementModule struct {
+	content      fyne.CanvasObject
+	nameEntry    *widget.Entry
+	allowedEntry *widget.Entry
+	deniedEntry  *widget.Entry
+	fromEntry    *widget.Entry
+	untilEntry   *widget.Entry
+	checkEntry   *widget.Entry
+	scopeLabel   *widget.Label
+	statusLabel  *widget.Label
+
+	exclusionEntry *widget.Entry
+	filesLabel     *widget.Label
+	exclusionFiles []string
+}
+
+func (m *engagementModule) Name() string {
+	return "Engagement"
+}
+
+func (m *engagementModule) Content() fyne.CanvasObject {
+	if m.content != nil {
+		return m.content
+	}
+
+	m.nameEntry = widget.NewEntry()
+	m.nameEntry.SetPlaceHolder("Engagement name")
+
+	m.allowedEntry = widget.NewMultiLineEntry()
+	m.allowedEntry.SetPlaceHolder("Allowed CIDRs, IPs, ranges or hostnames\n10.20.0.0/16\n*.client.example")
+	m.allowedEntry.SetMinRowsVisible(6)
+
+	m.deniedEntry = widget.NewMultiLineEntry()
+	m.deniedEntry.SetPlaceHolder("Denied ranges inside the allowed ones\n10.20.5.0/24")
+	m.deniedEntry.SetMinRowsVisible(6)
+
+	m.fromEntry = widget.NewEntry()
+	m.fromEntry.SetPlaceHolder("Valid from (YYYY-MM-DD HH:MM)")
+	m.untilEntry = widget.NewEntry()
+	m.untilEntry.SetPlaceHolder("Valid until (YYYY-MM-DD HH:MM)")
+
+	rowHeight := m.nameEntry.MinSize().Height
+	windowRow := container.NewHBox(
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(220, rowHeight)), m.nameEntry),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(230, rowHeight)), m.fromEntry),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(230, rowHeight)), m.untilEntry),
+		layout.NewSpacer(),
+	)
+
+	listsRow := container.NewGridWithColumns(2,
+		widget.NewCard("Allowed", "", m.allowedEntry),
+		widget.NewCard("Denied", "", m.deniedEntry),
+	)
+
+	saveButton := widget.NewButton("Save Scope", m.save)
+	loadButton := widget.NewButton("Load Engagement File...", m.loadFile)
+	clearButton := widget.NewButton("Clear Scope", func() {
+		if err := defaultScope.set(engagementScope{}); err != nil {
+			m.setStatus(fmt.Sprintf("Unable to clear scope: %v", err))
+		}
+	})
+
+	m.checkEntry = widget.NewEntry()
+	m.checkEntry.SetPlaceHolder("Check a target")
+	checkButton := widget.NewButton("Check", m.checkTarget)
+
+	actionRow := container.NewHBox(
+		saveButton, loadButton, clearButton,
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(24, rowHeight)), widget.NewLabel("")),
+		container.New(layout.NewGridWrapLayout(fyne.NewSize(200, rowHeight)), m.checkEntry),
+		checkButton,
+		layout.NewSpacer(),
+	)
+
+	m.exclusionEntry = widget.NewMultiLineEntry()
+	m.exclusionEntry.SetPlaceHolder("Hosts to skip: IPs, ranges or hostnames\n10.20.1.50\n10.20.1.200-210\nceo-laptop.client.example")
+	m.exclusionEntry.SetMinRowsVisible(4)
+	m.filesLabel = widget.NewLabel("")
+	m.filesLabel.Wrapping = fyne.TextWrapWord
+
+	exclusionButtons := container.NewHBox(
+		widget.NewButton("Save Exclusions", m.saveExclusions),
+		widget.NewButton("Add Exclusion File...", m.addExclusionFile),
+		widget.NewButton("Remove Files", func() {
+			m.exclusionFiles = nil
+			m.showExclusionFiles()
+		}),
+		layout.NewSpacer(),
+	)
+	exclusionsCard := widget.NewCard("Exclusions", "Fragile hosts inside the scope that every module skips and reports as \"skipped (excluded)\".",
+		container.NewVBox(m.exclusionEntry, m.filesLabel, exclusionButtons))
+
+	m.scopeLabel = widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	m.statusLabel = widget.NewLabel("")
+	m.statusLabel.Wrapping = fyne.TextWrapWord
+
+	m.content = container.NewVBox(
+		widget.NewLabelWithStyle("Engagement Scope", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Every module refuses targets outside the allowed networks or the validity window."),
+		m.scopeLabel,
+		windowRow,
+		listsRow,
+		actionRow,
+		m.statusLabel,
+		exclusionsCard,
+	)
+
+	defaultScope.subscribe(func() { m.queueOnMain(m.reload) })
+	defaultExclusions.subscribe(func() { m.queueOnMain(m.reloadExclusions) })
+	m.reload()
+	m.reloadExclusions()
+
+	return m.content
+}
+
+func (m *engagementModule) reload() {
+	scope := defaultScope.current()
+	m.nameEntry.SetText(scope.Name)
+	m.allowedEntry.SetText(strings.Join(scope.Allowed, "\n"))
+	m.deniedEntry.SetText(strings.Join(scope.Denied, "\n"))
+	m.fromEntry.SetText(formatScopeTime(scope.NotBefore))
+	m.untilEntry.SetText(formatScopeTime(scope.NotAfter))
+
+	if !scope.defined() {
+		m.scopeLabel.SetText("No engagement scope defined: targets are not restricted.")
+		return
+	}
+	state := "active"
+	if err := scope.checkWindow(time.Now()); err != nil {
+		state = "outside its validity window"
+	}
+	m.scopeLabel.SetText(fmt.Sprintf("Enforcing %s (%d allowed, %d denied entries, %s).",
+		scope.label(), len(scope.Allowed), len(scope.Denied), state))
+}
+
+func (m *engagementModule) save() {
+	from, err := parseScopeTime(m.fromEntry.Text)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Valid from: %v", err))
+		return
+	}
+	until, err := parseScopeTime(m.untilEntry.Text)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Valid until: %v", err))
+		return
+	}
+	scope := engagementScope{
+		Name:      strings.TrimSpace(m.nameEntry.Text),
+		Allowed:   splitLines(m.allowedEntry.Text),
+		Denied:    splitLines(m.deniedEntry.Text),
+		NotBefore: from,
+		NotAfter:  until,
+	}
+	if err := defaultScope.set(scope); err != nil {
+		m.setStatus(fmt.Sprintf("Unable to save scope: %v", err))
+		return
+	}
+	m.setStatus("Scope saved.")
+}
+
+func (m *engagementModule) loadFile() {
+	win := activeWindow()
+	if win == nil {
+		return
+	}
+	dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+		if err != nil || r == nil {
+			return
+		}
+		defer r.Close()
+		scope, err := readEngagementFile(r)
+		if err != nil {
+			m.setStatus(err.Error())
+			return
+		}
+		if err := defaultScope.set(scope); err != nil {
+			m.setStatus(fmt.Sprintf("Unable to apply engagement file: %v", err))
+			return
+		}
+		m.setStatus(fmt.Sprintf("Loaded engagement file %s.", r.URI().Name()))
+	}, win)
+}
+
+func (m *engagementModule) reloadExclusions() {
+	list := defaultExclusions.current()
+	m.exclusionEntry.SetText(strings.Join(list.Entries, "\n"))
+	m.exclusionFiles = append([]string(nil), list.Files...)
+	m.showExclusionFiles()
+}
+
+func (m *engagementModule) showExclusionFiles() {
+	if len(m.exclusionFiles) == 0 {
+		m.filesLabel.SetText("No exclusion files.")
+		return
+	}
+	m.filesLabel.SetText("Files (re-read on every run): " + strings.Join(m.exclusionFiles, ", "))
+}
+
+func (m *engagementModule) saveExclusions() {
+	list := exclusionList{
+		Entries: splitLines(m.exclusionEntry.Text),
+		Files:   m.exclusionFiles,
+	}
+	if err := defaultExclusions.set(list); err != nil {
+		m.setStatus(fmt.Sprintf("Unable to save exclusions: %v", err))
+		return
+	}
+	m.setStatus("Exclusions saved.")
+}
+
+func (m *engagementModule) addExclusionFile() {
+	win := activeWindow()
+	if win == nil {
+		return
+	}
+	dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+		if err != nil || r == nil {
+			return
+		}
+		r.Close()
+		path := r.URI().Path()
+		if _, err := readExclusionFile(path); err != nil {
+			m.setStatus(err.Error())
+			return
+		}
+		m.exclusionFiles = append(m.exclusionFiles, path)
+		m.showExclusionFiles()
+		m.setStatus(fmt.Sprintf("Added %s. Save exclusions to apply it.", r.URI().Name()))
+	}, win)
+}
+
+func (m *engagementModule) checkTarget() {
+	target := strings.TrimSpace(m.checkEntry.Text)
+	if target == "" {
+		return
+	}
+	scope := defaultScope.current()
+	var err error
+	if _, network, cidrErr := net.ParseCIDR(target); cidrErr == nil {
+		err = scope.checkSubnet(network)
+	} else {
+		_, err = scope.checkTarget(target)
+	}
+	if err != nil {
+		m.setStatus(err.Error())
+		return
+	}
+	if _, skipped, err := partitionExcluded([]string{target}); err == nil && len(skipped) > 0 {
+		m.setStatus(fmt.Sprintf("%s is in scope but %s.", target, skipped[0].reason()))
+		return
+	}
+	m.setStatus(fmt.Sprintf("%s is in scope.", target))
+}
+
+func (m *engagementModule) setStatus(text string) {
+	if m.statusLabel != nil {
+		m.statusLabel.SetText(text)
+	}
+}
+
+func (m *engagementModule) queueOnMain(fn func()) {
+	if app := fyne.CurrentApp(); app != nil {
+		if drv := app.Driver(); drv != nil {
+			if runner, ok := drv.(interface{ RunOnMain(func()) }); ok {
+				runner.RunOnMain(fn)
+				return
+			}
+		}
+	}
+	fn()
+}
+
+func splitLines(input string) []string {
+	var out []string
+	for _, line := range strings.FieldsFunc(input, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func parseScopeTime(input string) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation(scopeTimeLayout, input, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("use the format YYYY-MM-DD HH:MM")
+	}
+	return t, nil
+}
+
+func formatScopeTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(scopeTimeLayout)
+}
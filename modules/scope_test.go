@@ -0,0 +1,127 @@
+package modules
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScopeCheckIP(t *testing.T) {
+	now := time.Now()
+	scope := engagementScope{
+		Name:    "test",
+		Allowed: []string{"10.20.0.0/16", "192.168.1.10-20"},
+		Denied:  []string{"10.20.5.0/24"},
+	}
+	expired := scope
+	expired.NotAfter = now.Add(-time.Hour)
+	pending := scope
+	pending.NotBefore = now.Add(time.Hour)
+
+	tests := []struct {
+		scope engagementScope
+		ip    string
+		want  string
+	}{
+		{engagementScope{}, "8.8.8.8", ""},
+		{scope, "10.20.1.1", ""},
+		{scope, "192.168.1.15", ""},
+		{scope, "192.168.1.21", "outside"},
+		{scope, "10.21.0.1", "outside"},
+		{scope, "10.20.5.7", "denied range 10.20.5.0/24"},
+		{expired, "10.20.1.1", "expired"},
+		{pending, "10.20.1.1", "not valid until"},
+	}
+	for _, tt := range tests {
+		err := tt.scope.checkIP(net.ParseIP(tt.ip))
+		checkScopeError(t, "checkIP("+tt.ip+")", err, tt.want)
+	}
+}
+
+// The checks resolve localhost, which every resolver answers from the hosts
+// file.
+func TestScopeCheckTarget(t *testing.T) {
+	loopback := []string{"127.0.0.0/8", "::1/128"}
+	tests := []struct {
+		name   string
+		scope  engagementScope
+		target string
+		want   string
+	}{
+		{"no scope", engagementScope{}, "localhost", ""},
+		{"address", engagementScope{Allowed: loopback}, "127.0.0.1", ""},
+		{"resolves inside", engagementScope{Allowed: loopback}, "localhost", ""},
+		{"resolves outside", engagementScope{Allowed: []string{"10.0.0.0/8"}}, "localhost", "outside"},
+		{"name only", engagementScope{Allowed: []string{"localhost"}}, "localhost", ""},
+		{"name matches, address outside", engagementScope{Allowed: []string{"localhost", "10.0.0.0/8"}}, "localhost", "resolves to"},
+		{"name not listed", engagementScope{Allowed: []string{"*.example.com"}}, "localhost", "outside"},
+		{"name denied", engagementScope{Allowed: loopback, Denied: []string{"localhost"}}, "localhost", "denied"},
+		{"address denied", engagementScope{Allowed: loopback, Denied: []string{"127.0.0.0/8", "::1/128"}}, "localhost", "denied range"},
+		{"expired", engagementScope{Allowed: loopback, NotAfter: time.Now().Add(-time.Minute)}, "localhost", "expired"},
+	}
+	for _, tt := range tests {
+		ips, err := tt.scope.checkTarget(tt.target)
+		checkScopeError(t, tt.name, err, tt.want)
+		if err == nil && tt.scope.defined() && len(ips) == 0 {
+			t.Errorf("%s: no approved addresses", tt.name)
+		}
+		for _, ip := range ips {
+			if !ip.IsLoopback() {
+				t.Errorf("%s: approved %s", tt.name, ip)
+			}
+		}
+	}
+}
+
+func TestCheckScopeHostsPins(t *testing.T) {
+	if err := defaultScope.set(engagementScope{Allowed: []string{"127.0.0.0/8", "::1/128", "10.99.0.0/16"}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { defaultScope.set(engagementScope{}) })
+
+	pins, err := checkScopeHosts(moduleScanner, []string{"LocalHost", "10.99.0.10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || !pins["localhost"].Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("pins = %v, want localhost pinned to 127.0.0.1", pins)
+	}
+}
+
+// A pinned name is dialed at its approved address even where it would
+// resolve elsewhere: here web.sim is pinned to the desktop host.
+func TestPinnedNetworkDialsApprovedAddress(t *testing.T) {
+	nw := pinNetwork(newSimNetwork(sampleFixture()), map[string]net.IP{"web.sim": net.ParseIP("10.99.0.30")})
+	statuses, _ := scanPorts(context.Background(), nw, "web.sim", nil)
+	open := map[int]bool{}
+	for _, ps := range statuses {
+		open[ps.Port] = ps.Status == "open"
+	}
+	if !open[3389] || open[22] {
+		t.Errorf("open ports %v, want those of 10.99.0.30", open)
+	}
+	if got := scopeAddress(nw, "WEB.sim"); got != "10.99.0.30" {
+		t.Errorf("scopeAddress = %s, want the pinned address", got)
+	}
+}
+
+func TestScopeCheckHost(t *testing.T) {
+	scope := engagementScope{Allowed: []string{"10.0.0.0/8", "*.example.com"}}
+	checkScopeError(t, "address", scope.checkHost("10.1.1.1"), "")
+	checkScopeError(t, "address outside", scope.checkHost("11.1.1.1"), "outside")
+	checkScopeError(t, "name", scope.checkHost("www.example.com"), "")
+	scope.NotAfter = time.Now().Add(-time.Minute)
+	checkScopeError(t, "name after the window", scope.checkHost("www.example.com"), "expired")
+}
+
+func checkScopeError(t *testing.T, name string, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("%s: %v", name, err)
+	case want != "" && (err == nil || !strings.Contains(err.Error(), want)):
+		t.Errorf("%s: got %v, want an error containing %q", name, err, want)
+	}
+}
@@ -0,0 +1,51 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+func StartServices() {
+	defaultMonitor.start()
+	defaultScheduler.start(context.Background())
+}
+
+// RunDaemon runs scheduled jobs until ctx is done. passphraseFile, when set,
+// holds the vault passphrase for jobs that use credentials.
+func RunDaemon(ctx context.Context, passphraseFile string) error {
+	if err := unlockVaultHeadless(passphraseFile); err != nil {
+		return fmt.Errorf("vault: %w", err)
+	}
+	headless = true
+	scanHistory().subscribe(func(rec scanRecord) {
+		log.Printf("%s", rec)
+	})
+	if !defaultVault.unlocked() {
+		credentialed := 0
+		for _, job := range defaultScheduler.jobs() {
+			if job.CredentialID != "" {
+				credentialed++
+			}
+		}
+		if credentialed > 0 {
+			log.Printf("vault is locked: %d job(s) that use credentials will fail; pass -vault-passphrase-file or set RODENT_VAULT_PASSPHRASE", credentialed)
+		}
+	}
+	defaultMonitor.start()
+	defaultScheduler.start(ctx)
+	log.Printf("rodent daemon running %d scheduled job(s)", len(defaultScheduler.jobs()))
+	for _, cp := range defaultCheckpoints.interrupted() {
+		if cp.Record.JobID != "" && defaultScheduler.resumeRun(cp) {
+			log.Printf("resuming interrupted %s run of %s", cp.Module, cp.Record.Target)
+		}
+	}
+
+	<-ctx.Done()
+	log.Printf("rodent daemon stopping")
+	if !activeRuns.stopAll(shutdownTimeout) {
+		log.Printf("runs still active after %s", shutdownTimeout)
+	}
+	defaultScheduler.wait()
+	return nil
+}
@@ -0,0 +1,101 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+var errShutdown = errors.New("application closed")
+
+// runRegistry holds the runs that are probing, so shutdown can stop them and
+// wait for their partial results to be saved.
+type runRegistry struct {
+	mu       sync.Mutex
+	controls map[*runControl]bool
+	closing  bool
+}
+
+var activeRuns = &runRegistry{controls: map[*runControl]bool{}}
+
+func (r *runRegistry) add(rc *runControl) {
+	r.mu.Lock()
+	r.controls[rc] = true
+	closing := r.closing
+	r.mu.Unlock()
+	if closing {
+		rc.stop(errShutdown)
+	}
+}
+
+func (r *runRegistry) remove(rc *runControl) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.controls, rc)
+}
+
+func (r *runRegistry) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.controls)
+}
+
+// stopAll stops every run, and any run that starts afterwards, then waits for
+// them to finish. It reports false when runs are still active at the timeout.
+func (r *runRegistry) stopAll(timeout time.Duration) bool {
+	r.mu.Lock()
+	r.closing = true
+	controls := make([]*runControl, 0, len(r.controls))
+	for rc := range r.controls {
+		controls = append(controls, rc)
+	}
+	r.mu.Unlock()
+
+	for _, rc := range controls {
+		rc.unpause()
+		rc.stop(errShutdown)
+	}
+	deadline := time.Now().Add(timeout)
+	for r.count() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+// HandleClose asks before closing the window while scans are running, then
+// stops them so their partial results are saved as incomplete.
+func HandleClose(win fyne.Window) {
+	win.SetCloseIntercept(func() {
+		count := activeRuns.count()
+		if count == 0 {
+			win.Close()
+			return
+		}
+		message := fmt.Sprintf("%d scan(s) still running. Stop them and quit?\n"+
+			"Partial results are saved as incomplete and can be resumed from the Scheduler.", count)
+		dialog.ShowConfirm("Active Scans", message, func(ok bool) {
+			if !ok {
+				return
+			}
+			progress := dialog.NewCustomWithoutButtons("Stopping Scans", widget.NewProgressBarInfinite(), win)
+			progress.Show()
+			go func() {
+				if !activeRuns.stopAll(shutdownTimeout) {
+					log.Printf("shutdown: runs still active after %s", shutdownTimeout)
+				}
+				fyne.CurrentApp().Quit()
+			}()
+		}, win)
+	})
+}
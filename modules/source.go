@@ -0,0 +1,187 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+const sourceAny = "Any source"
+
+// sourceBinding pins the local end of probe connections to an address and,
+// optionally, a range of source ports. A nil binding lets the OS choose.
+type sourceBinding struct {
+	input   string
+	spec    string
+	ip      net.IP
+	portMin int
+	portMax int
+	next    atomic.Uint32
+}
+
+func (s *sourceBinding) source() string {
+	if s == nil {
+		return ""
+	}
+	return s.input
+}
+
+// parseSource accepts an interface name or local address, optionally followed
+// by a source port or port range: eth0, 192.168.1.5:40000-40100,
+// [fe80::1%eth0]:40000. Interfaces bind to their first IPv4 address, or their
+// first address when they have no IPv4 address.
+func parseSource(input string) (*sourceBinding, error) {
+	input = strings.TrimSpace(input)
+	if input == "" || input == sourceAny {
+		return nil, nil
+	}
+	name, ports := input, ""
+	if strings.HasPrefix(input, "[") {
+		end := strings.Index(input, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid source %q", input)
+		}
+		name = input[1:end]
+		ports = strings.TrimPrefix(input[end+1:], ":")
+	} else if strings.Count(input, ":") == 1 {
+		name, ports, _ = strings.Cut(input, ":")
+	}
+
+	s := &sourceBinding{input: input, spec: name}
+	if ports != "" {
+		lo, hi, isRange := strings.Cut(ports, "-")
+		var err error
+		if s.portMin, err = strconv.Atoi(lo); err != nil {
+			return nil, fmt.Errorf("invalid source port %q", lo)
+		}
+		s.portMax = s.portMin
+		if isRange {
+			if s.portMax, err = strconv.Atoi(hi); err != nil {
+				return nil, fmt.Errorf("invalid source port %q", hi)
+			}
+		}
+		if s.portMin < 1 || s.portMax > 65535 || s.portMin > s.portMax {
+			return nil, fmt.Errorf("invalid source port range %q", ports)
+		}
+	}
+
+	ip, err := localAddress(name)
+	if err != nil {
+		return nil, err
+	}
+	s.ip = ip
+	return s, nil
+}
+
+func localAddress(name string) (net.IP, error) {
+	host, _, _ := strings.Cut(name, "%")
+	if ip := net.ParseIP(host); ip != nil {
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+				return ip, nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not assigned to a local interface", ip)
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown interface or address %q", name)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var chosen net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			return ipnet.IP, nil
+		}
+		if chosen == nil {
+			chosen = ipnet.IP
+		}
+	}
+	if chosen == nil {
+		return nil, fmt.Errorf("interface %s has no address", name)
+	}
+	return chosen, nil
+}
+
+// dial connects from the bound address. With a port range, ports are handed
+// out round-robin and a port still in use is skipped for the next one.
+func (s *sourceBinding) dial(address string, timeout time.Duration) (net.Conn, error) {
+	if s == nil {
+		return net.DialTimeout("tcp", address, timeout)
+	}
+	local := &net.TCPAddr{IP: s.ip}
+	if s.ip.IsLinkLocalUnicast() {
+		if host, zone, ok := strings.Cut(s.spec, "%"); ok && net.ParseIP(host) != nil {
+			local.Zone = zone
+		} else if net.ParseIP(s.spec) == nil {
+			local.Zone = s.spec
+		}
+	}
+	if s.portMin == 0 {
+		dialer := net.Dialer{Timeout: timeout, LocalAddr: local}
+		return dialer.Dial("tcp", address)
+	}
+
+	span := s.portMax - s.portMin + 1
+	deadline := time.Now().Add(timeout)
+	var err error
+	for i := 0; i < span; i++ {
+		local.Port = s.portMin + int(s.next.Add(1)-1)%span
+		dialer := net.Dialer{Deadline: deadline, LocalAddr: local}
+		var conn net.Conn
+		conn, err = dialer.Dial("tcp", address)
+		if err == nil || !errors.Is(err, syscall.EADDRINUSE) {
+			return conn, err
+		}
+	}
+	return nil, fmt.Errorf("no free source port in %d-%d: %w", s.portMin, s.portMax, err)
+}
+
+func sourceOptions() []string {
+	options := []string{sourceAny}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return options
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		if len(addrs) == 0 {
+			continue
+		}
+		options = append(options, iface.Name)
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				options = append(options, ipnet.IP.String())
+			}
+		}
+	}
+	return options
+}
+
+func newSourceEntry() *widget.SelectEntry {
+	entry := widget.NewSelectEntry(sourceOptions())
+	entry.SetPlaceHolder("Source (interface or address[:ports])")
+	return entry
+}
@@ -0,0 +1,76 @@
+//go:build linux
+
+package modules
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+const stackWait = 100 * time.Millisecond
+
+// dialStack dials address and captures the SYN-ACK that answered it on a raw
+// socket, which needs CAP_NET_RAW. The stack is nil when the reply could not
+// be seen: without the capability, through a proxy chain, over IPv6, or when
+// the open socket limit leaves no room for the raw socket. The dial itself
+// waits for a slot, so the raw socket does not, or the two could deadlock.
+func dialStack(src *sourceBinding, address string, timeout time.Duration) (net.Conn, *tcpStack, error) {
+	var sniffer *ipv4.PacketConn
+	host, portText, err := net.SplitHostPort(address)
+	ip := net.ParseIP(host)
+	port, _ := strconv.Atoi(portText)
+	if err == nil && ip.To4() != nil && !defaultProxy.current().active() {
+		if release, ok := defaultGuard.tryAcquireSocket(); ok {
+			defer release()
+			sniffer = listenSYNACK(src)
+		}
+	}
+	conn, err := dialTCP(src, address, timeout)
+	if sniffer == nil {
+		return conn, nil, err
+	}
+	defer sniffer.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	local, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return conn, nil, nil
+	}
+
+	sniffer.SetReadDeadline(time.Now().Add(stackWait))
+	buf := make([]byte, 1500)
+	for {
+		n, cm, peer, err := sniffer.ReadFrom(buf)
+		if err != nil {
+			return conn, nil, nil
+		}
+		if cm == nil || !peerIP(peer).Equal(ip) {
+			continue
+		}
+		if stack := parseSYNACK(buf[:n], port, local.Port); stack != nil {
+			stack.TTL = cm.TTL
+			return conn, stack, nil
+		}
+	}
+}
+
+func listenSYNACK(src *sourceBinding) *ipv4.PacketConn {
+	address := "0.0.0.0"
+	if src != nil {
+		address = src.ip.String()
+	}
+	c, err := net.ListenPacket("ip4:tcp", address)
+	if err != nil {
+		return nil
+	}
+	p := ipv4.NewPacketConn(c)
+	if err := p.SetControlMessage(ipv4.FlagTTL, true); err != nil {
+		p.Close()
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package modules
+
+import (
+	"net"
+	"time"
+)
+
+// dialStack only dials: raw sockets outside Linux are not handed TCP
+// segments, so the SYN-ACK cannot be captured.
+func dialStack(src *sourceBinding, address string, timeout time.Duration) (net.Conn, *tcpStack, error) {
+	conn, err := dialTCP(src, address, timeout)
+	return conn, nil, err
+}
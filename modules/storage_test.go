@@ -0,0 +1,51 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestMain keeps the stores out of the real configuration directory.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "rodent-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	os.Setenv("HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestSaveJSONConcurrent(t *testing.T) {
+	const name = "concurrent_test.json"
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := saveJSON(name, map[string]int{"writer": i}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var got map[string]int
+	if err := loadJSON(name, &got); err != nil {
+		t.Fatalf("loadJSON: %v", err)
+	}
+	if _, ok := got["writer"]; !ok {
+		t.Fatalf("got %v, want one writer's snapshot", got)
+	}
+	path, _ := dataPath(name)
+	leftovers, _ := filepath.Glob(path + ".*.tmp")
+	if len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
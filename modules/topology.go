@@ -0,0 +1,395 @@
+package modules
+
+import (
+	"fmt"
+	"image/color"
+	"net"
+	"os"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	topoColumn = 180
+	topoRow    = 34
+	topoRadius = 7
+	topoMargin = 40
+	topoMinZ   = 0.2
+	topoMaxZ   = 4
+)
+
+type topoKind int
+
+const (
+	topoLocal topoKind = iota
+	topoGateway
+	topoRouter
+	topoSilent
+	topoSubnet
+	topoHost
+)
+
+// topoNode is a node of the topology tree: this machine at the root, then the
+// routers traces passed, the subnets behind them and the devices.
+type topoNode struct {
+	kind     topoKind
+	label    string
+	hop      int
+	devices  int
+	device   *networkDevice
+	parent   *topoNode
+	children []*topoNode
+	pos      fyne.Position
+}
+
+// buildTopology lays the devices out by their routes. Routers are merged by
+// address, so the first route a router is seen on decides where it hangs.
+// Devices that were not traced hang off this machine. Devices are grouped
+// under the most specific of prefixes that holds them; the rest hang off
+// their last hop directly.
+func buildTopology(local string, devices []networkDevice, prefixes []*net.IPNet) (*topoNode, []*topoNode) {
+	root := &topoNode{kind: topoLocal, label: local}
+	nodes := []*topoNode{root}
+	index := map[string]*topoNode{}
+	child := func(parent *topoNode, key string, n *topoNode) *topoNode {
+		if existing, ok := index[key]; ok {
+			return existing
+		}
+		n.parent = parent
+		parent.children = append(parent.children, n)
+		index[key] = n
+		nodes = append(nodes, n)
+		return n
+	}
+
+	for i := range devices {
+		dev := &devices[i]
+		if dev.Status != "" {
+			continue
+		}
+		parent, key := root, ""
+		for _, hop := range dev.Route {
+			if hop.IP == dev.IP {
+				break
+			}
+			n := &topoNode{kind: topoRouter, label: hop.IP, hop: hop.TTL}
+			switch {
+			case hop.IP == "":
+				key += fmt.Sprintf(">*%d", hop.TTL)
+				n.kind, n.label = topoSilent, "*"
+			case hop.TTL == 1:
+				key = hop.IP
+				n.kind = topoGateway
+			default:
+				key = hop.IP
+			}
+			parent = child(parent, key, n)
+			parent.devices++
+		}
+		if prefix := containingPrefix(net.ParseIP(dev.IP), prefixes); prefix != nil {
+			label := prefix.String()
+			parent = child(parent, key+"|"+label, &topoNode{kind: topoSubnet, label: label})
+			parent.devices++
+		}
+		name := dev.IP
+		if dev.Hostname != "" {
+			name += " " + dev.Hostname
+		}
+		child(parent, "#"+dev.IP, &topoNode{kind: topoHost, label: name, device: dev})
+	}
+
+	next := float32(0)
+	root.layout(0, &next)
+	return root, nodes
+}
+
+// containingPrefix returns the longest of prefixes that holds ip. Host
+// routes are not subnets and are left out.
+func containingPrefix(ip net.IP, prefixes []*net.IPNet) *net.IPNet {
+	if ip == nil {
+		return nil
+	}
+	var best *net.IPNet
+	bestOnes := -1
+	for _, prefix := range prefixes {
+		ones, bits := prefix.Mask.Size()
+		if ones == bits || ones <= bestOnes || !prefix.Contains(ip) {
+			continue
+		}
+		best, bestOnes = prefix, ones
+	}
+	return best
+}
+
+// topologyPrefixes lists the networks the topology groups devices by: the
+// mapped subnet and the networks of this machine's interfaces and routes.
+func topologyPrefixes(mapped *net.IPNet) []*net.IPNet {
+	var prefixes []*net.IPNet
+	if mapped != nil {
+		prefixes = append(prefixes, mapped)
+	}
+	local := detectNetwork()
+	for _, iface := range local.Interfaces {
+		if iface.Loopback {
+			continue
+		}
+		for _, addr := range iface.Addrs {
+			prefixes = append(prefixes, &net.IPNet{IP: addr.IP.Mask(addr.Mask), Mask: addr.Mask})
+		}
+	}
+	for _, r := range local.Routes {
+		if !r.isDefault() {
+			prefixes = append(prefixes, r.Destination)
+		}
+	}
+	return prefixes
+}
+
+// layout places leaves one row apart and centers parents on their children.
+func (n *topoNode) layout(depth int, next *float32) {
+	n.pos.X = float32(depth * topoColumn)
+	if len(n.children) == 0 {
+		n.pos.Y = *next
+		*next += topoRow
+		return
+	}
+	for _, c := range n.children {
+		c.layout(depth+1, next)
+	}
+	n.pos.Y = (n.children[0].pos.Y + n.children[len(n.children)-1].pos.Y) / 2
+}
+
+func (n *topoNode) color() color.Color {
+	switch n.kind {
+	case topoLocal:
+		return theme.ForegroundColor()
+	case topoGateway:
+		return theme.WarningColor()
+	case topoRouter, topoSilent:
+		return theme.DisabledColor()
+	case topoSubnet:
+		return theme.PrimaryColor()
+	default:
+		return theme.SuccessColor()
+	}
+}
+
+func (n *topoNode) title() string {
+	switch n.kind {
+	case topoLocal:
+		return "This machine"
+	case topoGateway:
+		return "Gateway " + n.label
+	case topoRouter:
+		return "Router " + n.label
+	case topoSilent:
+		return fmt.Sprintf("Hop %d", n.hop)
+	case topoSubnet:
+		return "Subnet " + n.label
+	default:
+		return n.device.IP
+	}
+}
+
+func (n *topoNode) describe() string {
+	switch n.kind {
+	case topoLocal:
+		return fmt.Sprintf("%s\nTraces start here.", n.label)
+	case topoGateway, topoRouter:
+		return fmt.Sprintf("Hop %d on the route to %d device(s).", n.hop, n.devices)
+	case topoSilent:
+		return fmt.Sprintf("Hop %d did not answer on the route to %d device(s).", n.hop, n.devices)
+	case topoSubnet:
+		return fmt.Sprintf("%d device(s).", n.devices)
+	default:
+		return n.device.details()
+	}
+}
+
+// topologyView draws the topology tree. Dragging pans, scrolling zooms
+// around the pointer, and tapping a node calls onTapped.
+type topologyView struct {
+	widget.BaseWidget
+	nodes    []*topoNode
+	zoom     float32
+	offset   fyne.Position
+	onTapped func(*topoNode)
+}
+
+func newTopologyView(nodes []*topoNode, onTapped func(*topoNode)) *topologyView {
+	v := &topologyView{nodes: nodes, onTapped: onTapped}
+	v.reset()
+	v.ExtendBaseWidget(v)
+	return v
+}
+
+func (v *topologyView) reset() {
+	v.zoom = 1
+	v.offset = fyne.NewPos(topoMargin, topoMargin)
+	v.Refresh()
+}
+
+func (v *topologyView) toScreen(p fyne.Position) fyne.Position {
+	return fyne.NewPos(p.X*v.zoom+v.offset.X, p.Y*v.zoom+v.offset.Y)
+}
+
+func (v *topologyView) zoomAt(factor float32, at fyne.Position) {
+	zoom := v.zoom * factor
+	if zoom < topoMinZ {
+		zoom = topoMinZ
+	}
+	if zoom > topoMaxZ {
+		zoom = topoMaxZ
+	}
+	factor = zoom / v.zoom
+	v.offset = fyne.NewPos(at.X-(at.X-v.offset.X)*factor, at.Y-(at.Y-v.offset.Y)*factor)
+	v.zoom = zoom
+	v.Refresh()
+}
+
+func (v *topologyView) zoomCenter(factor float32) {
+	size := v.Size()
+	v.zoomAt(factor, fyne.NewPos(size.Width/2, size.Height/2))
+}
+
+func (v *topologyView) Scrolled(ev *fyne.ScrollEvent) {
+	factor := float32(1.1)
+	if ev.Scrolled.DY < 0 {
+		factor = 1 / factor
+	}
+	v.zoomAt(factor, ev.Position)
+}
+
+func (v *topologyView) Dragged(ev *fyne.DragEvent) {
+	v.offset = v.offset.Add(ev.Dragged)
+	v.Refresh()
+}
+
+func (v *topologyView) DragEnd() {}
+
+func (v *topologyView) Tapped(ev *fyne.PointEvent) {
+	if n := v.nodeAt(ev.Position); n != nil && v.onTapped != nil {
+		v.onTapped(n)
+	}
+}
+
+// nodeAt returns the node nearest to p, if p is on it or close enough to
+// tap at small zoom levels.
+func (v *topologyView) nodeAt(p fyne.Position) *topoNode {
+	reach := topoRadius * v.zoom
+	if reach < 10 {
+		reach = 10
+	}
+	var found *topoNode
+	best := reach * reach
+	for _, n := range v.nodes {
+		c := v.toScreen(n.pos)
+		dx, dy := c.X-p.X, c.Y-p.Y
+		if d := dx*dx + dy*dy; d <= best {
+			found, best = n, d
+		}
+	}
+	return found
+}
+
+func (v *topologyView) CreateRenderer() fyne.WidgetRenderer {
+	r := &topologyRenderer{view: v}
+	for _, n := range v.nodes {
+		if n.parent != nil {
+			line := canvas.NewLine(theme.DisabledColor())
+			line.StrokeWidth = 1.5
+			r.edges = append(r.edges, line)
+			r.objects = append(r.objects, line)
+		}
+	}
+	for _, n := range v.nodes {
+		dot := canvas.NewCircle(n.color())
+		label := canvas.NewText(n.label, theme.ForegroundColor())
+		r.dots = append(r.dots, dot)
+		r.labels = append(r.labels, label)
+		r.objects = append(r.objects, dot, label)
+	}
+	return r
+}
+
+type topologyRenderer struct {
+	view    *topologyView
+	edges   []*canvas.Line
+	dots    []*canvas.Circle
+	labels  []*canvas.Text
+	objects []fyne.CanvasObject
+}
+
+func (r *topologyRenderer) Layout(size fyne.Size) {
+	v := r.view
+	radius := topoRadius * v.zoom
+	textSize := theme.TextSize() * 0.85 * v.zoom
+	edge := 0
+	for i, n := range v.nodes {
+		c := v.toScreen(n.pos)
+		if n.parent != nil {
+			line := r.edges[edge]
+			line.Position1, line.Position2 = v.toScreen(n.parent.pos), c
+			edge++
+		}
+		dot, label := r.dots[i], r.labels[i]
+		dot.FillColor = n.color()
+		dot.Move(fyne.NewPos(c.X-radius, c.Y-radius))
+		dot.Resize(fyne.NewSize(2*radius, 2*radius))
+		label.Color = theme.ForegroundColor()
+		label.TextSize = textSize
+		label.Move(fyne.NewPos(c.X+radius+4, c.Y-label.MinSize().Height/2))
+		label.Resize(label.MinSize())
+		if textSize < 5 {
+			label.Hide()
+		} else {
+			label.Show()
+		}
+	}
+}
+
+func (r *topologyRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(300, 200)
+}
+
+func (r *topologyRenderer) Refresh() {
+	r.Layout(r.view.Size())
+	for _, o := range r.objects {
+		o.Refresh()
+	}
+}
+
+func (r *topologyRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *topologyRenderer) Destroy() {}
+
+// showTopology opens the topology of the devices in a dialog. Tapping a node
+// shows what is known about it.
+func showTopology(devices []networkDevice, mapped *net.IPNet, win fyne.Window) {
+	local := "This machine"
+	if name, err := os.Hostname(); err == nil {
+		local = name
+	}
+	_, nodes := buildTopology(local, devices, topologyPrefixes(mapped))
+	view := newTopologyView(nodes, func(n *topoNode) {
+		dialog.ShowInformation(n.title(), n.describe(), win)
+	})
+	toolbar := container.NewHBox(
+		widget.NewButton("Zoom In", func() { view.zoomCenter(1.25) }),
+		widget.NewButton("Zoom Out", func() { view.zoomCenter(0.8) }),
+		widget.NewButton("Reset", view.reset),
+		widget.NewLabel("Drag to pan, scroll to zoom, click a node for details."),
+		layout.NewSpacer(),
+	)
+	d := dialog.NewCustom("Network Topology", "Close", container.NewBorder(toolbar, nil, nil, nil, view), win)
+	d.Resize(fyne.NewSize(900, 600))
+	d.Show()
+}
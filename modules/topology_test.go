@@ -0,0 +1,49 @@
+package modules
+
+import (
+	"net"
+	"testing"
+)
+
+func TestBuildTopologyGroupsByPrefix(t *testing.T) {
+	var prefixes []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/16", "10.0.5.0/24", "10.0.9.7/32"} {
+		_, prefix, _ := net.ParseCIDR(cidr)
+		prefixes = append(prefixes, prefix)
+	}
+	devices := []networkDevice{
+		{IP: "10.0.1.10"},
+		{IP: "10.0.200.3"},
+		{IP: "10.0.5.20"},
+		{IP: "10.0.9.7"},
+		{IP: "192.0.2.1"},
+	}
+
+	root, _ := buildTopology("local", devices, prefixes)
+	got := map[string]string{}
+	for _, n := range root.children {
+		switch n.kind {
+		case topoSubnet:
+			for _, host := range n.children {
+				got[host.device.IP] = n.label
+			}
+		case topoHost:
+			got[n.device.IP] = ""
+		}
+	}
+	want := map[string]string{
+		"10.0.1.10":  "10.0.0.0/16",
+		"10.0.200.3": "10.0.0.0/16",
+		"10.0.5.20":  "10.0.5.0/24",
+		"10.0.9.7":   "10.0.0.0/16",
+		"192.0.2.1":  "",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for ip, label := range want {
+		if got[ip] != label {
+			t.Errorf("%s grouped under %q, want %q", ip, got[ip], label)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package modules
+
+import "syscall"
+
+// Without a portable way to set the TTL of a connecting socket, TCP traces
+// are refused.
+const tcpTraceSupported = false
+
+func ttlControl(ttl int) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package modules
+
+import "syscall"
+
+const tcpTraceSupported = true
+
+// ttlControl sets the TTL of a socket before it connects.
+func ttlControl(ttl int) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		if cerr := c.Control(func(fd uintptr) {
+			err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+		}); cerr != nil {
+			return cerr
+		}
+		return err
+	}
+}
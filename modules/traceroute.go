@@ -0,0 +1,328 @@
+package modules
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	traceICMP = "icmp"
+	traceUDP  = "udp"
+	traceTCP  = "tcp"
+)
+
+const (
+	traceMaxHops  = 30
+	traceSilent   = 5
+	traceTimeout  = time.Second
+	traceWorkers  = 8
+	tracePortBase = 33434
+	tracePort     = 443
+)
+
+var errTraceProxied = errors.New("traceroute cannot run through the proxy chain")
+
+var traceLabels = map[string]string{
+	traceICMP: "ICMP",
+	traceUDP:  "UDP",
+	traceTCP:  "TCP SYN",
+}
+
+func traceOptions() []string {
+	return []string{traceLabels[traceICMP], traceLabels[traceUDP], traceLabels[traceTCP]}
+}
+
+// parseTrace accepts a traceroute method or its label. The empty method is
+// ICMP.
+func parseTrace(input string) (string, error) {
+	if input == "" {
+		return traceICMP, nil
+	}
+	for method, label := range traceLabels {
+		if input == method || input == label {
+			return method, nil
+		}
+	}
+	return "", fmt.Errorf("unknown traceroute method %q", input)
+}
+
+// traceHop is the router that answered at one TTL, or a silent hop when IP
+// is empty. The last hop is the device itself when the trace reached it.
+type traceHop struct {
+	TTL   int     `json:"ttl"`
+	IP    string  `json:"ip,omitempty"`
+	RTTMS float64 `json:"rtt_ms,omitempty"`
+}
+
+func routeText(route []traceHop) string {
+	parts := make([]string, 0, len(route))
+	for _, hop := range route {
+		if hop.IP == "" {
+			parts = append(parts, "*")
+		} else {
+			parts = append(parts, hop.IP)
+		}
+	}
+	return strings.Join(parts, " > ")
+}
+
+// traceHosts traces up to traceWorkers routes at a time. It reports whether
+// the run was stopped before every host was traced.
+func traceHosts(ctx context.Context, nw probeNetwork, ips []string, method string) ([][]traceHop, []error, bool) {
+	rc := runControlFrom(ctx)
+	routes := make([][]traceHop, len(ips))
+	errs := make([]error, len(ips))
+	sem := make(chan struct{}, traceWorkers)
+	var wg sync.WaitGroup
+	canceled := false
+	for i, ip := range ips {
+		if !rc.wait(ctx) {
+			canceled = true
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, ip string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			routes[i], errs[i] = nw.trace(ctx, net.ParseIP(ip), method)
+		}(i, ip)
+	}
+	wg.Wait()
+	return routes, errs, canceled || ctx.Err() != nil
+}
+
+// tracer sends probes with increasing TTLs and reads the ICMP errors the
+// routers on the way send back on a raw socket, which needs CAP_NET_RAW.
+type tracer struct {
+	src  *sourceBinding
+	ip   net.IP
+	conn *icmp.PacketConn
+	id   int
+	udp  *net.UDPConn
+}
+
+// traceRoute follows the route to ip until the device answers, a router
+// reports it unreachable, or traceSilent hops in a row stay silent.
+func traceRoute(ctx context.Context, src *sourceBinding, ip net.IP, method string) ([]traceHop, error) {
+	if defaultProxy.current().active() {
+		return nil, errTraceProxied
+	}
+	if ip.To4() == nil {
+		return nil, errors.New("traceroute supports IPv4 only")
+	}
+	if method == traceTCP && !tcpTraceSupported {
+		return nil, errors.New("TCP traceroute is not supported on this platform")
+	}
+	release := defaultGuard.acquireSocket()
+	defer release()
+
+	address := "0.0.0.0"
+	if src != nil {
+		address = src.ip.String()
+	}
+	conn, err := icmp.ListenPacket("ip4:icmp", address)
+	if err != nil {
+		return nil, fmt.Errorf("traceroute needs a raw ICMP socket: %w", err)
+	}
+	defer conn.Close()
+	t := &tracer{src: src, ip: ip.To4(), conn: conn, id: rand.Intn(1 << 16)}
+	defer func() {
+		if t.udp != nil {
+			t.udp.Close()
+		}
+	}()
+
+	var hops []traceHop
+	silent := 0
+	for ttl := 1; ttl <= traceMaxHops && ctx.Err() == nil; ttl++ {
+		start := time.Now()
+		from, stop, err := t.probe(ctx, method, ttl)
+		if err != nil {
+			return hops, err
+		}
+		hop := traceHop{TTL: ttl}
+		if from != nil {
+			hop.IP = from.String()
+			hop.RTTMS = float64(time.Since(start).Microseconds()) / 1000
+			silent = 0
+		} else {
+			silent++
+		}
+		hops = append(hops, hop)
+		if stop || silent == traceSilent {
+			break
+		}
+	}
+	if silent > 1 {
+		hops = hops[:len(hops)-silent+1]
+	}
+	return hops, nil
+}
+
+func (t *tracer) probe(ctx context.Context, method string, ttl int) (net.IP, bool, error) {
+	deadline := time.Now().Add(traceTimeout)
+	switch method {
+	case traceUDP:
+		return t.probeUDP(ttl, deadline)
+	case traceTCP:
+		return t.probeTCP(ctx, ttl, deadline)
+	default:
+		return t.probeICMP(ttl, deadline)
+	}
+}
+
+func (t *tracer) probeICMP(ttl int, deadline time.Time) (net.IP, bool, error) {
+	msg, err := (&icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: t.id, Seq: ttl, Data: []byte("rodent")}}).Marshal(nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := t.conn.IPv4PacketConn().SetTTL(ttl); err != nil {
+		return nil, false, err
+	}
+	if _, err := t.conn.WriteTo(msg, &net.IPAddr{IP: t.ip}); err != nil {
+		return nil, false, err
+	}
+	from, stop := t.await(deadline, nil, func(m *icmp.Message, peer net.IP) (bool, bool) {
+		if echo, ok := m.Body.(*icmp.Echo); ok && m.Type == ipv4.ICMPTypeEchoReply {
+			return echo.ID == t.id && echo.Seq == ttl && peer.Equal(t.ip), true
+		}
+		payload := t.quoted(m, 1)
+		if len(payload) < 8 {
+			return false, false
+		}
+		ok := int(binary.BigEndian.Uint16(payload[4:])) == t.id && int(binary.BigEndian.Uint16(payload[6:])) == ttl
+		return ok, m.Type == ipv4.ICMPTypeDestinationUnreachable
+	})
+	return from, stop, nil
+}
+
+// probeUDP sends to an unlikely port, so the device answers with port
+// unreachable.
+func (t *tracer) probeUDP(ttl int, deadline time.Time) (net.IP, bool, error) {
+	if t.udp == nil {
+		var laddr *net.UDPAddr
+		if t.src != nil {
+			laddr = &net.UDPAddr{IP: t.src.ip}
+		}
+		conn, err := net.ListenUDP("udp4", laddr)
+		if err != nil {
+			return nil, false, err
+		}
+		t.udp = conn
+	}
+	port := tracePortBase + ttl
+	if err := ipv4.NewPacketConn(t.udp).SetTTL(ttl); err != nil {
+		return nil, false, err
+	}
+	if _, err := t.udp.WriteToUDP([]byte("rodent"), &net.UDPAddr{IP: t.ip, Port: port}); err != nil {
+		return nil, false, err
+	}
+	local := t.udp.LocalAddr().(*net.UDPAddr).Port
+	from, stop := t.await(deadline, nil, func(m *icmp.Message, peer net.IP) (bool, bool) {
+		payload := t.quoted(m, 17)
+		if len(payload) < 4 {
+			return false, false
+		}
+		ok := int(binary.BigEndian.Uint16(payload)) == local && int(binary.BigEndian.Uint16(payload[2:])) == port
+		return ok, m.Type == ipv4.ICMPTypeDestinationUnreachable
+	})
+	return from, stop, nil
+}
+
+// probeTCP connects with the TTL lowered. The device answers with a SYN-ACK
+// or a reset; routers with ICMP errors quoting the SYN. The local port is not
+// known before the dial returns, so errors are matched on the destination.
+func (t *tracer) probeTCP(ctx context.Context, ttl int, deadline time.Time) (net.IP, bool, error) {
+	d := net.Dialer{Control: ttlControl(ttl)}
+	if t.src != nil {
+		d.LocalAddr = &net.TCPAddr{IP: t.src.ip}
+	}
+	dctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	done := make(chan bool, 1)
+	go func() {
+		conn, err := d.DialContext(dctx, "tcp4", net.JoinHostPort(t.ip.String(), strconv.Itoa(tracePort)))
+		if err == nil {
+			conn.Close()
+		}
+		done <- err == nil || errors.Is(err, syscall.ECONNREFUSED)
+	}()
+	from, stop := t.await(deadline, done, func(m *icmp.Message, peer net.IP) (bool, bool) {
+		payload := t.quoted(m, 6)
+		if len(payload) < 4 {
+			return false, false
+		}
+		return int(binary.BigEndian.Uint16(payload[2:])) == tracePort, m.Type == ipv4.ICMPTypeDestinationUnreachable
+	})
+	return from, stop, nil
+}
+
+// await reads ICMP until match accepts a message, done reports the device
+// reached, or the deadline passes. match reports whether the message
+// answers the probe and whether the trace ends there.
+func (t *tracer) await(deadline time.Time, done <-chan bool, match func(m *icmp.Message, peer net.IP) (bool, bool)) (net.IP, bool) {
+	buf := make([]byte, 1500)
+	for time.Now().Before(deadline) {
+		select {
+		case reached := <-done:
+			if reached {
+				return t.ip, true
+			}
+			done = nil
+		default:
+		}
+		wait := time.Now().Add(50 * time.Millisecond)
+		if wait.After(deadline) {
+			wait = deadline
+		}
+		t.conn.SetReadDeadline(wait)
+		n, peer, err := t.conn.ReadFrom(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return nil, false
+		}
+		m, err := icmp.ParseMessage(1, buf[:n])
+		if err != nil {
+			continue
+		}
+		if ok, stop := match(m, peerIP(peer)); ok {
+			return peerIP(peer), stop
+		}
+	}
+	return nil, false
+}
+
+// quoted returns the transport header of the probe an ICMP error quotes,
+// when the probe was of the given protocol and went to the traced device.
+func (t *tracer) quoted(m *icmp.Message, protocol int) []byte {
+	var data []byte
+	switch body := m.Body.(type) {
+	case *icmp.TimeExceeded:
+		data = body.Data
+	case *icmp.DstUnreach:
+		data = body.Data
+	default:
+		return nil
+	}
+	h, err := ipv4.ParseHeader(data)
+	if err != nil || h.Protocol != protocol || !h.Dst.Equal(t.ip) || len(data) < h.Len {
+		return nil
+	}
+	return data[h.Len:]
+}
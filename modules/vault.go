@@ -0,0 +1,626 @@
+package modules
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"golang.org/x/crypto/argon2"
+)
+
+const vaultFile = "vault.json"
+
+const (
+	vaultVersion   = 1
+	vaultKDF       = "argon2id"
+	vaultKeyLength = 32
+	vaultSaltSize  = 16
+	vaultAAD       = "rodent-vault-v1"
+	minPassphrase  = 10
+)
+
+var (
+	errVaultLocked     = errors.New("credential vault is locked")
+	errVaultPassphrase = errors.New("incorrect vault passphrase")
+)
+
+func credentialKinds() []string {
+	return []string{"SSH password", "SSH private key", "SNMPv3", "Database login", "API token", "Proxy login"}
+}
+
+type secretString string
+
+func (s secretString) String() string {
+	if s == "" {
+		return ""
+	}
+	return "********"
+}
+
+func (s secretString) GoString() string {
+	return s.String()
+}
+
+// credential is the in-memory view of a vault entry. The secret is
+// unexported so it never ends up in history, reports or logs.
+type credential struct {
+	ID       string
+	Name     string
+	Kind     string
+	Username string
+	Hosts    []string
+	Tags     []string
+	Created  time.Time
+	secret   secretString
+}
+
+func (c credential) String() string {
+	return fmt.Sprintf("%s (%s, user %s, secret redacted)", c.Name, c.Kind, c.Username)
+}
+
+func (c credential) label() string {
+	return fmt.Sprintf("%s [%s]", c.Name, c.ID)
+}
+
+func (c credential) appliesTo(target string) bool {
+	if len(c.Hosts) == 0 && len(c.Tags) == 0 {
+		return true
+	}
+	for _, host := range c.Hosts {
+		if targetMatches(host, target) {
+			return true
+		}
+	}
+	tags := defaultBaselines.snapshot().Tags[strings.ToLower(target)]
+	for _, wanted := range c.Tags {
+		for _, tag := range tags {
+			if strings.EqualFold(tag, wanted) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type storedCredential struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Kind     string    `json:"kind"`
+	Username string    `json:"username"`
+	Secret   string    `json:"secret"`
+	Hosts    []string  `json:"hosts,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+type vaultEnvelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	Time       uint32 `json:"time"`
+	Memory     uint32 `json:"memory_kib"`
+	Threads    uint8  `json:"threads"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type credentialVault struct {
+	mu          sync.Mutex
+	key         []byte
+	envelope    vaultEnvelope
+	credentials []credential
+	listeners   []func()
+}
+
+var defaultVault = &credentialVault{}
+
+func (v *credentialVault) exists() bool {
+	path, err := dataPath(vaultFile)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+func (v *credentialVault) unlocked() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.key != nil
+}
+
+func (v *credentialVault) create(passphrase string) error {
+	if len(passphrase) < minPassphrase {
+		return fmt.Errorf("passphrase must be at least %d characters", minPassphrase)
+	}
+	if v.exists() {
+		return fmt.Errorf("a vault already exists")
+	}
+
+	salt := make([]byte, vaultSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	envelope := vaultEnvelope{
+		Version: vaultVersion,
+		KDF:     vaultKDF,
+		Salt:    salt,
+		Time:    3,
+		Memory:  64 * 1024,
+		Threads: 4,
+	}
+
+	v.mu.Lock()
+	v.envelope = envelope
+	v.key = deriveVaultKey(passphrase, envelope)
+	v.credentials = nil
+	err := v.saveLocked()
+	v.mu.Unlock()
+
+	v.changed()
+	return err
+}
+
+func (v *credentialVault) unlock(passphrase string) error {
+	var envelope vaultEnvelope
+	if err := loadJSON(vaultFile, &envelope); err != nil {
+		return err
+	}
+	if envelope.Version != vaultVersion || envelope.KDF != vaultKDF {
+		return fmt.Errorf("unsupported vault format")
+	}
+
+	key := deriveVaultKey(passphrase, envelope)
+	gcm, err := newVaultCipher(key)
+	if err != nil {
+		return err
+	}
+	plaintext, err := gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, []byte(vaultAAD))
+	if err != nil {
+		return errVaultPassphrase
+	}
+
+	var stored []storedCredential
+	if err := json.Unmarshal(plaintext, &stored); err != nil {
+		return fmt.Errorf("vault contents are corrupt")
+	}
+	creds := make([]credential, 0, len(stored))
+	for _, sc := range stored {
+		creds = append(creds, credential{
+			ID:       sc.ID,
+			Name:     sc.Name,
+			Kind:     sc.Kind,
+			Username: sc.Username,
+			Hosts:    sc.Hosts,
+			Tags:     sc.Tags,
+			Created:  sc.Created,
+			secret:   secretString(sc.Secret),
+		})
+	}
+
+	v.mu.Lock()
+	v.envelope = envelope
+	v.key = key
+	v.credentials = creds
+	v.mu.Unlock()
+
+	v.changed()
+	return nil
+}
+
+func (v *credentialVault) lock() {
+	v.mu.Lock()
+	for i := range v.key {
+		v.key[i] = 0
+	}
+	v.key = nil
+	v.credentials = nil
+	v.mu.Unlock()
+	v.changed()
+}
+
+func (v *credentialVault) list() ([]credential, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return nil, errVaultLocked
+	}
+	out := make([]credential, len(v.credentials))
+	for i, c := range v.credentials {
+		c.secret = ""
+		out[i] = c
+	}
+	return out, nil
+}
+
+func (v *credentialVault) add(c credential, secret string) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return fmt.Errorf("a name is required")
+	}
+	if secret == "" {
+		return fmt.Errorf("a secret is required")
+	}
+
+	v.mu.Lock()
+	if v.key == nil {
+		v.mu.Unlock()
+		return errVaultLocked
+	}
+	c.ID = newID()
+	c.Created = time.Now()
+	c.secret = secretString(secret)
+	v.credentials = append(v.credentials, c)
+	err := v.saveLocked()
+	v.mu.Unlock()
+
+	v.changed()
+	return err
+}
+
+func (v *credentialVault) remove(id string) error {
+	v.mu.Lock()
+	if v.key == nil {
+		v.mu.Unlock()
+		return errVaultLocked
+	}
+	for i := range v.credentials {
+		if v.credentials[i].ID == id {
+			v.credentials = append(v.credentials[:i], v.credentials[i+1:]...)
+			break
+		}
+	}
+	err := v.saveLocked()
+	v.mu.Unlock()
+
+	v.changed()
+	return err
+}
+
+// resolve returns the credential, including its secret, when it is scoped
+// to target. Callers must not log or persist the result.
+func (v *credentialVault) resolve(id, target string) (credential, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return credential{}, errVaultLocked
+	}
+	for _, c := range v.credentials {
+		if c.ID != id {
+			continue
+		}
+		if !c.appliesTo(target) {
+			return credential{}, fmt.Errorf("credential %q is not scoped to %s", c.Name, target)
+		}
+		return c, nil
+	}
+	return credential{}, fmt.Errorf("credential %s not found", id)
+}
+
+func (v *credentialVault) saveLocked() error {
+	stored := make([]storedCredential, 0, len(v.credentials))
+	for _, c := range v.credentials {
+		stored = append(stored, storedCredential{
+			ID:       c.ID,
+			Name:     c.Name,
+			Kind:     c.Kind,
+			Username: c.Username,
+			Secret:   string(c.secret),
+			Hosts:    c.Hosts,
+			Tags:     c.Tags,
+			Created:  c.Created,
+		})
+	}
+	plaintext, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newVaultCipher(v.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	v.envelope.Nonce = nonce
+	v.envelope.Ciphertext = gcm.Seal(nil, nonce, plaintext, []byte(vaultAAD))
+	for i := range plaintext {
+		plaintext[i] = 0
+	}
+	return saveJSON(vaultFile, v.envelope)
+}
+
+func deriveVaultKey(passphrase string, envelope vaultEnvelope) []byte {
+	return argon2.IDKey([]byte(passphrase), envelope.Salt, envelope.Time, envelope.Memory, envelope.Threads, vaultKeyLength)
+}
+
+func newVaultCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (v *credentialVault) subscribe(fn func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.listeners = append(v.listeners, fn)
+}
+
+func (v *credentialVault) changed() {
+	v.mu.Lock()
+	listeners := append([]func(){}, v.listeners...)
+	v.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+func splitList(input string) []string {
+	var out []string
+	for _, item := range strings.Split(input, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+type vaultModule struct {
+	content         fyne.CanvasObject
+	lockedView      *fyne.Container
+	unlockedView    *fyne.Container
+	passphraseEntry *widget.Entry
+	unlockButton    *widget.Button
+	nameEntry       *widget.Entry
+	kindSelect      *widget.Select
+	userEntry       *widget.Entry
+	secretEntry     *widget.Entry
+	hostsEntry      *widget.Entry
+	tagsEntry       *widget.Entry
+	statusLabel     *widget.Label
+	credentialList  *widget.List
+	deleteButton    *widget.Button
+	credentials     []credential
+	selected        int
+}
+
+func (m *vaultModule) Name() string {
+	return "Credentials"
+}
+
+func (m *vaultModule) Content() fyne.CanvasObject {
+	if m.content != nil {
+		return m.content
+	}
+
+	m.selected = -1
+
+	m.passphraseEntry = widget.NewPasswordEntry()
+	m.passphraseEntry.SetPlaceHolder("Master passphrase")
+	m.passphraseEntry.OnSubmitted = func(string) { m.unlock() }
+	m.unlockButton = widget.NewButton("Unlock", m.unlock)
+
+	rowHeight := m.passphraseEntry.MinSize().Height
+	m.lockedView = container.NewVBox(
+		container.NewHBox(
+			container.New(layout.NewGridWrapLayout(fyne.NewSize(260, rowHeight)), m.passphraseEntry),
+			m.unlockButton,
+			layout.NewSpacer(),
+		),
+	)
+
+	m.nameEntry = widget.NewEntry()
+	m.nameEntry.SetPlaceHolder("Name")
+	m.kindSelect = widget.NewSelect(credentialKinds(), nil)
+	m.kindSelect.SetSelectedIndex(0)
+	m.userEntry = widget.NewEntry()
+	m.userEntry.SetPlaceHolder("Username")
+	m.secretEntry = widget.NewPasswordEntry()
+	m.secretEntry.SetPlaceHolder("Secret")
+	m.hostsEntry = widget.NewEntry()
+	m.hostsEntry.SetPlaceHolder("Hosts/subnets (comma separated)")
+	m.tagsEntry = widget.NewEntry()
+	m.tagsEntry.SetPlaceHolder("Tags (comma separated)")
+
+	addButton := widget.NewButton("Add Credential", m.addCredential)
+	lockButton := widget.NewButton("Lock Vault", defaultVault.lock)
+
+	m.credentialList = widget.NewList(
+		func() int { return len(m.credentials) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i int, obj fyne.CanvasObject) {
+			c := m.credentials[i]
+			scope := strings.Join(append(append([]string(nil), c.Hosts...), prefixed("tag:", c.Tags)...), ", ")
+			if scope == "" {
+				scope = "any host"
+			}
+			obj.(*widget.Label).SetText(fmt.Sprintf("%-20s %-16s %-14s user: %-12s scope: %s", c.ID, c.Name, c.Kind, c.Username, scope))
+		},
+	)
+	m.credentialList.OnSelected = func(id widget.ListItemID) {
+		m.selected = id
+		m.deleteButton.Enable()
+	}
+	m.credentialList.OnUnselected = func(widget.ListItemID) {
+		m.selected = -1
+		m.deleteButton.Disable()
+	}
+	listScroll := container.NewVScroll(m.credentialList)
+	listScroll.SetMinSize(fyne.NewSize(0, 220))
+
+	m.deleteButton = widget.NewButton("Delete", m.deleteSelected)
+	m.deleteButton.Disable()
+
+	m.unlockedView = container.NewVBox(
+		container.NewHBox(
+			container.New(layout.NewGridWrapLayout(fyne.NewSize(160, rowHeight)), m.nameEntry),
+			container.New(layout.NewGridWrapLayout(fyne.NewSize(160, rowHeight)), m.kindSelect),
+			container.New(layout.NewGridWrapLayout(fyne.NewSize(140, rowHeight)), m.userEntry),
+			container.New(layout.NewGridWrapLayout(fyne.NewSize(180, rowHeight)), m.secretEntry),
+			layout.NewSpacer(),
+		),
+		container.NewHBox(
+			container.New(layout.NewGridWrapLayout(fyne.NewSize(260, rowHeight)), m.hostsEntry),
+			container.New(layout.NewGridWrapLayout(fyne.NewSize(200, rowHeight)), m.tagsEntry),
+			addButton,
+			layout.NewSpacer(),
+		),
+		widget.NewCard("Stored Credentials", "Scan jobs reference credentials by id; secrets are never shown.",
+			container.NewVBox(listScroll, container.NewHBox(m.deleteButton, lockButton, layout.NewSpacer()))),
+	)
+
+	m.statusLabel = widget.NewLabel("")
+
+	m.content = container.NewVBox(
+		widget.NewLabelWithStyle("Credential Vault", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel("Credentials for authenticated checks, encrypted at rest with a master passphrase."),
+		m.statusLabel,
+		m.lockedView,
+		m.unlockedView,
+	)
+
+	defaultVault.subscribe(func() { m.queueOnMain(m.reload) })
+	m.reload()
+
+	return m.content
+}
+
+func prefixed(prefix string, items []string) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = prefix + item
+	}
+	return out
+}
+
+func (m *vaultModule) unlock() {
+	passphrase := m.passphraseEntry.Text
+	var err error
+	if defaultVault.exists() {
+		err = defaultVault.unlock(passphrase)
+	} else {
+		err = defaultVault.create(passphrase)
+	}
+	m.passphraseEntry.SetText("")
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Unable to open vault: %v", err))
+		return
+	}
+	m.setStatus("Vault unlocked.")
+}
+
+func (m *vaultModule) addCredential() {
+	c := credential{
+		Name:     m.nameEntry.Text,
+		Kind:     m.kindSelect.Selected,
+		Username: strings.TrimSpace(m.userEntry.Text),
+		Hosts:    splitList(m.hostsEntry.Text),
+		Tags:     splitList(m.tagsEntry.Text),
+	}
+	if err := defaultVault.add(c, m.secretEntry.Text); err != nil {
+		m.setStatus(fmt.Sprintf("Unable to add credential: %v", err))
+		return
+	}
+	m.secretEntry.SetText("")
+	m.nameEntry.SetText("")
+	m.userEntry.SetText("")
+	m.hostsEntry.SetText("")
+	m.tagsEntry.SetText("")
+	m.setStatus("Credential stored.")
+}
+
+func (m *vaultModule) deleteSelected() {
+	if m.selected < 0 || m.selected >= len(m.credentials) {
+		return
+	}
+	if err := defaultVault.remove(m.credentials[m.selected].ID); err != nil {
+		m.setStatus(fmt.Sprintf("Unable to delete credential: %v", err))
+	}
+	m.credentialList.UnselectAll()
+}
+
+func (m *vaultModule) reload() {
+	creds, err := defaultVault.list()
+	if err != nil {
+		m.credentials = nil
+		m.unlockedView.Hide()
+		m.lockedView.Show()
+		if defaultVault.exists() {
+			m.unlockButton.SetText("Unlock")
+			m.setStatus("The vault is locked. Enter the master passphrase to unlock it.")
+		} else {
+			m.unlockButton.SetText("Create Vault")
+			m.setStatus(fmt.Sprintf("No vault yet. Choose a master passphrase of at least %d characters.", minPassphrase))
+		}
+	} else {
+		m.credentials = creds
+		m.lockedView.Hide()
+		m.unlockedView.Show()
+	}
+	m.credentialList.Refresh()
+}
+
+func (m *vaultModule) setStatus(text string) {
+	if m.statusLabel != nil {
+		m.statusLabel.SetText(text)
+	}
+}
+
+func (m *vaultModule) queueOnMain(fn func()) {
+	if app := fyne.CurrentApp(); app != nil {
+		if drv := app.Driver(); drv != nil {
+			if runner, ok := drv.(interface{ RunOnMain(func()) }); ok {
+				runner.RunOnMain(fn)
+				return
+			}
+		}
+	}
+	fn()
+}
+
+// unlockVaultHeadless opens the vault for the daemon, which has no window to
+// ask for the passphrase in. It is read from passphraseFile when given, which
+// must not be readable by other users, or else from RODENT_VAULT_PASSPHRASE.
+// The variable is cleared once read so jobs' child processes do not inherit
+// it, and the passphrase is never logged.
+func unlockVaultHeadless(passphraseFile string) error {
+	passphrase := os.Getenv("RODENT_VAULT_PASSPHRASE")
+	os.Unsetenv("RODENT_VAULT_PASSPHRASE")
+	if passphraseFile != "" {
+		info, err := os.Stat(passphraseFile)
+		if err != nil {
+			return err
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+			return fmt.Errorf("%s is readable by other users; restrict it to mode 0600", passphraseFile)
+		}
+		data, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return err
+		}
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	}
+	if passphrase == "" {
+		return nil
+	}
+	if !defaultVault.exists() {
+		return fmt.Errorf("no credential vault to unlock")
+	}
+	return defaultVault.unlock(passphrase)
+}
@@ -0,0 +1,92 @@
+package modules
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	const passphrase = "correct horse battery"
+	v := &credentialVault{}
+	if err := v.create("short"); err == nil {
+		t.Fatal("create accepted a short passphrase")
+	}
+	if err := v.create(passphrase); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := v.create(passphrase); err == nil {
+		t.Fatal("create replaced an existing vault")
+	}
+	if err := v.add(credential{Name: "router", Kind: "SSH password", Username: "admin"}, "s3cret"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	v.lock()
+	if _, err := v.list(); !errors.Is(err, errVaultLocked) {
+		t.Fatalf("list on a locked vault: %v, want %v", err, errVaultLocked)
+	}
+
+	if err := v.unlock("wrong passphrase"); !errors.Is(err, errVaultPassphrase) {
+		t.Fatalf("unlock with the wrong passphrase: %v, want %v", err, errVaultPassphrase)
+	}
+	if v.unlocked() {
+		t.Fatal("vault unlocked with the wrong passphrase")
+	}
+
+	if err := v.unlock(passphrase); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	creds, err := v.list()
+	if err != nil || len(creds) != 1 {
+		t.Fatalf("list = %v, %v; want one credential", creds, err)
+	}
+	if creds[0].secret != "" {
+		t.Error("list exposed the secret")
+	}
+	c, err := v.resolve(creds[0].ID, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if c.Username != "admin" || c.secret != "s3cret" {
+		t.Errorf("resolve = %s / %q, want admin / s3cret", c.Username, string(c.secret))
+	}
+}
+
+func TestUnlockVaultHeadless(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("RODENT_VAULT_PASSPHRASE", "")
+	const passphrase = "correct horse battery"
+	if err := defaultVault.create(passphrase); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defaultVault.lock()
+	t.Cleanup(defaultVault.lock)
+
+	file := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(file, []byte(passphrase+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := unlockVaultHeadless(file); err == nil || defaultVault.unlocked() {
+			t.Fatalf("unlocked from a world-readable file: %v", err)
+		}
+	}
+	if err := os.Chmod(file, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := unlockVaultHeadless(file); err != nil || !defaultVault.unlocked() {
+		t.Fatalf("unlock from file: %v", err)
+	}
+
+	defaultVault.lock()
+	os.Setenv("RODENT_VAULT_PASSPHRASE", passphrase)
+	if err := unlockVaultHeadless(""); err != nil || !defaultVault.unlocked() {
+		t.Fatalf("unlock from the environment: %v", err)
+	}
+	if os.Getenv("RODENT_VAULT_PASSPHRASE") != "" {
+		t.Error("the passphrase was left in the environment")
+	}
+}
@@ -1,6 +1,7 @@
 package modules
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"net"
@@ -14,26 +15,111 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
+const (
+	checkPassive    = "passive"
+	checkSafe       = "safe"
+	checkIntrusive  = "intrusive"
+	checkDisruptive = "potentially disruptive"
+)
+
+func checkCategories() []string {
+	return []string{checkPassive, checkSafe, checkIntrusive, checkDisruptive}
+}
+
+func defaultCheckCategories() []string {
+	return []string{checkPassive, checkSafe}
+}
+
+func checkCategoryLabel(category string) string {
+	switch category {
+	case checkPassive:
+		return "Passive (no traffic)"
+	case checkSafe:
+		return "Safe (connect only)"
+	case checkIntrusive:
+		return "Intrusive (sends payloads)"
+	case checkDisruptive:
+		return "Potentially disruptive"
+	default:
+		return category
+	}
+}
+
+func newCategoryGroup(changed func([]string)) *widget.CheckGroup {
+	var options, selected []string
+	for _, c := range checkCategories() {
+		options = append(options, checkCategoryLabel(c))
+	}
+	for _, c := range defaultCheckCategories() {
+		selected = append(selected, checkCategoryLabel(c))
+	}
+	group := widget.NewCheckGroup(options, changed)
+	group.Horizontal = true
+	group.Selected = selected
+	return group
+}
+
+func categoriesFromLabels(labels []string) []string {
+	var categories []string
+	for _, c := range checkCategories() {
+		for _, label := range labels {
+			if label == checkCategoryLabel(c) {
+				categories = append(categories, c)
+			}
+		}
+	}
+	return categories
+}
+
+// allowedCategories falls back to the defaults so that jobs saved without a
+// choice never run intrusive checks.
+func allowedCategories(categories []string) map[string]bool {
+	if len(categories) == 0 {
+		categories = defaultCheckCategories()
+	}
+	allowed := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		allowed[c] = true
+	}
+	return allowed
+}
+
 type vulnerabilityModule struct {
-	content     fyne.CanvasObject
-	targetEntry *widget.Entry
-	runButton   *widget.Button
-	statusLabel *widget.Label
-	resultsList *widget.List
-	findings    []vulnerabilityFinding
-	cancel      context.CancelFunc
-	running     bool
+	content        fyne.CanvasObject
+	targetEntry    *widget.Entry
+	sourceEntry    *widget.SelectEntry
+	runButton      *widget.Button
+	pauseButton    *widget.Button
+	statusLabel    *widget.Label
+	categoryGroup  *widget.CheckGroup
+	warningLabel   *widget.Label
+	resultsList    *widget.List
+	findings       []vulnerabilityFinding
+	lastTarget     string
+	lastCategories []string
+	cancel         context.CancelFunc
+	control        *runControl
+	running        bool
 }
 
 type vulnerabilityFinding struct {
-	Service     string
-	Severity    string
-	Description string
-	Remediation string
+	Service     string `json:"service"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	Remediation string `json:"remediation"`
+	Source      string `json:"source,omitempty"`
+	Category    string `json:"category,omitempty"`
+}
+
+func (f vulnerabilityFinding) line() string {
+	if f.Category == "" {
+		return fmt.Sprintf("[%s] %s - %s", f.Severity, f.Service, f.Description)
+	}
+	return fmt.Sprintf("[%s] %s (%s check) - %s", f.Severity, f.Service, f.Category, f.Description)
 }
 
 func (m *vulnerabilityModule) Name() string {
-	return "Vulnerability Scanner"
+	return moduleVulnerability
 }
 
 func (m *vulnerabilityModule) Content() fyne.CanvasObject {
@@ -44,12 +130,22 @@ func (m *vulnerabilityModule) Content() fyne.CanvasObject {
 	m.targetEntry = widget.NewEntry()
 	m.targetEntry.SetPlaceHolder("Target host (IP or hostname)")
 
+	m.sourceEntry = newSourceEntry()
 	m.runButton = widget.NewButton("Run Vulnerability Scan", m.toggleRun)
 
 	entryField := container.New(layout.NewGridWrapLayout(fyne.NewSize(260, m.targetEntry.MinSize().Height)), m.targetEntry)
 	buttonWrap := container.New(layout.NewGridWrapLayout(fyne.NewSize(220, m.runButton.MinSize().Height)), m.runButton)
 	buttonSpacer := container.New(layout.NewGridWrapLayout(fyne.NewSize(12, m.runButton.MinSize().Height)), widget.NewLabel(""))
-	entryRow := container.NewHBox(entryField, buttonSpacer, buttonWrap, layout.NewSpacer())
+	sourceField := container.New(layout.NewGridWrapLayout(fyne.NewSize(240, m.targetEntry.MinSize().Height)), m.sourceEntry)
+	sourceSpacer := container.New(layout.NewGridWrapLayout(fyne.NewSize(12, m.runButton.MinSize().Height)), widget.NewLabel(""))
+	m.pauseButton = widget.NewButton("Pause", m.togglePause)
+	m.pauseButton.Disable()
+	pauseWrap := container.New(layout.NewGridWrapLayout(fyne.NewSize(100, m.runButton.MinSize().Height)), m.pauseButton)
+	entryRow := container.NewHBox(entryField, sourceSpacer, sourceField, buttonSpacer, buttonWrap, pauseWrap, layout.NewSpacer())
+
+	m.warningLabel = widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+	m.categoryGroup = newCategoryGroup(func([]string) { m.updateCategoryWarning() })
+	m.updateCategoryWarning()
 
 	m.statusLabel = widget.NewLabel("Idle. Provide a target and click Run.")
 
@@ -58,7 +154,7 @@ func (m *vulnerabilityModule) Content() fyne.CanvasObject {
 		func() fyne.CanvasObject { return widget.NewLabel("") },
 		func(i int, obj fyne.CanvasObject) {
 			f := m.findings[i]
-			obj.(*widget.Label).SetText(fmt.Sprintf("[%s] %s - %s\nRemediation: %s", f.Severity, f.Service, f.Description, f.Remediation))
+			obj.(*widget.Label).SetText(fmt.Sprintf("%s\nRemediation: %s", f.line(), f.Remediation))
 		},
 	)
 
@@ -69,10 +165,19 @@ func (m *vulnerabilityModule) Content() fyne.CanvasObject {
 		widget.NewLabelWithStyle("Vulnerability Scanner", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
 		widget.NewLabel("Run lightweight checks for common exposures."),
 		entryRow,
+		m.categoryGroup,
+		m.warningLabel,
+		newProxyNotice(moduleVulnerability),
 		m.statusLabel,
 		widget.NewCard("Findings", "Severity ratings and remediation suggestions.", container.NewMax(scroll)),
 	)
 
+	scanHistory().subscribe(func(rec scanRecord) {
+		if rec.Module == moduleScanner && rec.Status == runCompleted {
+			m.queueOnMain(func() { m.mergePolicyFindings(rec) })
+		}
+	})
+
 	return m.content
 }
 
@@ -91,38 +196,132 @@ func (m *vulnerabilityModule) toggleRun() {
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	m.cancel = cancel
-	m.findings = nil
-	m.resultsList.Refresh()
-	m.setRunning(true)
-	m.setStatus(fmt.Sprintf("Running vulnerability checks for %s ...", target))
+	pins, err := checkScopeHosts(moduleVulnerability, []string{target})
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Refused: %v.", err))
+		return
+	}
+
+	categories := m.selectedCategories()
+	if len(categories) == 0 {
+		m.setStatus("Select at least one check category.")
+		return
+	}
+
+	src, err := parseSource(m.sourceEntry.Text)
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Invalid source: %v.", err))
+		return
+	}
+
+	ranges := sensitiveAddresses([]string{target})
+	spec := jobSpec{Module: moduleVulnerability, Target: target, Categories: categories, Source: src.source()}
+	err = confirmRun(spec, ranges, func(acknowledged []string) {
+		spec.Acknowledged = acknowledged
+		m.control = newRunControl()
+		ctx, cancel := guardContext(withRunControl(context.Background(), m.control))
+		m.cancel = cancel
+		m.findings = nil
+		m.lastTarget = target
+		m.lastCategories = categories
+		m.resultsList.Refresh()
+		m.setRunning(true)
+		m.setStatus(fmt.Sprintf("Running vulnerability checks for %s ...", target))
+
+		go func() {
+			defer cancel()
+			m.performScan(ctx, spec, pinNetwork(liveNetwork{src: src}, pins), ranges)
+		}()
+	})
+	if err != nil {
+		m.setStatus(fmt.Sprintf("Refused: %v.", err))
+	}
+}
 
-	go m.performScan(ctx, target)
+func (m *vulnerabilityModule) performScan(ctx context.Context, spec jobSpec, nw probeNetwork, ranges []sensitiveRange) {
+	target := spec.Target
+	record := beginRun(spec, "")
+	probe, skipped, err := partitionExcluded([]string{target})
+	if err == nil {
+		err = preflight(spec, ranges, &record)
+	}
+	if err != nil || len(probe) == 0 {
+		record.Skipped = skipped
+		record.finish(false, err)
+		endRun(record)
+		m.queueOnMain(func() {
+			if err != nil {
+				m.setStatus(fmt.Sprintf("Vulnerability scan failed: %v.", err))
+			} else {
+				m.setStatus(fmt.Sprintf("Target %s.", skipped[0]))
+			}
+			m.setRunning(false)
+		})
+		return
+	}
+	rc := runControlFrom(ctx)
+	rc.track(spec, record, nil)
+	results, canceled := checkVulnerabilities(ctx, nw, target, spec.Categories, nil)
+	record.Findings = results
+	record.finish(canceled, guardCause(ctx))
+	rc.finish(record)
+	endRun(record)
+
+	if canceled {
+		m.queueStatus("Vulnerability scan stopped.")
+		m.setRunning(false)
+		return
+	}
+
+	m.queueOnMain(func() {
+		m.findings = results
+		m.resultsList.Refresh()
+		m.setStatus(fmt.Sprintf("Vulnerability scan complete for %s (%d finding(s)).", target, len(results)))
+		m.setRunning(false)
+	})
 }
 
-func (m *vulnerabilityModule) performScan(ctx context.Context, target string) {
+// checkVulnerabilities runs the rules of the chosen categories. cred is the
+// job's resolved credential, if any; checks that need one skip without it.
+func checkVulnerabilities(ctx context.Context, nw probeNetwork, target string, categories []string, cred *credential) ([]vulnerabilityFinding, bool) {
+	allowed := allowedCategories(categories)
 	rules := vulnerabilityRules()
 	results := make([]vulnerabilityFinding, 0, len(rules))
+	rc := runControlFrom(ctx)
+	prior := rc.resumed()
+	results = append(results, prior.Record.Findings...)
 
-	for _, rule := range rules {
-		select {
-		case <-ctx.Done():
-			m.queueStatus("Vulnerability scan stopped.")
-			m.setRunning(false)
-			return
-		default:
+	for i, rule := range rules {
+		if i < prior.Done || !allowed[rule.Category] {
+			continue
+		}
+
+		if !rc.wait(ctx) {
+			return results, true
 		}
 
+		check := rule.Check
+		if check == nil {
+			check = portOpen
+		}
 		address := net.JoinHostPort(target, strconv.Itoa(rule.Port))
-		if portOpen(address, 500*time.Millisecond) {
-			results = append(results, vulnerabilityFinding{
+		var finding *vulnerabilityFinding
+		if check(nw, address, 500*time.Millisecond, cred) {
+			finding = &vulnerabilityFinding{
 				Service:     rule.Service,
 				Severity:    rule.Severity,
 				Description: rule.Description,
 				Remediation: rule.Remediation,
-			})
+				Category:    rule.Category,
+			}
+			results = append(results, *finding)
 		}
+		rc.progress(func(cp *runCheckpoint) {
+			cp.Done = i + 1
+			if finding != nil {
+				cp.Record.Findings = append(cp.Record.Findings, *finding)
+			}
+		})
 	}
 
 	if len(results) == 0 {
@@ -134,12 +333,59 @@ func (m *vulnerabilityModule) performScan(ctx context.Context, target string) {
 		})
 	}
 
-	m.queueOnMain(func() {
-		m.findings = results
-		m.resultsList.Refresh()
-		m.setStatus(fmt.Sprintf("Vulnerability scan complete for %s (%d finding(s)).", target, len(results)))
-		m.setRunning(false)
-	})
+	if rec, ok := scanHistory().latest(moduleScanner, target); ok && allowed[checkPassive] {
+		results = append(results, policyFindings(rec.Findings)...)
+	}
+
+	return results, false
+}
+
+func policyFindings(findings []vulnerabilityFinding) []vulnerabilityFinding {
+	var out []vulnerabilityFinding
+	for _, f := range findings {
+		if f.Source == findingSourcePolicy {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func (m *vulnerabilityModule) mergePolicyFindings(rec scanRecord) {
+	if m.running || m.lastTarget == "" || !strings.EqualFold(rec.Target, m.lastTarget) || !allowedCategories(m.lastCategories)[checkPassive] {
+		return
+	}
+	merged := make([]vulnerabilityFinding, 0, len(m.findings))
+	for _, f := range m.findings {
+		if f.Source != findingSourcePolicy {
+			merged = append(merged, f)
+		}
+	}
+	m.findings = append(merged, policyFindings(rec.Findings)...)
+	m.resultsList.Refresh()
+}
+
+func (m *vulnerabilityModule) selectedCategories() []string {
+	return categoriesFromLabels(m.categoryGroup.Selected)
+}
+
+func (m *vulnerabilityModule) updateCategoryWarning() {
+	if m.warningLabel == nil {
+		return
+	}
+	counts := map[string]int{}
+	for _, rule := range vulnerabilityRules() {
+		counts[rule.Category]++
+	}
+	var parts []string
+	for _, c := range checkCategories() {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[c], c))
+	}
+	text := fmt.Sprintf("Available checks: %s.", strings.Join(parts, ", "))
+	allowed := allowedCategories(m.selectedCategories())
+	if allowed[checkIntrusive] || allowed[checkDisruptive] {
+		text += " Warning: intrusive checks send payloads and may affect the target's services."
+	}
+	m.warningLabel.SetText(text)
 }
 
 func (m *vulnerabilityModule) setStatus(text string) {
@@ -148,8 +394,31 @@ func (m *vulnerabilityModule) setStatus(text string) {
 	}
 }
 
+func (m *vulnerabilityModule) togglePause() {
+	if !m.running || m.control == nil {
+		return
+	}
+	if m.control.paused() {
+		m.control.unpause()
+		m.pauseButton.SetText("Pause")
+		m.setStatus("Vulnerability scan resumed.")
+		return
+	}
+	m.control.pause()
+	m.pauseButton.SetText("Resume")
+	m.setStatus("Vulnerability scan paused. Progress is checkpointed and can be resumed after a restart.")
+}
+
 func (m *vulnerabilityModule) setRunning(active bool) {
 	m.running = active
+	if m.pauseButton != nil {
+		m.pauseButton.SetText("Pause")
+		if active {
+			m.pauseButton.Enable()
+		} else {
+			m.pauseButton.Disable()
+		}
+	}
 	if m.runButton != nil {
 		if active {
 			m.runButton.SetText("Stop Vulnerability Scan")
@@ -187,21 +456,79 @@ type vulnerabilityRule struct {
 	Severity    string
 	Description string
 	Remediation string
+	Category    string
+	Check       func(nw probeNetwork, address string, timeout time.Duration, cred *credential) bool
 }
 
 func vulnerabilityRules() []vulnerabilityRule {
 	return []vulnerabilityRule{
-		{21, "FTP (21/tcp)", "Medium", "FTP service detected. Anonymous or unencrypted FTP can expose credentials.", "Disable FTP or enforce FTPS/SFTP with strong authentication."},
-		{22, "SSH (22/tcp)", "Medium", "SSH reachable from the network. Weak passwords enable brute-force attacks.", "Restrict SSH to trusted IPs and require key-based authentication."},
-		{80, "HTTP (80/tcp)", "High", "Plain HTTP service detected. Traffic is unencrypted and susceptible to MITM attacks.", "Redirect HTTP to HTTPS and enforce TLS 1.2+."},
-		{443, "HTTPS (443/tcp)", "Medium", "HTTPS service reachable. Ensure TLS configuration is hardened.", "Disable legacy ciphers, enable HSTS, and use modern certificates."},
-		{3389, "RDP (3389/tcp)", "High", "Remote Desktop exposed. RDP is a common entry vector for ransomware.", "Restrict RDP to VPN users, enable MFA, and keep patches current."},
-		{6379, "Redis (6379/tcp)", "Critical", "Redis port open. Default Redis has no authentication and can be exploited remotely.", "Bind Redis to localhost, enable AUTH, or deploy behind a firewall."},
+		{21, "FTP (21/tcp)", "Medium", "FTP service detected. Anonymous or unencrypted FTP can expose credentials.", "Disable FTP or enforce FTPS/SFTP with strong authentication.", checkSafe, nil},
+		{22, "SSH (22/tcp)", "Medium", "SSH reachable from the network. Weak passwords enable brute-force attacks.", "Restrict SSH to trusted IPs and require key-based authentication.", checkSafe, nil},
+		{80, "HTTP (80/tcp)", "High", "Plain HTTP service detected. Traffic is unencrypted and susceptible to MITM attacks.", "Redirect HTTP to HTTPS and enforce TLS 1.2+.", checkSafe, nil},
+		{443, "HTTPS (443/tcp)", "Medium", "HTTPS service reachable. Ensure TLS configuration is hardened.", "Disable legacy ciphers, enable HSTS, and use modern certificates.", checkSafe, nil},
+		{3389, "RDP (3389/tcp)", "High", "Remote Desktop exposed. RDP is a common entry vector for ransomware.", "Restrict RDP to VPN users, enable MFA, and keep patches current.", checkSafe, nil},
+		{6379, "Redis (6379/tcp)", "Critical", "Redis port open. Default Redis has no authentication and can be exploited remotely.", "Bind Redis to localhost, enable AUTH, or deploy behind a firewall.", checkSafe, nil},
+		{6379, "Redis unauthenticated (6379/tcp)", "Critical", "Redis answered a PING without authentication. Anyone reaching the port can read and modify data.", "Enable AUTH or ACLs and bind Redis to trusted interfaces.", checkIntrusive, redisUnauthenticated},
+		{6379, "Redis CONFIG allowed (6379/tcp)", "High", "The job's database login may run CONFIG, which lets the account move the data directory and write files on the host.", "Remove the @admin and @dangerous ACL categories from the account or rename CONFIG.", checkIntrusive, redisConfigAllowed},
+	}
+}
+
+func redisUnauthenticated(nw probeNetwork, address string, timeout time.Duration, _ *credential) bool {
+	conn, err := nw.dialTCP(address, timeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return false
+	}
+	buf := make([]byte, 16)
+	n, _ := conn.Read(buf)
+	return strings.HasPrefix(string(buf[:n]), "+PONG")
+}
+
+// redisConfigAllowed logs in with a "Database login" credential and asks for
+// the data directory. Without such a credential the check does not run.
+func redisConfigAllowed(nw probeNetwork, address string, timeout time.Duration, cred *credential) bool {
+	if cred == nil || cred.Kind != "Database login" {
+		return false
+	}
+	conn, err := nw.dialTCP(address, timeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	reader := bufio.NewReader(conn)
+
+	auth := []string{"AUTH", string(cred.secret)}
+	if cred.Username != "" {
+		auth = []string{"AUTH", cred.Username, string(cred.secret)}
+	}
+	if reply, err := redisCommand(conn, reader, auth...); err != nil || reply != "+OK" {
+		return false
+	}
+	reply, err := redisCommand(conn, reader, "CONFIG", "GET", "dir")
+	return err == nil && strings.HasPrefix(reply, "*2")
+}
+
+// redisCommand sends args as a RESP array and returns the first reply line.
+func redisCommand(conn net.Conn, reader *bufio.Reader, args ...string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return "", err
 	}
+	line, err := reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
 }
 
-func portOpen(address string, timeout time.Duration) bool {
-	conn, err := net.DialTimeout("tcp", address, timeout)
+func portOpen(nw probeNetwork, address string, timeout time.Duration, _ *credential) bool {
+	conn, err := nw.dialTCP(address, timeout)
 	if err != nil {
 		return false
 	}
@@ -0,0 +1,103 @@
+package modules
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// redisStub answers AUTH for one account and CONFIG GET when admin is set.
+type redisStub struct {
+	*simNetwork
+	user, password string
+	admin          bool
+}
+
+func (r redisStub) dialTCP(address string, timeout time.Duration) (net.Conn, error) {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		reader := bufio.NewReader(server)
+		authed := false
+		for {
+			args, err := readRESP(reader)
+			if err != nil {
+				return
+			}
+			reply := "-ERR unknown command\r\n"
+			switch strings.ToUpper(args[0]) {
+			case "AUTH":
+				user, password := "default", args[len(args)-1]
+				if len(args) == 3 {
+					user = args[1]
+				}
+				authed = user == r.user && password == r.password
+				reply = "-WRONGPASS invalid username-password pair\r\n"
+				if authed {
+					reply = "+OK\r\n"
+				}
+			case "CONFIG":
+				switch {
+				case !authed:
+					reply = "-NOAUTH Authentication required.\r\n"
+				case !r.admin:
+					reply = "-NOPERM this user has no permissions to run the 'config' command\r\n"
+				default:
+					reply = "*2\r\n$3\r\ndir\r\n$14\r\n/var/lib/redis\r\n"
+				}
+			}
+			if _, err := server.Write([]byte(reply)); err != nil {
+				return
+			}
+		}
+	}()
+	return client, nil
+}
+
+func readRESP(reader *bufio.Reader) ([]string, error) {
+	var n int
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Sscanf(line, "*%d", &n); err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimRight(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestRedisConfigAllowed(t *testing.T) {
+	login := &credential{Kind: "Database login", Username: "app", secret: "s3cret"}
+	tests := []struct {
+		name string
+		stub redisStub
+		cred *credential
+		want bool
+	}{
+		{"admin account", redisStub{user: "app", password: "s3cret", admin: true}, login, true},
+		{"restricted account", redisStub{user: "app", password: "s3cret"}, login, false},
+		{"wrong password", redisStub{user: "app", password: "other", admin: true}, login, false},
+		{"default user", redisStub{user: "default", password: "s3cret", admin: true}, &credential{Kind: "Database login", secret: "s3cret"}, true},
+		{"no credential", redisStub{user: "app", password: "s3cret", admin: true}, nil, false},
+		{"other kind", redisStub{user: "app", password: "s3cret", admin: true}, &credential{Kind: "SSH password", Username: "app", secret: "s3cret"}, false},
+	}
+	for _, tt := range tests {
+		if got := redisConfigAllowed(tt.stub, "10.0.0.1:6379", time.Second, tt.cred); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}